@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"tls-secret-injector/pkg/replica"
+	"tls-secret-injector/pkg/secretcache"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func getAdoptCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "adopt",
+		Short: "Adopt the existing Secrets that share their name with a source Secret, so they are managed from then on, whatever the conflict policy of their namespace",
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			mgr, err := newCommandManager()
+			if err != nil {
+				return
+			}
+
+			secretSource, err := newSecretSource(mgr, secretcache.ModeFull)
+			if err != nil {
+				return
+			}
+
+			policies, err := newPolicyResolver(mgr)
+			if err != nil {
+				return
+			}
+
+			writer := replica.NewWriter(mgr.GetClient(), secretSource, policies, mgr.GetEventRecorderFor("tls-secret-injector"))
+
+			err = startCommandManager(ctx, mgr)
+			if err != nil {
+				return
+			}
+
+			namespace, _ := cmd.Flags().GetString("namespace")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+			// Look for unmanaged Secrets outside of the source
+			secretList := &corev1.SecretList{}
+
+			err = mgr.GetClient().List(ctx, secretList, client.InNamespace(namespace))
+			if err != nil {
+				err = fmt.Errorf("could not list Secrets: %v", err)
+				return
+			}
+
+			var adopted, failed int
+
+			for i := range secretList.Items {
+				existingSecret := &secretList.Items[i]
+
+				if replica.IsManaged(existingSecret) || secretSource.IsSourceNamespace(ctx, existingSecret.Namespace) {
+					continue
+				}
+
+				sourceSecret, err := secretSource.Get(ctx, existingSecret.Name)
+				if errors.IsNotFound(err) {
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("could not fetch the source Secret [%s]: %v", existingSecret.Name, err)
+				}
+
+				if dryRun {
+					fmt.Printf("Would adopt Secret [%s/%s]\n", existingSecret.Namespace, existingSecret.Name)
+					adopted++
+					continue
+				}
+
+				err = writer.Adopt(ctx, sourceSecret, existingSecret, nil)
+				if err != nil {
+					fmt.Printf("Failed to adopt Secret [%s/%s]: %v\n", existingSecret.Namespace, existingSecret.Name, err)
+					failed++
+					continue
+				}
+
+				fmt.Printf("Adopted Secret [%s/%s]\n", existingSecret.Namespace, existingSecret.Name)
+				adopted++
+			}
+
+			fmt.Printf("%d Secrets adopted, %d failed\n", adopted, failed)
+
+			if failed > 0 {
+				err = fmt.Errorf("failed to adopt %d Secrets", failed)
+			}
+
+			return
+		},
+	}
+
+	c.Flags().String("namespace", "", "Only adopt the Secrets in this namespace")
+	c.Flags().Bool("dry-run", false, "Only print the Secrets that would be adopted")
+
+	return c
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"tls-secret-injector/pkg/index"
+
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// newCommandManager returns a manager without leader election, probes or metrics for the one-off commands
+func newCommandManager() (manager.Manager, error) {
+	mgr, err := manager.New(config.GetConfigOrDie(), manager.Options{
+		HealthProbeBindAddress: "0",
+		MetricsBindAddress:     "0",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up manager: %v", err)
+	}
+
+	err = index.Register(context.Background(), mgr.GetFieldIndexer())
+	if err != nil {
+		return nil, err
+	}
+
+	return mgr, nil
+}
+
+// startCommandManager runs the manager in the background until the context is done, and waits for its caches to fill
+func startCommandManager(ctx context.Context, mgr manager.Manager) error {
+	go func() {
+		_ = mgr.Start(ctx)
+	}()
+
+	if !mgr.GetCache().WaitForCacheSync(ctx) {
+		return fmt.Errorf("unable to sync the caches")
+	}
+
+	return nil
+}
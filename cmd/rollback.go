@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/history"
+	"tls-secret-injector/pkg/index"
+	"tls-secret-injector/pkg/replica"
+	"tls-secret-injector/pkg/secretcache"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func getRollbackCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "rollback <source>",
+		Short: "Restore a previous version of a source Secret from the history, to the source and to all its copies",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// The restored version is written back to the source, which only the local Kubernetes backend allows
+			if viper.GetString("source-backend") != "kubernetes" || viper.GetString("source-kubeconfig") != "" || viper.GetString("source-kubeconfig-secret") != "" {
+				return fmt.Errorf("rollback is only supported for source Secrets in the local cluster")
+			}
+
+			mgr, err := newCommandManager()
+			if err != nil {
+				return
+			}
+
+			secretSource, err := newSecretSource(mgr, secretcache.ModeFull)
+			if err != nil {
+				return
+			}
+
+			secretHistory, err := newSecretHistory(mgr, secretSource)
+			if err != nil {
+				return
+			}
+			if secretHistory == nil {
+				return fmt.Errorf("rollback requires the history, enabled with --history-limit")
+			}
+
+			policies, err := newPolicyResolver(mgr)
+			if err != nil {
+				return
+			}
+
+			writer := replica.NewWriter(mgr.GetClient(), secretHistory, policies, mgr.GetEventRecorderFor("tls-secret-injector"))
+
+			err = startCommandManager(ctx, mgr)
+			if err != nil {
+				return
+			}
+
+			name := args[0]
+			toVersion, _ := cmd.Flags().GetInt("to-version")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+			// Find the version to restore, by default the one before the version the source Secret holds
+			sourceSecret, err := secretHistory.Get(ctx, name)
+			if err != nil {
+				return fmt.Errorf("could not fetch the source Secret [%s]: %v", name, err)
+			}
+
+			sourceSecret = sourceSecret.DeepCopy()
+
+			err = secretHistory.Resolve(ctx, sourceSecret)
+			if err != nil {
+				return
+			}
+
+			if toVersion == 0 {
+				toVersion, err = previousVersion(ctx, secretHistory, sourceSecret)
+				if err != nil {
+					return
+				}
+			}
+
+			historySecret, err := secretHistory.Find(ctx, name, toVersion)
+			if err != nil {
+				return fmt.Errorf("could not fetch version %d of Secret [%s]: %v", toVersion, name, err)
+			}
+
+			if historySecret.Type != sourceSecret.Type {
+				return fmt.Errorf("could not restore version %d of Secret [%s] as its type %s differs from %s", toVersion, name, historySecret.Type, sourceSecret.Type)
+			}
+
+			copies, err := listCopies(ctx, mgr.GetClient(), secretSource, name)
+			if err != nil {
+				return
+			}
+
+			if dryRun {
+				fmt.Printf("Would restore version %d of Secret [%s/%s] to the source and %d copies\n", toVersion, sourceSecret.Namespace, name, len(copies))
+
+				// Report the Ingresses serving the restored certificate
+				lookup := index.NewLookup(mgr.GetClient())
+
+				for _, targetSecret := range copies {
+					var ingresses []networkingv1.Ingress
+
+					ingresses, err = lookup.IngressesUsing(ctx, types.NamespacedName{Namespace: targetSecret.Namespace, Name: targetSecret.Name})
+					if err != nil {
+						return
+					}
+
+					fmt.Printf("Would restore Secret [%s/%s] used by %d Ingresses\n", targetSecret.Namespace, targetSecret.Name, len(ingresses))
+				}
+
+				return
+			}
+
+			// Restore the version to the source Secret
+			restoredSecret := &corev1.Secret{}
+
+			err = mgr.GetClient().Get(ctx, types.NamespacedName{Namespace: sourceSecret.Namespace, Name: name}, restoredSecret)
+			if err != nil {
+				return fmt.Errorf("could not fetch the source Secret [%s/%s]: %v", sourceSecret.Namespace, name, err)
+			}
+
+			restoredSecret.Data = historySecret.Data
+
+			err = mgr.GetClient().Update(ctx, restoredSecret)
+			if err != nil {
+				return fmt.Errorf("failed to restore the source Secret [%s/%s]: %v", sourceSecret.Namespace, name, err)
+			}
+
+			fmt.Printf("Restored version %d of Secret [%s/%s]\n", toVersion, sourceSecret.Namespace, name)
+
+			// Restore the version to all copies right away, instead of waiting for the controller
+			restoredSecret = restoredSecret.DeepCopy()
+			history.SetVersion(restoredSecret, toVersion)
+
+			var failed int
+
+			for i := range copies {
+				err = restoreCopy(ctx, writer, restoredSecret, &copies[i])
+				if err != nil {
+					fmt.Printf("Failed to restore Secret [%s/%s]: %v\n", copies[i].Namespace, copies[i].Name, err)
+					failed++
+					continue
+				}
+
+				fmt.Printf("Restored Secret [%s/%s]\n", copies[i].Namespace, copies[i].Name)
+			}
+
+			fmt.Printf("%d copies restored, %d failed\n", len(copies)-failed, failed)
+
+			if failed > 0 {
+				err = fmt.Errorf("failed to restore %d copies", failed)
+			}
+
+			return
+		},
+	}
+
+	c.Flags().Int("to-version", 0, "Version to restore, defaults to the version before the one the source Secret holds")
+	c.Flags().Bool("dry-run", false, "Only print the version that would be restored")
+
+	return c
+}
+
+// previousVersion returns the latest version kept before the version the source Secret holds
+func previousVersion(ctx context.Context, secretHistory *history.History, sourceSecret *corev1.Secret) (int, error) {
+	versions, err := secretHistory.Versions(ctx, sourceSecret.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	current := history.Version(sourceSecret)
+
+	previous := 0
+	for i := range versions {
+		version := history.Version(&versions[i])
+		if current != 0 && version >= current {
+			break
+		}
+
+		previous = version
+	}
+
+	if previous == 0 {
+		return 0, fmt.Errorf("no version of Secret [%s] was kept before version %d", sourceSecret.Name, current)
+	}
+
+	return previous, nil
+}
+
+// listCopies returns the copies of the source Secret with the given name
+func listCopies(ctx context.Context, c client.Client, secretSource backend.SecretSource, name string) ([]corev1.Secret, error) {
+	copiesMetadata, err := index.NewLookup(c).SecretCopiesOf(ctx, types.NamespacedName{Name: name})
+	if err != nil {
+		return nil, err
+	}
+
+	var copies []corev1.Secret
+
+	for _, targetSecretMetadata := range copiesMetadata {
+		// Skip the copies of Secrets referenced through annotations outside of the source
+		sourceNamespace, ok := targetSecretMetadata.Annotations[replica.SourceNamespaceAnnotation]
+		if ok && !secretSource.IsSourceNamespace(ctx, sourceNamespace) {
+			continue
+		}
+
+		targetSecretName := types.NamespacedName{
+			Namespace: targetSecretMetadata.Namespace,
+			Name:      targetSecretMetadata.Name,
+		}
+		targetSecret := corev1.Secret{}
+
+		err = c.Get(ctx, targetSecretName, &targetSecret)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch the copy [%s]: %v", targetSecretName, err)
+		}
+
+		copies = append(copies, targetSecret)
+	}
+
+	return copies, nil
+}
+
+// restoreCopy writes the data of the restored source Secret into the copy
+func restoreCopy(ctx context.Context, writer *replica.Writer, restoredSecret *corev1.Secret, targetSecret *corev1.Secret) error {
+	data, err := writer.Data(ctx, restoredSecret, targetSecret.Namespace)
+	if err != nil {
+		return err
+	}
+
+	return writer.Update(ctx, restoredSecret, targetSecret, data)
+}
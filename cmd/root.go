@@ -1,18 +1,38 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/health"
+	"tls-secret-injector/pkg/history"
+	"tls-secret-injector/pkg/index"
 	"tls-secret-injector/pkg/ingress"
+	"tls-secret-injector/pkg/namespace"
+	"tls-secret-injector/pkg/policy"
+	"tls-secret-injector/pkg/replica"
+	"tls-secret-injector/pkg/rollout"
 	"tls-secret-injector/pkg/secret"
+	"tls-secret-injector/pkg/secretcache"
+	"tls-secret-injector/pkg/status"
+	"tls-secret-injector/pkg/tracing"
+	"tls-secret-injector/pkg/workload"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -69,25 +89,86 @@ func bindFlags(flag *pflag.Flag) {
 }
 
 func getCommand() (c *cobra.Command) {
-	pflag.String("cert-dir", "", "Directory that holds the tls.crt and tls.key files")
-	pflag.String("leader-election-resource", "", "Resource name that the leader election will use for holding the leader lock")
-	pflag.String("leader-election-namespace", "", "Namespace in which the leader election resource will be created")
-	pflag.String("log-level", "warning", "Log verbosity level")
-	pflag.String("source-namespace", "", "Namespace containing the original TLS Secret from which we want to copy")
-	pflag.Parse()
-
-	if err := viper.BindPFlags(pflag.CommandLine); err != nil {
+	flags := pflag.NewFlagSet("tls-secret-injector", pflag.ContinueOnError)
+	flags.Duration("audit-interval", 10*time.Minute, "Interval between audits of the Secrets used by all Ingresses, repairing missing and stale copies, or 0 to disable the audits")
+	flags.String("cert-dir", "", "Directory that holds the tls.crt and tls.key files")
+	flags.String("conflict-policy", "skip", "What happens to existing Secrets not managed by the injector when policies do not decide: skip, adopt or overwrite")
+	flags.Int("history-limit", 0, "Number of versions of each source Secret kept in the history namespace for rollbacks, or 0 to disable the history")
+	flags.String("history-namespace", "", "Namespace holding the history of the source Secrets, defaults to the leader election namespace")
+	flags.String("leader-election-resource", "", "Resource name that the leader election will use for holding the leader lock")
+	flags.String("leader-election-namespace", "", "Namespace in which the leader election resource will be created")
+	flags.String("log-level", "warning", "Log verbosity level")
+	flags.Bool("owner-references", true, "Make Ingresses the owners of the Secrets copied for them, so copies are deleted together with the last Ingress using them")
+	flags.String("policy-file", "", "YAML file holding the policies that define how Secrets are copied into namespaces")
+	flags.String("provision-namespace-selector", "", "Label selector of the namespaces into which the provisioned Secrets are copied as soon as they are created")
+	flags.StringSlice("provision-secrets", nil, "Source Secrets copied into the namespaces matching the provision namespace selector")
+	flags.Duration("rollout-interval", 0, "Minimum interval between rollouts of the opted-in workloads using a changed copy, across all namespaces, or 0 to disable rollouts")
+	flags.Duration("rotation-batch-pause", time.Minute, "Time waited after each batch of copies updated for a rotated source Secret, used when rotations roll out in waves")
+	flags.Int("rotation-batch-size", 0, "Number of copies updated at once for a rotated source Secret, or 0 to update all copies of a wave at once")
+	flags.String("rotation-canary-namespace-selector", "", "Label selector of the canary namespaces whose copies are updated first for a rotated source Secret, halting the rollout when their Ingresses report errors")
+	flags.String("secret-cache", string(secretcache.ModeFull), "How Secrets are cached: full caches whole Secrets in all namespaces, metadata only caches whole Secrets in the source namespaces and the metadata of all others")
+	flags.StringSlice("secret-types", []string{string(corev1.SecretTypeTLS)}, "Types of the Secrets that are replicated: kubernetes.io/tls, kubernetes.io/dockerconfigjson for imagePullSecrets of ServiceAccounts and Opaque for CA bundles mounted by Pods")
+	flags.String("source-backend", "kubernetes", "Backend from which the original TLS Secrets are read: kubernetes, directory or vault")
+	flags.String("source-directory", "", "Directory holding one sub-directory of PEM files per Secret, used by the directory backend")
+	flags.String("source-kubeconfig", "", "Kubeconfig file of the remote cluster holding the source namespace, used by the kubernetes backend")
+	flags.String("source-kubeconfig-secret", "", "Secret as namespace/name whose kubeconfig key points at the remote cluster holding the source namespace, used by the kubernetes backend")
+	flags.StringSlice("source-namespace", nil, "Namespaces containing the original TLS Secrets from which we want to copy, in order of precedence")
+	flags.String("source-namespace-selector", "", "Label selector of additional namespaces containing original TLS Secrets, with lower precedence in alphabetical order")
+	flags.Duration("source-probe-interval", 30*time.Second, "Interval between connection checks to the remote cluster holding the source namespace")
+	flags.Bool("status-api", false, "Serve the authenticated status API under /status/ on the webhook server")
+	flags.Int("status-error-history", 100, "Number of recent errors listed by the status API")
+	flags.String("tracing-endpoint", "", "Host and port of the OTLP collector receiving spans over HTTP, defaults to the OTEL_EXPORTER_OTLP_ENDPOINT environment variable and then to localhost:4318")
+	flags.String("tracing-exporter", string(tracing.ExporterNone), "Where the spans of admission requests and reconciles are exported to: none, otlp or stdout")
+	flags.Bool("tracing-insecure", false, "Export spans to the OTLP collector over plain HTTP rather than HTTPS")
+	flags.Float64("tracing-sample-ratio", 1, "Share of the admission requests and reconciles that are traced, from 0 to 1")
+	flags.Int("update-concurrency", 10, "Number of copies of a changed source Secret updated in parallel")
+	flags.Float64("update-write-limit", 50, "Number of copies written per second across all changed source Secrets, or 0 for no limit")
+	flags.String("vault-address", "", "Address of the Vault compatible API, used by the vault backend")
+	flags.String("vault-mount", "secret", "Mount path of the key/value secrets engine, used by the vault backend")
+	flags.String("vault-path", "", "Path under the mount holding one entry per Secret, used by the vault backend")
+	flags.Duration("vault-poll-interval", time.Minute, "Interval between checks for new versions of the Secrets, used by the vault backend")
+	flags.String("vault-token-file", "", "File containing the token to authenticate against Vault, used by the vault backend")
+
+	// Viper reads the flags once cobra has parsed them
+	if err := viper.BindPFlags(flags); err != nil {
 		panic(err)
 	}
 
-	pflag.VisitAll(bindFlags)
+	flags.VisitAll(bindFlags)
 
-	return &cobra.Command{
+	c = &cobra.Command{
 		Use:   "tls-secret-injector",
 		Short: "Listen for Ingresses object created and patch them to have a valid certificate",
 		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			cacheMode, err := secretcache.ParseMode(viper.GetString("secret-cache"))
+			if err != nil {
+				return
+			}
+
+			tracingExporter, err := tracing.ParseExporter(viper.GetString("tracing-exporter"))
+			if err != nil {
+				return
+			}
+
+			// Setup the export of spans, flushing the spans left once the manager stops
+			shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+				Exporter:    tracingExporter,
+				Endpoint:    viper.GetString("tracing-endpoint"),
+				Insecure:    viper.GetBool("tracing-insecure"),
+				SampleRatio: viper.GetFloat64("tracing-sample-ratio"),
+			})
+			if err != nil {
+				return
+			}
+			defer func() {
+				shutdownErr := shutdownTracing(context.Background())
+				if shutdownErr != nil {
+					log.Errorf("could not flush the spans: %v", shutdownErr)
+				}
+			}()
+
 			// Setup the manager
-			mgr, err := manager.New(config.GetConfigOrDie(), manager.Options{
+			options := manager.Options{
 				Host:    "",
 				Port:    8443,
 				CertDir: viper.GetString("cert-dir"),
@@ -99,12 +180,26 @@ func getCommand() (c *cobra.Command) {
 				LeaderElectionID:           viper.GetString("leader-election-resource"),
 				LeaderElectionNamespace:    viper.GetString("leader-election-namespace"),
 				LeaderElectionResourceLock: resourcelock.LeasesResourceLock,
-			})
+			}
+			cacheMode.Apply(&options)
+
+			// Record the calls of the clients as spans, if tracing is enabled
+			if tracingExporter != tracing.ExporterNone {
+				options.NewClient = tracing.NewClient(options.NewClient)
+			}
+
+			mgr, err := manager.New(config.GetConfigOrDie(), options)
 			if err != nil {
 				err = fmt.Errorf("unable to set up overall controller manager: %v", err)
 				return
 			}
 
+			// Index Ingresses by the Secrets they use and copies by their source Secret
+			err = index.Register(context.Background(), mgr.GetFieldIndexer())
+			if err != nil {
+				return
+			}
+
 			// Add healthz and readyz check
 			err = mgr.AddHealthzCheck("ping", healthz.Ping)
 			if err != nil {
@@ -112,24 +207,127 @@ func getCommand() (c *cobra.Command) {
 				return
 			}
 
-			err = mgr.AddReadyzCheck("ping", healthz.Ping)
+			// Setup the backend from which the original Secrets are read
+			secretSource, err := newSecretSource(mgr, cacheMode)
+			if err != nil {
+				return
+			}
+
+			err = addReadyzChecks(mgr, secretSource)
+			if err != nil {
+				return
+			}
+			if tracingExporter != tracing.ExporterNone {
+				secretSource = tracing.SecretSource(viper.GetString("source-backend"), secretSource)
+			}
+
+			// Keep the history of the source Secrets, if enabled, marking the Secrets read from the source with their version
+			secretHistory, err := newSecretHistory(mgr, secretSource)
+			if err != nil {
+				return
+			}
+			if secretHistory != nil {
+				secretSource = secretHistory
+			}
+
+			// Setup the policies defining how Secrets are copied into namespaces
+			policies, err := newPolicyResolver(mgr)
 			if err != nil {
-				err = fmt.Errorf("failed to add ping readyz check")
 				return
 			}
 
 			// Setup a new controller to reconcile Ingresses
-			err = ingress.NewController(mgr, viper.GetString("source-namespace"))
+			err = ingress.NewController(mgr, secretSource, policies, viper.GetBool("owner-references"))
 			if err != nil {
 				return
 			}
 
 			// Setup a new controller to reconcile Secrets
-			err = secret.NewController(mgr, viper.GetString("source-namespace"))
+			secretTypes, err := newSecretTypes()
+			if err != nil {
+				return
+			}
+
+			waves, err := newWaves()
 			if err != nil {
 				return
 			}
 
+			secretTrigger := secret.NewTrigger()
+
+			err = secret.NewController(mgr, secretSource, policies, secretTypes, secret.FanOut{
+				Concurrency: viper.GetInt("update-concurrency"),
+				WriteLimit:  viper.GetFloat64("update-write-limit"),
+			}, waves, secretHistory, secretTrigger)
+			if err != nil {
+				return
+			}
+
+			// Audit the Secrets used by Ingresses on an interval, to repair the copies of missed events
+			if viper.GetDuration("audit-interval") > 0 {
+				auditor := ingress.NewAuditor(mgr.GetClient(), secretSource, policies, mgr.GetEventRecorderFor("tls-secret-injector"), viper.GetBool("owner-references"), secretTrigger.Resync, viper.GetDuration("audit-interval"))
+
+				err = mgr.Add(auditor)
+				if err != nil {
+					err = fmt.Errorf("unable to add the auditor to the manager: %v", err)
+					return
+				}
+
+				err = mgr.AddMetricsExtraHandler("/audit", auditor)
+				if err != nil {
+					err = fmt.Errorf("unable to serve the audit report: %v", err)
+					return
+				}
+			}
+
+			// Serve the status API next to the webhooks, if enabled
+			if viper.GetBool("status-api") {
+				errorLog := status.NewErrorLog(viper.GetInt("status-error-history"))
+				log.AddHook(errorLog)
+
+				writer := replica.NewWriter(mgr.GetClient(), secretSource, policies, mgr.GetEventRecorderFor("tls-secret-injector"))
+				status.NewServer(mgr.GetClient(), secretSource, writer, errorLog, secretTrigger.Resync, mgr.Elected()).Register(mgr.GetWebhookServer())
+			}
+
+			// Setup new controllers to copy the Secrets of other types for the resources referencing them
+			if secretTypes.Allows(corev1.SecretTypeDockerConfigJson) {
+				err = workload.NewServiceAccountController(mgr, secretSource, policies, secretTrigger.Resync)
+				if err != nil {
+					return
+				}
+			}
+
+			if secretTypes.Allows(corev1.SecretTypeOpaque) {
+				err = workload.NewPodController(mgr, secretSource, policies, secretTrigger.Resync)
+				if err != nil {
+					return
+				}
+			}
+
+			// Setup a new controller to provision Secrets into namespaces, if enabled
+			if viper.GetString("provision-namespace-selector") != "" {
+				var selector labels.Selector
+
+				selector, err = labels.Parse(viper.GetString("provision-namespace-selector"))
+				if err != nil {
+					err = fmt.Errorf("invalid provision namespace selector: %v", err)
+					return
+				}
+
+				err = namespace.NewController(mgr, secretSource, policies, selector, viper.GetStringSlice("provision-secrets"), secretTrigger.Resync)
+				if err != nil {
+					return
+				}
+			}
+
+			// Setup a new controller to roll out the workloads using changed copies, if enabled
+			if viper.GetDuration("rollout-interval") > 0 {
+				err = rollout.NewController(mgr, viper.GetDuration("rollout-interval"))
+				if err != nil {
+					return
+				}
+			}
+
 			// Start the controller manager
 			log.Infof("Starting controller manager")
 
@@ -142,4 +340,268 @@ func getCommand() (c *cobra.Command) {
 			return
 		},
 	}
+
+	// Share the flags with the sub-commands, whose own flags and help cobra handles
+	c.PersistentFlags().AddFlagSet(flags)
+
+	c.AddCommand(getAdoptCommand())
+	c.AddCommand(getRollbackCommand())
+
+	return
+}
+
+// addReadyzChecks adds the readiness checks, each of them also served on its own under /readyz/<name>
+func addReadyzChecks(mgr manager.Manager, secretSource backend.SecretSource) error {
+	webhookServer := mgr.GetWebhookServer()
+
+	checks := map[string]healthz.Checker{
+		"cache-sync": health.CacheSynced(mgr.GetCache(), mgr.GetScheme(), &networkingv1.Ingress{}, replica.NewSecretMetadata()),
+		"webhook":    health.WebhookCertificate(webhookServer.Host, webhookServer.Port),
+		"leader":     health.LeaderElection(mgr.Elected()),
+	}
+
+	// The Kubernetes backends read the source Secrets through informers of their own, unless the manager caches them whole
+	if kubernetesSource, ok := secretSource.(interface{ Informers() cache.Informers }); ok {
+		checks["source-cache-sync"] = health.CacheSynced(kubernetesSource.Informers(), mgr.GetScheme(), &corev1.Secret{})
+	}
+
+	// Remote source namespaces have their own check, probed in the background
+	if viper.GetString("source-backend") == "kubernetes" && viper.GetString("source-kubeconfig") == "" && viper.GetString("source-kubeconfig-secret") == "" {
+		selector, err := newSourceNamespaceSelector()
+		if err != nil {
+			return err
+		}
+
+		checks["source-namespace"] = health.NamespacesReadable(mgr.GetAPIReader(), viper.GetStringSlice("source-namespace"), selector)
+	}
+
+	for name, check := range checks {
+		err := mgr.AddReadyzCheck(name, check)
+		if err != nil {
+			return fmt.Errorf("failed to add %s readyz check: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+func newSecretSource(mgr manager.Manager, cacheMode secretcache.Mode) (backend.SecretSource, error) {
+	switch viper.GetString("source-backend") {
+	case "kubernetes":
+		if viper.GetString("source-kubeconfig") != "" || viper.GetString("source-kubeconfig-secret") != "" {
+			return newRemoteSecretSource(mgr)
+		}
+
+		selector, err := newSourceNamespaceSelector()
+		if err != nil {
+			return nil, err
+		}
+
+		if cacheMode != secretcache.ModeMetadata {
+			return backend.NewKubernetes(mgr.GetClient(), mgr.GetCache(), viper.GetStringSlice("source-namespace"), selector), nil
+		}
+
+		// The manager only caches the metadata of Secrets, so the whole source Secrets are cached by a cluster of their own
+		sourceCluster, err := secretcache.NewSourceCluster(mgr.GetConfig(), mgr.GetScheme(), viper.GetStringSlice("source-namespace"), selector)
+		if err != nil {
+			return nil, fmt.Errorf("unable to set up the source cluster: %v", err)
+		}
+
+		err = mgr.Add(sourceCluster)
+		if err != nil {
+			return nil, fmt.Errorf("unable to add the source cluster to the manager: %v", err)
+		}
+
+		return backend.NewKubernetes(sourceCluster.GetClient(), sourceCluster.GetCache(), viper.GetStringSlice("source-namespace"), selector), nil
+
+	case "directory":
+		return backend.NewDirectory(viper.GetString("source-directory")), nil
+
+	case "vault":
+		token, err := os.ReadFile(viper.GetString("vault-token-file"))
+		if err != nil {
+			return nil, fmt.Errorf("could not read the Vault token: %v", err)
+		}
+
+		return backend.NewVault(
+			viper.GetString("vault-address"),
+			viper.GetString("vault-mount"),
+			viper.GetString("vault-path"),
+			strings.TrimSpace(string(token)),
+			viper.GetDuration("vault-poll-interval"),
+		), nil
+
+	default:
+		return nil, fmt.Errorf("unknown source backend [%s]", viper.GetString("source-backend"))
+	}
+}
+
+func newRemoteSecretSource(mgr manager.Manager) (backend.SecretSource, error) {
+	kubeconfig, err := readRemoteKubeconfig(mgr)
+	if err != nil {
+		return nil, err
+	}
+
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig for the remote cluster: %v", err)
+	}
+
+	selector, err := newSourceNamespaceSelector()
+	if err != nil {
+		return nil, err
+	}
+
+	// Setup a cluster that only caches the source namespaces of the remote cluster, unless they are selected by label
+	remoteCluster, err := secretcache.NewSourceCluster(restConfig, mgr.GetScheme(), viper.GetStringSlice("source-namespace"), selector)
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up the remote cluster: %v", err)
+	}
+
+	err = mgr.Add(remoteCluster)
+	if err != nil {
+		return nil, fmt.Errorf("unable to add the remote cluster to the manager: %v", err)
+	}
+
+	// Probe the connection to the remote cluster and report it as part of the readiness
+	remote := backend.NewRemote(remoteCluster, viper.GetStringSlice("source-namespace"), selector, viper.GetDuration("source-probe-interval"))
+
+	err = mgr.Add(remote)
+	if err != nil {
+		return nil, fmt.Errorf("unable to add the remote cluster probe to the manager: %v", err)
+	}
+
+	err = mgr.AddReadyzCheck("remote-source", remote.Check)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add remote-source readyz check")
+	}
+
+	return remote, nil
+}
+
+func newSecretHistory(mgr manager.Manager, secretSource backend.SecretSource) (*history.History, error) {
+	if viper.GetInt("history-limit") <= 0 {
+		return nil, nil
+	}
+
+	namespace := viper.GetString("history-namespace")
+	if namespace == "" {
+		namespace = viper.GetString("leader-election-namespace")
+	}
+	if namespace == "" {
+		return nil, fmt.Errorf("the history requires a namespace, set with --history-namespace or --leader-election-namespace")
+	}
+
+	return history.NewHistory(mgr.GetClient(), secretSource, namespace, viper.GetInt("history-limit")), nil
+}
+
+func newPolicyResolver(mgr manager.Manager) (*policy.Resolver, error) {
+	var policies []policy.Policy
+
+	if viper.GetString("policy-file") != "" {
+		var err error
+
+		policies, err = policy.Load(viper.GetString("policy-file"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	defaults := policy.Policy{
+		Conflict: policy.ConflictPolicy(viper.GetString("conflict-policy")),
+	}
+
+	err := defaults.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid default policy: %v", err)
+	}
+
+	return policy.NewResolver(mgr.GetClient(), policies, defaults), nil
+}
+
+func newSecretTypes() (replica.Types, error) {
+	var secretTypes replica.Types
+
+	for _, secretType := range viper.GetStringSlice("secret-types") {
+		switch corev1.SecretType(secretType) {
+		case corev1.SecretTypeTLS, corev1.SecretTypeDockerConfigJson, corev1.SecretTypeOpaque:
+			secretTypes = append(secretTypes, corev1.SecretType(secretType))
+
+		default:
+			return nil, fmt.Errorf("unsupported Secret type [%s]", secretType)
+		}
+	}
+
+	return secretTypes, nil
+}
+
+func newWaves() (*secret.Waves, error) {
+	// Rotations are rolled out in waves only when canaries or batches are configured
+	if viper.GetString("rotation-canary-namespace-selector") == "" && viper.GetInt("rotation-batch-size") <= 0 {
+		return nil, nil
+	}
+
+	waves := &secret.Waves{
+		BatchSize: viper.GetInt("rotation-batch-size"),
+		Pause:     viper.GetDuration("rotation-batch-pause"),
+	}
+
+	if viper.GetString("rotation-canary-namespace-selector") != "" {
+		selector, err := labels.Parse(viper.GetString("rotation-canary-namespace-selector"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid rotation canary namespace selector: %v", err)
+		}
+
+		waves.CanarySelector = selector
+	}
+
+	return waves, nil
+}
+
+func newSourceNamespaceSelector() (labels.Selector, error) {
+	if viper.GetString("source-namespace-selector") == "" {
+		return nil, nil
+	}
+
+	selector, err := labels.Parse(viper.GetString("source-namespace-selector"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid source namespace selector: %v", err)
+	}
+
+	return selector, nil
+}
+
+func readRemoteKubeconfig(mgr manager.Manager) ([]byte, error) {
+	if viper.GetString("source-kubeconfig") != "" {
+		kubeconfig, err := os.ReadFile(viper.GetString("source-kubeconfig"))
+		if err != nil {
+			return nil, fmt.Errorf("could not read the remote kubeconfig: %v", err)
+		}
+
+		return kubeconfig, nil
+	}
+
+	parts := strings.SplitN(viper.GetString("source-kubeconfig-secret"), "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid remote kubeconfig Secret [%s], expected namespace/name", viper.GetString("source-kubeconfig-secret"))
+	}
+
+	// The cache is not running yet, so read the Secret directly from the API server
+	kubeconfigSecretName := types.NamespacedName{
+		Namespace: parts[0],
+		Name:      parts[1],
+	}
+	kubeconfigSecret := &corev1.Secret{}
+
+	err := mgr.GetAPIReader().Get(context.Background(), kubeconfigSecretName, kubeconfigSecret)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch the remote kubeconfig Secret [%s]: %v", kubeconfigSecretName, err)
+	}
+
+	kubeconfig, ok := kubeconfigSecret.Data["kubeconfig"]
+	if !ok {
+		return nil, fmt.Errorf("remote kubeconfig Secret [%s] has no kubeconfig key", kubeconfigSecretName)
+	}
+
+	return kubeconfig, nil
 }
@@ -0,0 +1,72 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SecretSource is the place from which the original Secrets are read before being copied
+type SecretSource interface {
+	// Get returns the source Secret with the given name, or a NotFound error when it does not exist
+	Get(ctx context.Context, name string) (*corev1.Secret, error)
+
+	// List returns all source Secrets, leaving out those that another Secret with the same name takes precedence over
+	List(ctx context.Context) ([]corev1.Secret, error)
+
+	// IsSourceNamespace returns whether the given namespace holds source Secrets
+	IsSourceNamespace(ctx context.Context, namespace string) bool
+
+	// Watch blocks until the context is done, calling notify with the key of every source Secret that has changed
+	Watch(ctx context.Context, notify func(name types.NamespacedName)) error
+}
+
+// GetReferenced returns the Secret referenced through annotations, reading it from the source when its namespace holds
+// source Secrets, and from the cluster otherwise
+func GetReferenced(ctx context.Context, secretSource SecretSource, reader client.Reader, name types.NamespacedName) (*corev1.Secret, error) {
+	if !secretSource.IsSourceNamespace(ctx, name.Namespace) {
+		secret := &corev1.Secret{}
+
+		err := reader.Get(ctx, name, secret)
+		if err != nil {
+			return nil, err
+		}
+
+		return secret, nil
+	}
+
+	secret, err := secretSource.Get(ctx, name.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only copy the referenced Secret, not the one of a source namespace taking precedence over it
+	if secret.Namespace != name.Namespace {
+		return nil, fmt.Errorf("source Secret [%s] is shadowed by Secret [%s/%s]", name, secret.Namespace, secret.Name)
+	}
+
+	return secret, nil
+}
+
+// secretType returns the type of a Secret read from outside of Kubernetes, inferred from the keys of its data
+func secretType(data map[string][]byte) corev1.SecretType {
+	if _, ok := data[corev1.DockerConfigJsonKey]; ok {
+		return corev1.SecretTypeDockerConfigJson
+	}
+
+	_, hasCertificate := data[corev1.TLSCertKey]
+	_, hasPrivateKey := data[corev1.TLSPrivateKeyKey]
+	if hasCertificate && hasPrivateKey {
+		return corev1.SecretTypeTLS
+	}
+
+	return corev1.SecretTypeOpaque
+}
+
+func newNotFound(name string) error {
+	return errors.NewNotFound(corev1.Resource("secrets"), name)
+}
@@ -0,0 +1,89 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetReferenced(t *testing.T) {
+	fakeClient := fake.NewClientBuilder().WithObjects(
+		newSecret("shared", "tls-shared-io", "shared certificate"),
+		newSecret("team-a", "tls-shared-io", "team certificate"),
+		newSecret("team-b", "tls-team-io", "team b certificate"),
+	).Build()
+
+	kubernetes := NewKubernetes(fakeClient, nil, []string{"shared", "team-a"}, nil)
+
+	tests := map[string]struct {
+		name        types.NamespacedName
+		certificate string
+		err         string
+	}{
+		"Secret in a source namespace is read from the source": {
+			name:        types.NamespacedName{Namespace: "shared", Name: "tls-shared-io"},
+			certificate: "shared certificate",
+		},
+		"shadowed Secret in a source namespace is not copied": {
+			name: types.NamespacedName{Namespace: "team-a", Name: "tls-shared-io"},
+			err:  "source Secret [team-a/tls-shared-io] is shadowed by Secret [shared/tls-shared-io]",
+		},
+		"Secret outside of the source is read from the cluster": {
+			name:        types.NamespacedName{Namespace: "team-b", Name: "tls-team-io"},
+			certificate: "team b certificate",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			secret, err := GetReferenced(context.TODO(), kubernetes, fakeClient, test.name)
+
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.certificate, string(secret.Data[corev1.TLSCertKey]))
+		})
+	}
+}
+
+func TestSecretType(t *testing.T) {
+	tests := map[string]struct {
+		keys       []string
+		secretType corev1.SecretType
+	}{
+		"certificate and private key": {
+			keys:       []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey},
+			secretType: corev1.SecretTypeTLS,
+		},
+		"Docker config": {
+			keys:       []string{corev1.DockerConfigJsonKey},
+			secretType: corev1.SecretTypeDockerConfigJson,
+		},
+		"CA bundle": {
+			keys:       []string{"ca.crt"},
+			secretType: corev1.SecretTypeOpaque,
+		},
+		"certificate without private key": {
+			keys:       []string{corev1.TLSCertKey},
+			secretType: corev1.SecretTypeOpaque,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			data := map[string][]byte{}
+			for _, key := range test.keys {
+				data[key] = []byte("value")
+			}
+
+			assert.Equal(t, test.secretType, secretType(data))
+		})
+	}
+}
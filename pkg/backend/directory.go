@@ -0,0 +1,169 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Directory reads the source Secrets from PEM files on the local filesystem, where every sub-directory is a Secret
+// and every file within it is a key of that Secret, e.g. <path>/tls-example-io/tls.crt
+type Directory struct {
+	path string
+}
+
+// NewDirectory returns a pointer to Directory
+func NewDirectory(path string) *Directory {
+	return &Directory{
+		path: path,
+	}
+}
+
+func (d *Directory) Get(_ context.Context, name string) (*corev1.Secret, error) {
+	secretPath := filepath.Join(d.path, name)
+
+	entries, err := os.ReadDir(secretPath)
+	if os.IsNotExist(err) {
+		return nil, newNotFound(name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read directory [%s]: %v", secretPath, err)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Data: map[string][]byte{},
+	}
+
+	for _, entry := range entries {
+		// Skip hidden files, including the "..data" links created when the directory is a mounted volume
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		filePath := filepath.Join(secretPath, entry.Name())
+
+		// Follow symbolic links to find out if this is a regular file
+		info, err := os.Stat(filePath)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		secret.Data[entry.Name()], err = os.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("could not read file [%s]: %v", filePath, err)
+		}
+	}
+
+	secret.Type = secretType(secret.Data)
+
+	return secret, nil
+}
+
+func (d *Directory) List(ctx context.Context) ([]corev1.Secret, error) {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read directory [%s]: %v", d.path, err)
+	}
+
+	var secrets []corev1.Secret
+
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		secret, err := d.Get(ctx, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		secrets = append(secrets, *secret)
+	}
+
+	return secrets, nil
+}
+
+func (d *Directory) IsSourceNamespace(_ context.Context, namespace string) bool {
+	// Secrets read from the filesystem do not live in any namespace
+	return namespace == ""
+}
+
+func (d *Directory) Watch(ctx context.Context, notify func(name types.NamespacedName)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("unable to create the filesystem watcher: %v", err)
+	}
+	defer watcher.Close()
+
+	// Watch the top directory for new Secrets and every existing Secret directory for changes
+	err = watcher.Add(d.path)
+	if err != nil {
+		return fmt.Errorf("unable to watch directory [%s]: %v", d.path, err)
+	}
+
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return fmt.Errorf("could not read directory [%s]: %v", d.path, err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			d.watchSecret(watcher, entry.Name())
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case err := <-watcher.Errors:
+			log.Errorf("error while watching directory [%s]: %v", d.path, err)
+
+		case fsEvent := <-watcher.Events:
+			relativePath, err := filepath.Rel(d.path, fsEvent.Name)
+			if err != nil || relativePath == "." {
+				continue
+			}
+
+			name := strings.Split(relativePath, string(filepath.Separator))[0]
+			if strings.HasPrefix(name, ".") {
+				continue
+			}
+
+			// Start watching Secret directories as soon as they are created
+			if filepath.Dir(fsEvent.Name) == filepath.Clean(d.path) && fsEvent.Op&fsnotify.Create != 0 {
+				d.watchSecret(watcher, name)
+			}
+
+			log.Debugf("Detected change of Secret [%s] in directory [%s]", name, d.path)
+			notify(types.NamespacedName{Name: name})
+		}
+	}
+}
+
+func (d *Directory) watchSecret(watcher *fsnotify.Watcher, name string) {
+	secretPath := filepath.Join(d.path, name)
+
+	info, err := os.Stat(secretPath)
+	if err != nil || !info.IsDir() {
+		return
+	}
+
+	err = watcher.Add(secretPath)
+	if err != nil {
+		log.Errorf("unable to watch directory [%s]: %v", secretPath, err)
+	}
+}
@@ -0,0 +1,39 @@
+package backend
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestDirectoryGet(t *testing.T) {
+	path := t.TempDir()
+
+	secretPath := filepath.Join(path, "tls-example-io")
+	_ = os.Mkdir(secretPath, 0755)
+	_ = os.WriteFile(filepath.Join(secretPath, corev1.TLSCertKey), []byte("certificate"), 0644)
+	_ = os.WriteFile(filepath.Join(secretPath, corev1.TLSPrivateKeyKey), []byte("private key"), 0600)
+	_ = os.WriteFile(filepath.Join(secretPath, ".hidden"), []byte("hidden"), 0644)
+
+	directory := NewDirectory(path)
+
+	// Read an existing Secret
+	secret, err := directory.Get(context.TODO(), "tls-example-io")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "tls-example-io", secret.Name)
+	assert.Equal(t, corev1.SecretTypeTLS, secret.Type)
+	assert.Len(t, secret.Data, 2)
+	assert.Equal(t, "certificate", string(secret.Data[corev1.TLSCertKey]))
+	assert.Equal(t, "private key", string(secret.Data[corev1.TLSPrivateKeyKey]))
+
+	// Read a missing Secret
+	_, err = directory.Get(context.TODO(), "tls-missing-io")
+
+	assert.True(t, errors.IsNotFound(err))
+}
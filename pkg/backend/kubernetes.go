@@ -0,0 +1,223 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var sourceConflicts = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "tls_secret_injector_source_conflicts",
+	Help: "Number of lower precedence source namespaces holding a different certificate under the same Secret name",
+}, []string{"name"})
+
+func init() {
+	metrics.Registry.MustRegister(sourceConflicts)
+}
+
+// Kubernetes reads the source Secrets from namespaces of a Kubernetes cluster. When several namespaces hold a Secret
+// with the same name the first one wins, in the order in which the namespaces were given followed by the namespaces
+// matching the selector in alphabetical order.
+type Kubernetes struct {
+	client     client.Client
+	informers  cache.Informers
+	namespaces []string
+	selector   labels.Selector
+}
+
+// NewKubernetes returns a pointer to Kubernetes, where a nil selector matches no namespaces
+func NewKubernetes(client client.Client, informers cache.Informers, namespaces []string, selector labels.Selector) *Kubernetes {
+	return &Kubernetes{
+		client:     client,
+		informers:  informers,
+		namespaces: namespaces,
+		selector:   selector,
+	}
+}
+
+func (k *Kubernetes) Get(ctx context.Context, name string) (*corev1.Secret, error) {
+	sourceNamespaces, err := k.sourceNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var sourceSecret *corev1.Secret
+	var conflicts []string
+
+	for _, namespace := range sourceNamespaces {
+		secretName := types.NamespacedName{
+			Namespace: namespace,
+			Name:      name,
+		}
+		secret := &corev1.Secret{}
+
+		err = k.client.Get(ctx, secretName, secret)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if sourceSecret == nil {
+			sourceSecret = secret
+			continue
+		}
+
+		// Report lower precedence Secrets holding a different certificate, as they are silently ignored otherwise
+		if !bytes.Equal(sourceSecret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSCertKey]) {
+			conflicts = append(conflicts, namespace)
+		}
+	}
+
+	sourceConflicts.WithLabelValues(name).Set(float64(len(conflicts)))
+
+	if sourceSecret == nil {
+		return nil, newNotFound(name)
+	}
+
+	if len(conflicts) > 0 {
+		log.Warnf(
+			"Source Secret [%s/%s] takes precedence over Secrets with the same name but a different certificate in namespaces %s",
+			sourceSecret.Namespace,
+			name,
+			conflicts,
+		)
+	}
+
+	return sourceSecret, nil
+}
+
+func (k *Kubernetes) List(ctx context.Context) ([]corev1.Secret, error) {
+	sourceNamespaces, err := k.sourceNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var sourceSecrets []corev1.Secret
+	seen := map[string]bool{}
+
+	for _, namespace := range sourceNamespaces {
+		secretList := &corev1.SecretList{}
+
+		err = k.client.List(ctx, secretList, client.InNamespace(namespace))
+		if err != nil {
+			return nil, fmt.Errorf("could not list Secrets in source namespace [%s]: %v", namespace, err)
+		}
+
+		for _, secret := range secretList.Items {
+			if seen[secret.Name] {
+				continue
+			}
+
+			seen[secret.Name] = true
+			sourceSecrets = append(sourceSecrets, secret)
+		}
+	}
+
+	return sourceSecrets, nil
+}
+
+func (k *Kubernetes) IsSourceNamespace(ctx context.Context, namespace string) bool {
+	if contains(k.namespaces, namespace) {
+		return true
+	}
+
+	if k.selector == nil {
+		return false
+	}
+
+	sourceNamespace := &corev1.Namespace{}
+
+	err := k.client.Get(ctx, types.NamespacedName{Name: namespace}, sourceNamespace)
+	if err != nil {
+		return false
+	}
+
+	return k.selector.Matches(labels.Set(sourceNamespace.Labels))
+}
+
+// Informers returns the informers the source Secrets are read from
+func (k *Kubernetes) Informers() cache.Informers {
+	return k.informers
+}
+
+func (k *Kubernetes) Watch(ctx context.Context, notify func(name types.NamespacedName)) error {
+	informer, err := k.informers.GetInformer(ctx, &corev1.Secret{})
+	if err != nil {
+		return fmt.Errorf("unable to get the Secret informer: %v", err)
+	}
+
+	notifySecret := func(object interface{}) {
+		secret, ok := object.(*corev1.Secret)
+		if !ok || !k.IsSourceNamespace(ctx, secret.Namespace) {
+			return
+		}
+
+		notify(types.NamespacedName{
+			Namespace: secret.Namespace,
+			Name:      secret.Name,
+		})
+	}
+
+	// Notify about created Secrets as well, as they can push copies into namespaces through annotations
+	informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: notifySecret,
+		UpdateFunc: func(_, newObject interface{}) {
+			notifySecret(newObject)
+		},
+	})
+
+	<-ctx.Done()
+
+	return nil
+}
+
+// sourceNamespaces returns the source namespaces ordered by precedence
+func (k *Kubernetes) sourceNamespaces(ctx context.Context) ([]string, error) {
+	sourceNamespaces := append([]string{}, k.namespaces...)
+
+	if k.selector == nil {
+		return sourceNamespaces, nil
+	}
+
+	namespaceList := &corev1.NamespaceList{}
+
+	err := k.client.List(ctx, namespaceList, client.MatchingLabelsSelector{Selector: k.selector})
+	if err != nil {
+		return nil, fmt.Errorf("could not list the source namespaces: %v", err)
+	}
+
+	var selectedNamespaces []string
+	for _, namespace := range namespaceList.Items {
+		if !contains(sourceNamespaces, namespace.Name) {
+			selectedNamespaces = append(selectedNamespaces, namespace.Name)
+		}
+	}
+	sort.Strings(selectedNamespaces)
+
+	return append(sourceNamespaces, selectedNamespaces...), nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
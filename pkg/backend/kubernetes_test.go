@@ -0,0 +1,96 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestKubernetesGet(t *testing.T) {
+	objects := []client.Object{
+		newNamespace("team-a", map[string]string{"certificates": "team"}),
+		newNamespace("team-b", map[string]string{"certificates": "team"}),
+		newNamespace("unrelated", nil),
+		newSecret("shared", "tls-shared-io", "shared certificate"),
+		newSecret("team-a", "tls-shared-io", "team certificate"),
+		newSecret("team-a", "tls-team-io", "team a certificate"),
+		newSecret("team-b", "tls-team-io", "team b certificate"),
+		newSecret("unrelated", "tls-unrelated-io", "unrelated certificate"),
+	}
+
+	tests := map[string]struct {
+		name      string
+		namespace string
+		conflicts float64
+	}{
+		"listed namespace takes precedence over selected namespaces": {
+			name:      "tls-shared-io",
+			namespace: "shared",
+			conflicts: 1,
+		},
+		"selected namespaces take precedence in alphabetical order": {
+			name:      "tls-team-io",
+			namespace: "team-a",
+			conflicts: 1,
+		},
+		"namespaces that are not selected are ignored": {
+			name: "tls-unrelated-io",
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().WithObjects(objects...).Build()
+	selector, _ := labels.Parse("certificates=team")
+
+	kubernetes := NewKubernetes(fakeClient, nil, []string{"shared"}, selector)
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			secret, err := kubernetes.Get(context.TODO(), test.name)
+
+			assert.Equal(t, test.conflicts, testutil.ToFloat64(sourceConflicts.WithLabelValues(test.name)))
+
+			if test.namespace == "" {
+				assert.True(t, errors.IsNotFound(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.namespace, secret.Namespace)
+		})
+	}
+
+	assert.True(t, kubernetes.IsSourceNamespace(context.TODO(), "shared"))
+	assert.True(t, kubernetes.IsSourceNamespace(context.TODO(), "team-b"))
+	assert.False(t, kubernetes.IsSourceNamespace(context.TODO(), "unrelated"))
+}
+
+func newNamespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+	}
+}
+
+func newSecret(namespace, name, certificate string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       []byte(certificate),
+			corev1.TLSPrivateKeyKey: []byte("private key"),
+		},
+	}
+}
@@ -0,0 +1,125 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	remoteUp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "tls_secret_injector_remote_source_up",
+		Help: "Whether the last attempt to reach the remote cluster holding the source Secrets succeeded",
+	})
+	remoteLastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "tls_secret_injector_remote_source_last_success_seconds",
+		Help: "Seconds since the last successful probe of the remote cluster holding the source Secrets, as of the last probe",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(remoteUp, remoteLastSuccess)
+}
+
+// Remote reads the source Secrets from a namespace of another Kubernetes cluster, while probing the connection to it
+type Remote struct {
+	*Kubernetes
+
+	reader        client.Reader
+	probeInterval time.Duration
+
+	mutex       sync.Mutex
+	lastContact time.Time
+	lastError   error
+}
+
+// NewRemote returns a pointer to Remote
+func NewRemote(remoteCluster cluster.Cluster, namespaces []string, selector labels.Selector, probeInterval time.Duration) *Remote {
+	return &Remote{
+		Kubernetes:    NewKubernetes(remoteCluster.GetClient(), remoteCluster.GetCache(), namespaces, selector),
+		reader:        remoteCluster.GetAPIReader(),
+		probeInterval: probeInterval,
+	}
+}
+
+// Start probes the remote cluster until the context is done
+func (r *Remote) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.probeInterval)
+	defer ticker.Stop()
+
+	for {
+		r.probe(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection makes sure that followers also probe the remote cluster, as they report it in their readiness
+func (r *Remote) NeedLeaderElection() bool {
+	return false
+}
+
+// Check reports an error when the remote cluster could not be reached for the last few probes
+func (r *Remote) Check(_ *http.Request) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.lastContact.IsZero() {
+		return fmt.Errorf("remote cluster has not been reached yet: %v", r.lastError)
+	}
+
+	sinceContact := time.Since(r.lastContact)
+	if sinceContact > 3*r.probeInterval {
+		return fmt.Errorf("remote cluster has not been reached for %s: %v", sinceContact.Round(time.Second), r.lastError)
+	}
+
+	return nil
+}
+
+func (r *Remote) probe(ctx context.Context) {
+	secretMetadataList := &metav1.PartialObjectMetadataList{}
+	secretMetadataList.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "",
+		Version: "v1",
+		Kind:    "SecretList",
+	})
+
+	listOptions := []client.ListOption{client.Limit(1)}
+	if len(r.namespaces) > 0 {
+		listOptions = append(listOptions, client.InNamespace(r.namespaces[0]))
+	}
+
+	err := r.reader.List(ctx, secretMetadataList, listOptions...)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.lastError = err
+	if err != nil {
+		log.Errorf("could not reach the remote cluster holding the source Secrets: %v", err)
+		remoteUp.Set(0)
+	} else {
+		r.lastContact = time.Now()
+		remoteUp.Set(1)
+	}
+
+	if !r.lastContact.IsZero() {
+		remoteLastSuccess.Set(time.Since(r.lastContact).Seconds())
+	}
+}
@@ -0,0 +1,40 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoteCheck(t *testing.T) {
+	tests := map[string]struct {
+		lastContact time.Time
+		healthy     bool
+	}{
+		"never reached": {
+			healthy: false,
+		},
+		"recently reached": {
+			lastContact: time.Now().Add(-time.Minute),
+			healthy:     true,
+		},
+		"not reached for too long": {
+			lastContact: time.Now().Add(-time.Hour),
+			healthy:     false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			remote := &Remote{
+				probeInterval: time.Minute,
+				lastContact:   test.lastContact,
+			}
+
+			err := remote.Check(nil)
+
+			assert.Equal(t, test.healthy, err == nil)
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Vault reads the source Secrets over HTTP from a Vault compatible key/value (version 2) API, where every entry under
+// the configured path is a Secret, e.g. <address>/v1/<mount>/data/<path>/tls-example-io
+type Vault struct {
+	address      string
+	mount        string
+	path         string
+	token        string
+	pollInterval time.Duration
+	httpClient   *http.Client
+
+	// Versions of the Secrets seen so far, which the watcher polls for changes
+	mutex    sync.Mutex
+	versions map[string]int
+}
+
+type vaultListResponse struct {
+	Data struct {
+		Keys []string `json:"keys"`
+	} `json:"data"`
+}
+
+type vaultResponse struct {
+	Data struct {
+		Data     map[string]string `json:"data"`
+		Metadata struct {
+			Version int `json:"version"`
+		} `json:"metadata"`
+	} `json:"data"`
+}
+
+// NewVault returns a pointer to Vault
+func NewVault(address, mount, path, token string, pollInterval time.Duration) *Vault {
+	return &Vault{
+		address:      strings.TrimSuffix(address, "/"),
+		mount:        mount,
+		path:         path,
+		token:        token,
+		pollInterval: pollInterval,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		versions: map[string]int{},
+	}
+}
+
+func (v *Vault) Get(ctx context.Context, name string) (*corev1.Secret, error) {
+	response, err := v.read(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	v.mutex.Lock()
+	v.versions[name] = response.Data.Metadata.Version
+	v.mutex.Unlock()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			ResourceVersion: strconv.Itoa(response.Data.Metadata.Version),
+		},
+		Data: map[string][]byte{},
+	}
+
+	for key, value := range response.Data.Data {
+		secret.Data[key] = []byte(value)
+	}
+
+	secret.Type = secretType(secret.Data)
+
+	return secret, nil
+}
+
+func (v *Vault) List(ctx context.Context) ([]corev1.Secret, error) {
+	listURL := v.address + "/v1/" + path.Join(v.mount, "metadata", v.path)
+
+	response := &vaultListResponse{}
+
+	err := v.do(ctx, "LIST", listURL, response)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var secrets []corev1.Secret
+
+	for _, name := range response.Data.Keys {
+		// Keys ending with a slash are nested paths rather than Secrets
+		if strings.HasSuffix(name, "/") {
+			continue
+		}
+
+		secret, err := v.Get(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		secrets = append(secrets, *secret)
+	}
+
+	return secrets, nil
+}
+
+func (v *Vault) IsSourceNamespace(_ context.Context, namespace string) bool {
+	// Secrets read from Vault do not live in any namespace
+	return namespace == ""
+}
+
+func (v *Vault) Watch(ctx context.Context, notify func(name types.NamespacedName)) error {
+	ticker := time.NewTicker(v.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-ticker.C:
+			// Only the Secrets that have been requested before can have copies that need to be updated
+			v.mutex.Lock()
+			versions := make(map[string]int, len(v.versions))
+			for name, version := range v.versions {
+				versions[name] = version
+			}
+			v.mutex.Unlock()
+
+			for name, version := range versions {
+				response, err := v.read(ctx, name)
+				if err != nil {
+					log.Errorf("could not poll Secret [%s] from Vault: %v", name, err)
+					continue
+				}
+
+				if response.Data.Metadata.Version == version {
+					continue
+				}
+
+				log.Debugf("Detected change of Secret [%s] in Vault from version %d to %d", name, version, response.Data.Metadata.Version)
+				notify(types.NamespacedName{Name: name})
+			}
+		}
+	}
+}
+
+func (v *Vault) read(ctx context.Context, name string) (*vaultResponse, error) {
+	secretURL := v.address + "/v1/" + path.Join(v.mount, "data", v.path, url.PathEscape(name))
+
+	response := &vaultResponse{}
+
+	err := v.do(ctx, http.MethodGet, secretURL, response)
+	if errors.IsNotFound(err) {
+		return nil, newNotFound(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (v *Vault) do(ctx context.Context, method, requestURL string, response interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, method, requestURL, nil)
+	if err != nil {
+		return fmt.Errorf("could not create request for [%s]: %v", requestURL, err)
+	}
+	request.Header.Set("X-Vault-Token", v.token)
+
+	httpResponse, err := v.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("could not fetch [%s]: %v", requestURL, err)
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode == http.StatusNotFound {
+		return newNotFound(requestURL)
+	}
+	if httpResponse.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d while fetching [%s]", httpResponse.StatusCode, requestURL)
+	}
+
+	err = json.NewDecoder(httpResponse.Body).Decode(response)
+	if err != nil {
+		return fmt.Errorf("could not decode response from [%s]: %v", requestURL, err)
+	}
+
+	return nil
+}
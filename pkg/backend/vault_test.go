@@ -0,0 +1,47 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestVaultGet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Header.Get("X-Vault-Token") != "token" {
+			writer.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if request.URL.Path != "/v1/secret/data/certificates/tls-example-io" {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = writer.Write([]byte(`{"data":{"data":{"tls.crt":"certificate","tls.key":"private key"},"metadata":{"version":3}}}`))
+	}))
+	defer server.Close()
+
+	vault := NewVault(server.URL, "secret", "certificates", "token", time.Minute)
+
+	// Read an existing Secret
+	secret, err := vault.Get(context.TODO(), "tls-example-io")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "tls-example-io", secret.Name)
+	assert.Equal(t, "3", secret.ResourceVersion)
+	assert.Equal(t, corev1.SecretTypeTLS, secret.Type)
+	assert.Equal(t, "certificate", string(secret.Data[corev1.TLSCertKey]))
+	assert.Equal(t, "private key", string(secret.Data[corev1.TLSPrivateKeyKey]))
+
+	// Read a missing Secret
+	_, err = vault.Get(context.TODO(), "tls-missing-io")
+
+	assert.True(t, errors.IsNotFound(err))
+}
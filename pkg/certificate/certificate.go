@@ -0,0 +1,52 @@
+package certificate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+)
+
+// Fingerprint returns the SHA-256 fingerprint of the first certificate found in the PEM data, or an empty string when
+// there is none
+func Fingerprint(pemData []byte) string {
+	for {
+		var block *pem.Block
+
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			return ""
+		}
+
+		if block.Type == "CERTIFICATE" {
+			sum := sha256.Sum256(block.Bytes)
+			return hex.EncodeToString(sum[:])
+		}
+	}
+}
+
+// ChainFingerprint returns the SHA-256 fingerprint of all certificates found in the PEM data in their order, or an
+// empty string when there is none
+func ChainFingerprint(pemData []byte) string {
+	hash := sha256.New()
+	found := false
+
+	for {
+		var block *pem.Block
+
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			break
+		}
+
+		if block.Type == "CERTIFICATE" {
+			hash.Write(block.Bytes)
+			found = true
+		}
+	}
+
+	if !found {
+		return ""
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
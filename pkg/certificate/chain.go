@@ -0,0 +1,52 @@
+package certificate
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+)
+
+// AssembleChain returns the certificates of the leaf PEM data followed by the intermediates it does not hold yet,
+// after verifying that every certificate of the chain is signed by the next one
+func AssembleChain(leafPEM []byte, intermediatesPEM []byte) ([]byte, error) {
+	chain, err := ParseCertificates(leafPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid leaf certificate: %v", err)
+	}
+
+	intermediates, err := ParseCertificates(intermediatesPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid intermediate certificates: %v", err)
+	}
+
+	for _, intermediate := range intermediates {
+		found := false
+		for _, certificate := range chain {
+			if bytes.Equal(certificate.Raw, intermediate.Raw) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			chain = append(chain, intermediate)
+		}
+	}
+
+	for i := 0; i < len(chain)-1; i++ {
+		err = chain[i].CheckSignatureFrom(chain[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("certificate [%s] is not signed by the next certificate [%s] of the chain: %v", chain[i].Subject, chain[i+1].Subject, err)
+		}
+	}
+
+	var chainPEM bytes.Buffer
+	for _, certificate := range chain {
+		err = pem.Encode(&chainPEM, &pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return chainPEM.Bytes(), nil
+}
@@ -0,0 +1,95 @@
+package certificate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssembleChain(t *testing.T) {
+	root, rootKey := newSignedCertificate(t, "root", nil, nil)
+	intermediate, intermediateKey := newSignedCertificate(t, "intermediate", root, rootKey)
+	leaf, _ := newSignedCertificate(t, "example.io", intermediate, intermediateKey)
+
+	tests := map[string]struct {
+		leaf          []*x509.Certificate
+		intermediates []*x509.Certificate
+		chain         []*x509.Certificate
+	}{
+		"append intermediates": {
+			leaf:          []*x509.Certificate{leaf},
+			intermediates: []*x509.Certificate{intermediate, root},
+			chain:         []*x509.Certificate{leaf, intermediate, root},
+		},
+		"skip intermediates already in chain": {
+			leaf:          []*x509.Certificate{leaf, intermediate},
+			intermediates: []*x509.Certificate{intermediate},
+			chain:         []*x509.Certificate{leaf, intermediate},
+		},
+		"fail on wrong order": {
+			leaf:          []*x509.Certificate{leaf},
+			intermediates: []*x509.Certificate{root, intermediate},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			chain, err := AssembleChain(encodeCertificates(test.leaf...), encodeCertificates(test.intermediates...))
+
+			if test.chain == nil {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, encodeCertificates(test.chain...), chain)
+			assert.Equal(t, Fingerprint(encodeCertificates(leaf)), Fingerprint(chain))
+			assert.NotEqual(t, ChainFingerprint(encodeCertificates(leaf)), ChainFingerprint(chain))
+		})
+	}
+}
+
+// newSignedCertificate returns a CA certificate signed by the parent, or self-signed without parent, and its key
+func newSignedCertificate(t *testing.T, commonName string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	if parent == nil {
+		parent, parentKey = template, privateKey
+	}
+
+	certificateDER, err := x509.CreateCertificate(rand.Reader, template, parent, &privateKey.PublicKey, parentKey)
+	assert.NoError(t, err)
+
+	certificate, err := x509.ParseCertificate(certificateDER)
+	assert.NoError(t, err)
+
+	return certificate, privateKey
+}
+
+func encodeCertificates(certificates ...*x509.Certificate) []byte {
+	var pemData []byte
+	for _, certificate := range certificates {
+		pemData = append(pemData, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})...)
+	}
+
+	return pemData
+}
@@ -0,0 +1,160 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// KeystoreAlias is the alias of the private key entry in the generated keystores
+const KeystoreAlias = "certificate"
+
+// ParseCertificates returns all certificates found in the PEM data
+func ParseCertificates(pemData []byte) ([]*x509.Certificate, error) {
+	var certificates []*x509.Certificate
+
+	for {
+		var block *pem.Block
+
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate: %v", err)
+		}
+
+		certificates = append(certificates, certificate)
+	}
+
+	if len(certificates) == 0 {
+		return nil, fmt.Errorf("no certificate found")
+	}
+
+	return certificates, nil
+}
+
+// ParsePrivateKey returns the first private key found in the PEM data, in PKCS #1, PKCS #8 or SEC 1 form
+func ParsePrivateKey(pemData []byte) (crypto.PrivateKey, error) {
+	for {
+		var block *pem.Block
+
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			return nil, fmt.Errorf("no private key found")
+		}
+
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			return x509.ParsePKCS1PrivateKey(block.Bytes)
+
+		case "EC PRIVATE KEY":
+			return x509.ParseECPrivateKey(block.Bytes)
+
+		case "PRIVATE KEY":
+			privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+
+			switch privateKey.(type) {
+			case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+				return privateKey, nil
+			default:
+				return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+			}
+		}
+	}
+}
+
+// EncodePKCS12 returns a PKCS #12 keystore holding the private key with its certificate chain
+func EncodePKCS12(privateKey crypto.PrivateKey, certificates []*x509.Certificate, password string) ([]byte, error) {
+	return pkcs12.Encode(rand.Reader, privateKey, certificates[0], certificates[1:], password)
+}
+
+// EncodePKCS12TrustStore returns a PKCS #12 truststore holding the certificates
+func EncodePKCS12TrustStore(certificates []*x509.Certificate, password string) ([]byte, error) {
+	return pkcs12.EncodeTrustStore(rand.Reader, certificates, password)
+}
+
+// EncodeJKS returns a Java keystore holding the private key with its certificate chain
+func EncodeJKS(privateKey crypto.PrivateKey, certificates []*x509.Certificate, password string) ([]byte, error) {
+	privateKeyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := keystore.PrivateKeyEntry{
+		CreationTime:     time.Now(),
+		PrivateKey:       privateKeyDER,
+		CertificateChain: jksCertificates(certificates),
+	}
+
+	jks := keystore.New(keystore.WithOrderedAliases())
+
+	err = jks.SetPrivateKeyEntry(KeystoreAlias, entry, []byte(password))
+	if err != nil {
+		return nil, err
+	}
+
+	return storeJKS(jks, password)
+}
+
+// EncodeJKSTrustStore returns a Java keystore holding the certificates as trusted entries
+func EncodeJKSTrustStore(certificates []*x509.Certificate, password string) ([]byte, error) {
+	jks := keystore.New(keystore.WithOrderedAliases())
+
+	for i, certificate := range jksCertificates(certificates) {
+		entry := keystore.TrustedCertificateEntry{
+			CreationTime: time.Now(),
+			Certificate:  certificate,
+		}
+
+		err := jks.SetTrustedCertificateEntry(fmt.Sprintf("ca-%d", i), entry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return storeJKS(jks, password)
+}
+
+func jksCertificates(certificates []*x509.Certificate) []keystore.Certificate {
+	jksCertificates := make([]keystore.Certificate, 0, len(certificates))
+
+	for _, certificate := range certificates {
+		jksCertificates = append(jksCertificates, keystore.Certificate{
+			Type:    "X509",
+			Content: certificate.Raw,
+		})
+	}
+
+	return jksCertificates
+}
+
+func storeJKS(jks keystore.KeyStore, password string) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	err := jks.Store(&buffer, []byte(password))
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,93 @@
+package certificate
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+	"github.com/stretchr/testify/assert"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestEncodeKeystores(t *testing.T) {
+	certificatePEM, privateKeyPEM := newCertificate(t)
+
+	certificates, err := ParseCertificates(certificatePEM)
+	assert.NoError(t, err)
+	assert.Len(t, certificates, 1)
+
+	privateKey, err := ParsePrivateKey(privateKeyPEM)
+	assert.NoError(t, err)
+
+	// Check the PKCS #12 keystore and truststore
+	pkcs12Keystore, err := EncodePKCS12(privateKey, certificates, "changeit")
+	assert.NoError(t, err)
+
+	decodedPrivateKey, decodedCertificate, err := pkcs12.Decode(pkcs12Keystore, "changeit")
+	assert.NoError(t, err)
+	assert.Equal(t, privateKey, decodedPrivateKey)
+	assert.Equal(t, certificates[0].Raw, decodedCertificate.Raw)
+
+	pkcs12Truststore, err := EncodePKCS12TrustStore(certificates, "changeit")
+	assert.NoError(t, err)
+
+	trustedCertificates, err := pkcs12.DecodeTrustStore(pkcs12Truststore, "changeit")
+	assert.NoError(t, err)
+	assert.Len(t, trustedCertificates, 1)
+
+	// Check the JKS keystore and truststore
+	jksKeystore, err := EncodeJKS(privateKey, certificates, "changeit")
+	assert.NoError(t, err)
+
+	jks := keystore.New()
+	assert.NoError(t, jks.Load(bytes.NewReader(jksKeystore), []byte("changeit")))
+
+	entry, err := jks.GetPrivateKeyEntry(KeystoreAlias, []byte("changeit"))
+	assert.NoError(t, err)
+	assert.Equal(t, certificates[0].Raw, entry.CertificateChain[0].Content)
+
+	jksTruststore, err := EncodeJKSTrustStore(certificates, "changeit")
+	assert.NoError(t, err)
+
+	jks = keystore.New()
+	assert.NoError(t, jks.Load(bytes.NewReader(jksTruststore), []byte("changeit")))
+	assert.True(t, jks.IsTrustedCertificateEntry("ca-0"))
+}
+
+func TestParse(t *testing.T) {
+	_, err := ParseCertificates([]byte("certificate"))
+	assert.Error(t, err)
+
+	_, err = ParsePrivateKey([]byte("private key"))
+	assert.Error(t, err)
+}
+
+// newCertificate returns a self-signed certificate and its private key in PEM form
+func newCertificate(t *testing.T) ([]byte, []byte) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.io"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	certificateDER, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	assert.NoError(t, err)
+
+	privateKeyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	assert.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificateDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyDER})
+}
@@ -0,0 +1,42 @@
+package certificate
+
+import (
+	"crypto"
+	"fmt"
+	"time"
+)
+
+// Verify returns an error when the leaf certificate found in the PEM data is not valid at the given time, or does not
+// belong to the private key
+func Verify(certificatePEM []byte, privateKeyPEM []byte, now time.Time) error {
+	certificates, err := ParseCertificates(certificatePEM)
+	if err != nil {
+		return err
+	}
+
+	leaf := certificates[0]
+
+	if now.Before(leaf.NotBefore) {
+		return fmt.Errorf("certificate [%s] is not valid before %s", leaf.Subject.CommonName, leaf.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if now.After(leaf.NotAfter) {
+		return fmt.Errorf("certificate [%s] expired at %s", leaf.Subject.CommonName, leaf.NotAfter.UTC().Format(time.RFC3339))
+	}
+
+	privateKey, err := ParsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return fmt.Errorf("invalid private key: %v", err)
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+
+	publicKey, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !publicKey.Equal(leaf.PublicKey) {
+		return fmt.Errorf("certificate [%s] does not match the private key", leaf.Subject.CommonName)
+	}
+
+	return nil
+}
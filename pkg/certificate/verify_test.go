@@ -0,0 +1,49 @@
+package certificate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	certificatePEM, privateKeyPEM := newCertificate(t)
+	_, otherPrivateKeyPEM := newCertificate(t)
+
+	tests := map[string]struct {
+		privateKey []byte
+		now        time.Time
+		valid      bool
+	}{
+		"accept matching key": {
+			privateKey: privateKeyPEM,
+			now:        time.Now(),
+			valid:      true,
+		},
+		"reject expired certificate": {
+			privateKey: privateKeyPEM,
+			now:        time.Now().Add(2 * time.Hour),
+		},
+		"reject certificate not valid yet": {
+			privateKey: privateKeyPEM,
+			now:        time.Now().Add(-time.Hour),
+		},
+		"reject mismatched key": {
+			privateKey: otherPrivateKeyPEM,
+			now:        time.Now(),
+		},
+		"reject invalid key": {
+			privateKey: []byte("private key"),
+			now:        time.Now(),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := Verify(certificatePEM, test.privateKey, test.now)
+
+			assert.Equal(t, test.valid, err == nil)
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// checkTimeout bounds how long a single check may take, well below the timeout of the readiness probe
+const checkTimeout = 2 * time.Second
+
+var leader = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "tls_secret_injector_leader",
+	Help: "Whether this replica is the elected leader running the controllers",
+})
+
+func init() {
+	metrics.Registry.MustRegister(leader)
+}
+
+// CacheSynced reports an error until the informers of all objects have synced
+func CacheSynced(informers cache.Informers, scheme *runtime.Scheme, objects ...client.Object) healthz.Checker {
+	return func(request *http.Request) error {
+		for _, object := range objects {
+			gvk, err := apiutil.GVKForObject(object, scheme)
+			if err != nil {
+				return err
+			}
+
+			// Getting an informer waits for it to sync, which the timeout cuts short
+			ctx, cancel := context.WithTimeout(request.Context(), checkTimeout)
+			informer, err := informers.GetInformer(ctx, object)
+			cancel()
+
+			if err != nil {
+				return fmt.Errorf("cache of %s has not synced: %v", gvk.Kind, err)
+			}
+			if !informer.HasSynced() {
+				return fmt.Errorf("cache of %s has not synced yet", gvk.Kind)
+			}
+		}
+
+		return nil
+	}
+}
+
+// NamespacesReadable reports an error when the Secrets of any of the namespaces, or of the namespaces matching the
+// selector, cannot be listed, or when no namespace is given nor matches the selector
+func NamespacesReadable(reader client.Reader, namespaces []string, selector labels.Selector) healthz.Checker {
+	return func(request *http.Request) error {
+		ctx, cancel := context.WithTimeout(request.Context(), checkTimeout)
+		defer cancel()
+
+		namespaces := append([]string{}, namespaces...)
+
+		if selector != nil {
+			namespaceList := &corev1.NamespaceList{}
+
+			err := reader.List(ctx, namespaceList, client.MatchingLabelsSelector{Selector: selector})
+			if err != nil {
+				return fmt.Errorf("could not list the namespaces matching [%s]: %v", selector, err)
+			}
+
+			for _, namespace := range namespaceList.Items {
+				namespaces = append(namespaces, namespace.Name)
+			}
+		}
+
+		if len(namespaces) == 0 {
+			return fmt.Errorf("no source namespace is given nor matches [%s]", selector)
+		}
+
+		for _, namespace := range namespaces {
+			secretMetadataList := &metav1.PartialObjectMetadataList{}
+			secretMetadataList.SetGroupVersionKind(schema.GroupVersionKind{
+				Group:   "",
+				Version: "v1",
+				Kind:    "SecretList",
+			})
+
+			err := reader.List(ctx, secretMetadataList, client.InNamespace(namespace), client.Limit(1))
+			if err != nil {
+				return fmt.Errorf("could not list the Secrets of namespace [%s]: %v", namespace, err)
+			}
+		}
+
+		return nil
+	}
+}
+
+// WebhookCertificate reports an error when the webhook server cannot be reached over TLS, or serves a certificate
+// that is not valid now
+func WebhookCertificate(host string, port int) healthz.Checker {
+	config := &tls.Config{
+		// Only the validity period is checked, the clients verify the certificate against the CA bundle of the webhook
+		InsecureSkipVerify: true, // nolint:gosec
+	}
+
+	return func(_ *http.Request) error {
+		dialer := &net.Dialer{Timeout: checkTimeout}
+
+		conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, strconv.Itoa(port)), config)
+		if err != nil {
+			return fmt.Errorf("webhook server is not reachable: %v", err)
+		}
+		defer conn.Close()
+
+		certificates := conn.ConnectionState().PeerCertificates
+		if len(certificates) == 0 {
+			return fmt.Errorf("webhook server does not serve a certificate")
+		}
+
+		now := time.Now()
+		if now.Before(certificates[0].NotBefore) || now.After(certificates[0].NotAfter) {
+			return fmt.Errorf("webhook server certificate is only valid from %s to %s", certificates[0].NotBefore, certificates[0].NotAfter)
+		}
+
+		return nil
+	}
+}
+
+// LeaderElection reports whether this replica is the leader in the tls_secret_injector_leader metric, without ever
+// failing, as followers are ready to serve the webhook
+func LeaderElection(elected <-chan struct{}) healthz.Checker {
+	return func(_ *http.Request) error {
+		select {
+		case <-elected:
+			leader.Set(1)
+		default:
+			leader.Set(0)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,109 @@
+package health
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCacheSynced(t *testing.T) {
+	synced := false
+	informers := &informertest.FakeInformers{Synced: &synced}
+
+	check := CacheSynced(informers, scheme.Scheme, &networkingv1.Ingress{}, &corev1.Secret{})
+	request := httptest.NewRequest(http.MethodGet, "/readyz/cache-sync", nil)
+
+	// Check that the caches are only reported once synced
+	assert.EqualError(t, check(request), "cache of Ingress has not synced yet")
+
+	for _, gvk := range []schema.GroupVersionKind{networkingv1.SchemeGroupVersion.WithKind("Ingress"), corev1.SchemeGroupVersion.WithKind("Secret")} {
+		informer, err := informers.FakeInformerForKind(context.TODO(), gvk)
+		assert.NoError(t, err)
+
+		informer.Synced = true
+	}
+
+	assert.NoError(t, check(request))
+}
+
+func TestNamespacesReadable(t *testing.T) {
+	sourceNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "source",
+			Labels: map[string]string{"tls-secret-source": "true"},
+		},
+	}
+
+	tests := map[string]struct {
+		namespaces  []string
+		selector    labels.Selector
+		expectError bool
+	}{
+		"source namespace": {
+			namespaces: []string{"source"},
+		},
+		"namespace matching the selector": {
+			selector: labels.SelectorFromSet(labels.Set{"tls-secret-source": "true"}),
+		},
+		"no namespace matching the selector": {
+			selector:    labels.SelectorFromSet(labels.Set{"tls-secret-source": "false"}),
+			expectError: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			check := NamespacesReadable(fake.NewClientBuilder().WithObjects(sourceNamespace).Build(), test.namespaces, test.selector)
+
+			err := check(httptest.NewRequest(http.MethodGet, "/readyz/source-namespace", nil))
+			assert.Equal(t, test.expectError, err != nil, err)
+		})
+	}
+}
+
+func TestWebhookCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	request := httptest.NewRequest(http.MethodGet, "/readyz/webhook", nil)
+
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	assert.NoError(t, err)
+
+	portNumber, err := strconv.Atoi(port)
+	assert.NoError(t, err)
+
+	// Check the certificate of a serving webhook
+	check := WebhookCertificate(host, portNumber)
+	assert.NoError(t, check(request))
+
+	// Check that a webhook that is gone is reported
+	server.Close()
+	assert.Error(t, check(request))
+}
+
+func TestLeaderElection(t *testing.T) {
+	elected := make(chan struct{})
+	check := LeaderElection(elected)
+	request := httptest.NewRequest(http.MethodGet, "/readyz/leader", nil)
+
+	// Followers are ready as well
+	assert.NoError(t, check(request))
+	assert.Equal(t, float64(0), testutil.ToFloat64(leader))
+
+	close(elected)
+	assert.NoError(t, check(request))
+	assert.Equal(t, float64(1), testutil.ToFloat64(leader))
+}
@@ -0,0 +1,233 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/replica"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SourceLabel holds the name of the source Secret a history Secret keeps a version of, and marks the history Secrets
+// apart from the copies
+const SourceLabel = "tls-secret-injector/history-of"
+
+// History keeps the last versions of the source Secrets as Secrets in its own namespace, and marks the source Secrets
+// it returns with the version they hold
+type History struct {
+	backend.SecretSource
+
+	client    client.Client
+	namespace string
+	limit     int
+
+	// recorded holds the version last recorded for each source Secret, which spares listing the history on every Get
+	recorded     map[string]recordedVersion
+	recordedLock sync.Mutex
+}
+
+// recordedVersion is a version of a source Secret along with the hash of its data
+type recordedVersion struct {
+	dataHash string
+	version  int
+}
+
+// NewHistory returns a pointer to History
+func NewHistory(client client.Client, secretSource backend.SecretSource, namespace string, limit int) *History {
+	return &History{
+		SecretSource: secretSource,
+		client:       client,
+		namespace:    namespace,
+		limit:        limit,
+		recorded:     map[string]recordedVersion{},
+	}
+}
+
+// Get returns the source Secret with the given name, annotated with the version it holds when it was last recorded
+func (h *History) Get(ctx context.Context, name string) (*corev1.Secret, error) {
+	sourceSecret, err := h.SecretSource.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	h.recordedLock.Lock()
+	recorded, ok := h.recorded[name]
+	h.recordedLock.Unlock()
+
+	if ok && recorded.dataHash == replica.DataHash(sourceSecret.Data) {
+		sourceSecret = sourceSecret.DeepCopy()
+		SetVersion(sourceSecret, recorded.version)
+	}
+
+	return sourceSecret, nil
+}
+
+// Resolve annotates the source Secret with the version of the history holding its data, if any
+func (h *History) Resolve(ctx context.Context, sourceSecret *corev1.Secret) error {
+	versions, err := h.Versions(ctx, sourceSecret.Name)
+	if err != nil {
+		return err
+	}
+
+	dataHash := replica.DataHash(sourceSecret.Data)
+	for i := range versions {
+		if versions[i].Annotations[replica.DataHashAnnotation] == dataHash {
+			SetVersion(sourceSecret, Version(&versions[i]))
+			break
+		}
+	}
+
+	return nil
+}
+
+// Versions returns the versions kept of the source Secret with the given name, from the oldest to the latest
+func (h *History) Versions(ctx context.Context, name string) ([]corev1.Secret, error) {
+	secretList := &corev1.SecretList{}
+
+	err := h.client.List(ctx, secretList, client.InNamespace(h.namespace), client.MatchingLabels{SourceLabel: name})
+	if err != nil {
+		return nil, fmt.Errorf("could not list the history of Secret [%s]: %v", name, err)
+	}
+
+	versions := secretList.Items
+	sort.Slice(versions, func(i, j int) bool {
+		return Version(&versions[i]) < Version(&versions[j])
+	})
+
+	return versions, nil
+}
+
+// Version returns the version held by a history Secret or by a source Secret returned by the history, or 0 when unknown
+func Version(object metav1.Object) int {
+	version, err := strconv.Atoi(object.GetAnnotations()[replica.VersionAnnotation])
+	if err != nil {
+		return 0
+	}
+
+	return version
+}
+
+// Record keeps the data of the source Secret as a new version, unless a kept version already holds it, annotates the
+// source Secret with its version and deletes the versions beyond the limit
+func (h *History) Record(ctx context.Context, sourceSecret *corev1.Secret) error {
+	versions, err := h.Versions(ctx, sourceSecret.Name)
+	if err != nil {
+		return err
+	}
+
+	// History Secrets kept before they had their own labels were taken for copies
+	for i := range versions {
+		if replica.IsManaged(&versions[i]) {
+			err = h.unmarkManaged(ctx, &versions[i])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	dataHash := replica.DataHash(sourceSecret.Data)
+
+	latest := 0
+	for i := range versions {
+		if versions[i].Annotations[replica.DataHashAnnotation] == dataHash {
+			h.setRecorded(sourceSecret, dataHash, Version(&versions[i]))
+			return nil
+		}
+
+		latest = Version(&versions[i])
+	}
+
+	// Keep the data as the next version
+	version := latest + 1
+
+	historySecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: h.namespace,
+			Name:      fmt.Sprintf("%s-v%d", sourceSecret.Name, version),
+			Labels:    map[string]string{SourceLabel: sourceSecret.Name},
+			Annotations: map[string]string{
+				replica.SourceNamespaceAnnotation: sourceSecret.Namespace,
+				replica.DataHashAnnotation:        dataHash,
+				replica.VersionAnnotation:         strconv.Itoa(version),
+			},
+		},
+		Type: sourceSecret.Type,
+		Data: sourceSecret.Data,
+	}
+
+	err = h.client.Create(ctx, historySecret)
+	if err != nil {
+		return fmt.Errorf("could not create the history Secret [%s/%s]: %v", historySecret.Namespace, historySecret.Name, err)
+	}
+
+	log.Infof("Recorded version %d of Secret [%s/%s]", version, sourceSecret.Namespace, sourceSecret.Name)
+
+	h.setRecorded(sourceSecret, dataHash, version)
+
+	// Delete the oldest versions beyond the limit
+	versions = append(versions, *historySecret)
+
+	for i := 0; i < len(versions)-h.limit; i++ {
+		err = h.client.Delete(ctx, &versions[i])
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("could not delete the history Secret [%s/%s]: %v", versions[i].Namespace, versions[i].Name, err)
+		}
+
+		log.Debugf("Deleted version %d of Secret [%s/%s]", Version(&versions[i]), sourceSecret.Namespace, sourceSecret.Name)
+	}
+
+	return nil
+}
+
+// setRecorded annotates the source Secret with the version holding its data, and remembers it for Get
+func (h *History) setRecorded(sourceSecret *corev1.Secret, dataHash string, version int) {
+	SetVersion(sourceSecret, version)
+
+	h.recordedLock.Lock()
+	h.recorded[sourceSecret.Name] = recordedVersion{dataHash: dataHash, version: version}
+	h.recordedLock.Unlock()
+}
+
+// unmarkManaged removes the labels of the copies from the history Secret
+func (h *History) unmarkManaged(ctx context.Context, historySecret *corev1.Secret) error {
+	delete(historySecret.Labels, replica.NameLabel)
+
+	err := h.client.Update(ctx, historySecret)
+	if err != nil {
+		return fmt.Errorf("could not update the labels of the history Secret [%s/%s]: %v", historySecret.Namespace, historySecret.Name, err)
+	}
+
+	return nil
+}
+
+// Find returns the history Secret holding the given version of the source Secret, or a NotFound error
+func (h *History) Find(ctx context.Context, name string, version int) (*corev1.Secret, error) {
+	historySecret := &corev1.Secret{}
+
+	err := h.client.Get(ctx, types.NamespacedName{Namespace: h.namespace, Name: fmt.Sprintf("%s-v%d", name, version)}, historySecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return historySecret, nil
+}
+
+// SetVersion annotates the source Secret with the version of the history it holds
+func SetVersion(sourceSecret *corev1.Secret, version int) {
+	if sourceSecret.Annotations == nil {
+		sourceSecret.Annotations = map[string]string{}
+	}
+
+	sourceSecret.Annotations[replica.VersionAnnotation] = strconv.Itoa(version)
+}
@@ -0,0 +1,157 @@
+package history
+
+import (
+	"context"
+	"testing"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/replica"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRecord(t *testing.T) {
+	sourceSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "tls-example-io",
+		},
+		Type: corev1.SecretTypeTLS,
+	}
+
+	// Create a client and the history keeping two versions
+	fakeClient := fake.NewClientBuilder().WithObjects(sourceSecret).Build()
+	secretHistory := NewHistory(fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), "injector", 2)
+
+	// Record three rotations, and the first data again
+	for i, certificate := range []string{"first", "second", "third", "third"} {
+		sourceSecret.Data = map[string][]byte{corev1.TLSCertKey: []byte(certificate)}
+
+		err := secretHistory.Record(context.TODO(), sourceSecret)
+		assert.NoError(t, err)
+
+		expectedVersion := i + 1
+		if i == 3 {
+			expectedVersion = 3
+		}
+
+		assert.Equal(t, expectedVersion, Version(sourceSecret))
+	}
+
+	// Verify that only the last two versions are kept
+	versions, err := secretHistory.Versions(context.TODO(), sourceSecret.Name)
+	assert.NoError(t, err)
+
+	if assert.Len(t, versions, 2) {
+		assert.Equal(t, "tls-example-io-v2", versions[0].Name)
+		assert.Equal(t, "second", string(versions[0].Data[corev1.TLSCertKey]))
+		assert.Equal(t, "tls-example-io-v3", versions[1].Name)
+		assert.Equal(t, "third", string(versions[1].Data[corev1.TLSCertKey]))
+	}
+
+	// Verify that the history Secrets are not taken for copies of the source Secret
+	copyList := &corev1.SecretList{}
+	err = fakeClient.List(context.TODO(), copyList, client.MatchingLabels(replica.Labels(sourceSecret.Name)))
+
+	assert.NoError(t, err)
+	assert.Empty(t, copyList.Items)
+
+	for i := range versions {
+		assert.False(t, replica.IsManaged(&versions[i]), versions[i].Name)
+	}
+}
+
+func TestRecordLegacyLabels(t *testing.T) {
+	sourceSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "tls-example-io",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{corev1.TLSCertKey: []byte("certificate")},
+	}
+
+	// Create a history Secret labelled like the copies, as kept by earlier releases
+	labels := replica.ManagedLabels()
+	labels[SourceLabel] = sourceSecret.Name
+
+	legacySecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "injector",
+			Name:      "tls-example-io-v1",
+			Labels:    labels,
+			Annotations: map[string]string{
+				replica.DataHashAnnotation: replica.DataHash(sourceSecret.Data),
+				replica.VersionAnnotation:  "1",
+			},
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().WithObjects(sourceSecret, legacySecret).Build()
+	secretHistory := NewHistory(fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), "injector", 2)
+
+	err := secretHistory.Record(context.TODO(), sourceSecret)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, Version(sourceSecret))
+
+	// Verify that the history Secret is no longer taken for a copy
+	historySecret, err := secretHistory.Find(context.TODO(), sourceSecret.Name, 1)
+
+	assert.NoError(t, err)
+	assert.False(t, replica.IsManaged(historySecret))
+	assert.Equal(t, sourceSecret.Name, historySecret.Labels[SourceLabel])
+}
+
+func TestGet(t *testing.T) {
+	sourceSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "tls-example-io",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{corev1.TLSCertKey: []byte("certificate")},
+	}
+
+	fakeClient := fake.NewClientBuilder().WithObjects(sourceSecret).Build()
+	secretHistory := NewHistory(fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), "injector", 2)
+
+	// Source Secrets not kept in the history have no version
+	fetchedSecret, err := secretHistory.Get(context.TODO(), sourceSecret.Name)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, Version(fetchedSecret))
+
+	// Source Secrets kept in the history are annotated with their version
+	err = secretHistory.Record(context.TODO(), sourceSecret.DeepCopy())
+	assert.NoError(t, err)
+
+	fetchedSecret, err = secretHistory.Get(context.TODO(), sourceSecret.Name)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, Version(fetchedSecret))
+
+	// A restarted history only knows the version once it is recorded again, or resolved from the history Secrets
+	restartedHistory := NewHistory(fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), "injector", 2)
+
+	restartedSecret, err := restartedHistory.Get(context.TODO(), sourceSecret.Name)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, Version(restartedSecret))
+
+	restartedSecret = restartedSecret.DeepCopy()
+
+	err = restartedHistory.Resolve(context.TODO(), restartedSecret)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, Version(restartedSecret))
+
+	// Versions annotated on the source Secret are written into its copies
+	targetSecret := &corev1.Secret{}
+	replica.Annotate(targetSecret, fetchedSecret, fetchedSecret.Data)
+
+	assert.Equal(t, "1", targetSecret.Annotations[replica.VersionAnnotation])
+}
@@ -0,0 +1,94 @@
+package index
+
+import (
+	"context"
+	"fmt"
+
+	"tls-secret-injector/pkg/replica"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// IngressSecretNameField indexes Ingresses by the secretName of their TLS entries
+	IngressSecretNameField = "spec.tls.secretName"
+	// CopySourceField indexes copies by the name of their source Secret, and by its namespace/name when recorded
+	CopySourceField = "metadata.source"
+)
+
+// Register adds the indexes to the cache, before any informer of the indexed objects is started
+func Register(ctx context.Context, indexer client.FieldIndexer) error {
+	err := indexer.IndexField(ctx, &networkingv1.Ingress{}, IngressSecretNameField, ingressSecretNames)
+	if err != nil {
+		return fmt.Errorf("unable to index Ingresses by secretName: %v", err)
+	}
+
+	for _, copyType := range []client.Object{replica.NewSecretMetadata(), newMetadata("ConfigMap")} {
+		err = indexer.IndexField(ctx, copyType, CopySourceField, copySources)
+		if err != nil {
+			return fmt.Errorf("unable to index copies by source: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func ingressSecretNames(object client.Object) []string {
+	ingress, ok := object.(*networkingv1.Ingress)
+	if !ok {
+		return nil
+	}
+
+	var secretNames []string
+	for _, ingressTLS := range ingress.Spec.TLS {
+		if ingressTLS.SecretName != "" {
+			secretNames = append(secretNames, ingressTLS.SecretName)
+		}
+	}
+
+	return secretNames
+}
+
+func copySources(object client.Object) []string {
+	sourceName, ok := object.GetLabels()[replica.SourceNameLabel]
+	if !ok || !replica.IsManaged(object) {
+		return nil
+	}
+
+	sources := []string{sourceName}
+
+	// Sources outside of Kubernetes, and copies made before their provenance was recorded, only have a name
+	if sourceNamespace := object.GetAnnotations()[replica.SourceNamespaceAnnotation]; sourceNamespace != "" {
+		sources = append(sources, sourceKey(types.NamespacedName{Namespace: sourceNamespace, Name: sourceName}))
+	}
+
+	return sources
+}
+
+// sourceKey returns the value under which copies of the source Secret are indexed, its name alone matching the copies
+// of every source Secret with that name
+func sourceKey(source types.NamespacedName) string {
+	if source.Namespace == "" {
+		return source.Name
+	}
+
+	return source.String()
+}
+
+func newMetadata(kind string) *metav1.PartialObjectMetadata {
+	metadata := &metav1.PartialObjectMetadata{}
+	metadata.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind(kind))
+
+	return metadata
+}
+
+func newMetadataList(kind string) *metav1.PartialObjectMetadataList {
+	metadataList := &metav1.PartialObjectMetadataList{}
+	metadataList.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind(kind + "List"))
+
+	return metadataList
+}
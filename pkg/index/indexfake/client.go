@@ -0,0 +1,134 @@
+// Package indexfake adds field indexes to the fake client, which ignores field selectors and only filters by labels,
+// so that lists by indexed fields return what a cache holding the indexes returns
+package indexfake
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Client filters the lists of the wrapped client by the field indexes registered on it
+type Client struct {
+	client.Client
+
+	// indexes holds the functions extracting the indexed values by kind and field
+	indexes map[string]map[string]client.IndexerFunc
+}
+
+// NewClient returns a pointer to Client, wrapping the client and adding the indexes through register. It panics when
+// the indexes cannot be added, like the fake client does when it cannot hold its objects.
+func NewClient(wrapped client.Client, register func(ctx context.Context, indexer client.FieldIndexer) error) *Client {
+	c := &Client{
+		Client:  wrapped,
+		indexes: map[string]map[string]client.IndexerFunc{},
+	}
+
+	err := register(context.Background(), c)
+	if err != nil {
+		panic(fmt.Errorf("failed to add the indexes: %v", err))
+	}
+
+	return c
+}
+
+// IndexField registers the function extracting the values of the field from the objects of the kind
+func (c *Client) IndexField(_ context.Context, object client.Object, field string, extractValue client.IndexerFunc) error {
+	gvk, err := apiutil.GVKForObject(object, c.Scheme())
+	if err != nil {
+		return err
+	}
+
+	if c.indexes[gvk.Kind] == nil {
+		c.indexes[gvk.Kind] = map[string]client.IndexerFunc{}
+	}
+	c.indexes[gvk.Kind][field] = extractValue
+
+	return nil
+}
+
+// List lists the objects of the wrapped client, keeping only those whose indexed values match the field selector. The
+// kinds without indexes are listed by the wrapped client alone, as the API server would filter them.
+func (c *Client) List(ctx context.Context, list client.ObjectList, options ...client.ListOption) error {
+	listOptions := &client.ListOptions{}
+	listOptions.ApplyOptions(options)
+
+	gvk, err := apiutil.GVKForObject(list, c.Scheme())
+	if err != nil {
+		return err
+	}
+	kind := strings.TrimSuffix(gvk.Kind, "List")
+
+	fieldSelector := listOptions.FieldSelector
+	if fieldSelector == nil || fieldSelector.Empty() || c.indexes[kind] == nil {
+		return c.Client.List(ctx, list, options...)
+	}
+
+	listOptions.FieldSelector = nil
+
+	err = c.Client.List(ctx, list, listOptions)
+	if err != nil {
+		return err
+	}
+
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+
+	var matching []runtime.Object
+
+	for _, item := range items {
+		object, ok := item.(client.Object)
+		if !ok {
+			return fmt.Errorf("could not filter %T by fields", item)
+		}
+
+		matches, err := c.matches(kind, object, fieldSelector.Requirements())
+		if err != nil {
+			return err
+		}
+		if matches {
+			matching = append(matching, item)
+		}
+	}
+
+	return meta.SetList(list, matching)
+}
+
+// matches returns whether the indexed values of the object match all requirements, which need exact values like the
+// requirements on indexes of a cache
+func (c *Client) matches(kind string, object client.Object, requirements []fields.Requirement) (bool, error) {
+	for _, requirement := range requirements {
+		extractValue, ok := c.indexes[kind][requirement.Field]
+		if !ok {
+			return false, fmt.Errorf("field [%s] of %s is not indexed", requirement.Field, kind)
+		}
+		if requirement.Operator != selection.Equals && requirement.Operator != selection.DoubleEquals {
+			return false, fmt.Errorf("field [%s] of %s can only be matched exactly", requirement.Field, kind)
+		}
+
+		if !contains(extractValue(object), requirement.Value) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
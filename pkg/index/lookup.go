@@ -0,0 +1,85 @@
+package index
+
+import (
+	"context"
+	"fmt"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Lookup answers which copies were made from a source Secret and which Ingresses use them, through the indexes of
+// the cache
+type Lookup struct {
+	reader client.Reader
+}
+
+// NewLookup returns a pointer to Lookup, reading from a cache holding the registered indexes
+func NewLookup(reader client.Reader) *Lookup {
+	return &Lookup{
+		reader: reader,
+	}
+}
+
+// IngressesUsing returns the Ingresses whose TLS entries use the Secret with the given namespace/name
+func (l *Lookup) IngressesUsing(ctx context.Context, secretName types.NamespacedName) ([]networkingv1.Ingress, error) {
+	ingressList := &networkingv1.IngressList{}
+
+	err := l.reader.List(ctx, ingressList, client.InNamespace(secretName.Namespace), client.MatchingFields{IngressSecretNameField: secretName.Name})
+	if err != nil {
+		return nil, fmt.Errorf("could not list Ingresses using Secret [%s]: %v", secretName, err)
+	}
+
+	return ingressList.Items, nil
+}
+
+// SecretCopiesOf returns the metadata of the Secrets copied from the source Secret, or from any source Secret with its
+// name when the namespace is empty
+func (l *Lookup) SecretCopiesOf(ctx context.Context, source types.NamespacedName) ([]metav1.PartialObjectMetadata, error) {
+	return l.copiesOf(ctx, "Secret", source)
+}
+
+// ConfigMapCopiesOf returns the metadata of the ConfigMaps copied from the source Secret, or from any source Secret
+// with its name when the namespace is empty
+func (l *Lookup) ConfigMapCopiesOf(ctx context.Context, source types.NamespacedName) ([]metav1.PartialObjectMetadata, error) {
+	return l.copiesOf(ctx, "ConfigMap", source)
+}
+
+// IngressesUsingSource returns the Ingresses using any copy of the source Secret, or of any source Secret with its name
+// when the namespace is empty
+func (l *Lookup) IngressesUsingSource(ctx context.Context, source types.NamespacedName) ([]networkingv1.Ingress, error) {
+	copies, err := l.SecretCopiesOf(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+
+	var ingresses []networkingv1.Ingress
+
+	for _, targetSecretMetadata := range copies {
+		copyIngresses, err := l.IngressesUsing(ctx, types.NamespacedName{
+			Namespace: targetSecretMetadata.Namespace,
+			Name:      targetSecretMetadata.Name,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		ingresses = append(ingresses, copyIngresses...)
+	}
+
+	return ingresses, nil
+}
+
+func (l *Lookup) copiesOf(ctx context.Context, kind string, source types.NamespacedName) ([]metav1.PartialObjectMetadata, error) {
+	key := sourceKey(source)
+	metadataList := newMetadataList(kind)
+
+	err := l.reader.List(ctx, metadataList, client.MatchingFields{CopySourceField: key})
+	if err != nil {
+		return nil, fmt.Errorf("could not list the %s copies of Secret [%s]: %v", kind, key, err)
+	}
+
+	return metadataList.Items, nil
+}
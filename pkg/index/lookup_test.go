@@ -0,0 +1,129 @@
+package index
+
+import (
+	"context"
+	"testing"
+
+	"tls-secret-injector/pkg/index/indexfake"
+	"tls-secret-injector/pkg/replica"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSecretCopiesOf(t *testing.T) {
+	objects := []client.Object{
+		newCopy("team-a", "tls-example-io", "source"),
+		newCopy("team-b", "tls-example-io", "shared"),
+		newCopy("team-c", "tls-example-io", ""),
+		newCopy("team-a", "tls-other-io", "source"),
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "team-d",
+				Name:      "tls-example-io",
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		source         types.NamespacedName
+		expectedCopies []string
+	}{
+		"copies of any source Secret with the name": {
+			source:         types.NamespacedName{Name: "tls-example-io"},
+			expectedCopies: []string{"team-a/tls-example-io", "team-b/tls-example-io", "team-c/tls-example-io"},
+		},
+		"copies of the source Secret in a namespace": {
+			source:         types.NamespacedName{Namespace: "shared", Name: "tls-example-io"},
+			expectedCopies: []string{"team-b/tls-example-io"},
+		},
+		"no copies": {
+			source: types.NamespacedName{Namespace: "shared", Name: "tls-other-io"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			lookup := NewLookup(indexfake.NewClient(fake.NewClientBuilder().WithObjects(objects...).Build(), Register))
+
+			copies, err := lookup.SecretCopiesOf(context.TODO(), test.source)
+			assert.NoError(t, err)
+
+			var copyNames []string
+			for _, targetSecretMetadata := range copies {
+				copyNames = append(copyNames, targetSecretMetadata.Namespace+"/"+targetSecretMetadata.Name)
+			}
+
+			assert.ElementsMatch(t, test.expectedCopies, copyNames)
+		})
+	}
+}
+
+func TestIngressesUsingSource(t *testing.T) {
+	objects := []client.Object{
+		newCopy("team-a", "tls-example-io", "source"),
+		newCopy("team-b", "tls-example-io", "shared"),
+		newIngress("team-a", "web", "tls-example-io"),
+		newIngress("team-a", "api", "tls-other-io", "tls-example-io"),
+		newIngress("team-a", "admin", "tls-other-io"),
+		newIngress("team-b", "web", "tls-example-io"),
+		newIngress("team-c", "web", "tls-example-io"),
+	}
+
+	lookup := NewLookup(indexfake.NewClient(fake.NewClientBuilder().WithObjects(objects...).Build(), Register))
+
+	// Only the Ingresses using a copy of the source Secret are returned
+	ingresses, err := lookup.IngressesUsingSource(context.TODO(), types.NamespacedName{Namespace: "source", Name: "tls-example-io"})
+	assert.NoError(t, err)
+
+	var ingressNames []string
+	for _, ingress := range ingresses {
+		ingressNames = append(ingressNames, ingress.Namespace+"/"+ingress.Name)
+	}
+
+	assert.ElementsMatch(t, []string{"team-a/web", "team-a/api"}, ingressNames)
+}
+
+func TestCopySources(t *testing.T) {
+	assert.Equal(t, []string{"tls-example-io", "source/tls-example-io"}, copySources(newCopy("team-a", "tls-example-io", "source")))
+	assert.Equal(t, []string{"tls-example-io"}, copySources(newCopy("team-a", "tls-example-io", "")))
+
+	// Secrets not managed by the injector are not indexed
+	assert.Empty(t, copySources(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{replica.SourceNameLabel: "tls-example-io"}}}))
+}
+
+func newCopy(namespace, name, sourceNamespace string) *corev1.Secret {
+	targetSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    replica.Labels(name),
+		},
+	}
+
+	if sourceNamespace != "" {
+		targetSecret.Annotations = map[string]string{replica.SourceNamespaceAnnotation: sourceNamespace}
+	}
+
+	return targetSecret
+}
+
+func newIngress(namespace, name string, secretNames ...string) *networkingv1.Ingress {
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+	}
+
+	for _, secretName := range secretNames {
+		ingress.Spec.TLS = append(ingress.Spec.TLS, networkingv1.IngressTLS{SecretName: secretName})
+	}
+
+	return ingress
+}
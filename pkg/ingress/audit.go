@@ -0,0 +1,346 @@
+package ingress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/policy"
+	"tls-secret-injector/pkg/replica"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// AuditStatus is the outcome of the audit of a Secret referenced by an Ingress
+type AuditStatus string
+
+const (
+	// AuditOK means that the copy holds the data of its source Secret
+	AuditOK AuditStatus = "ok"
+	// AuditMissing means that the copy did not exist
+	AuditMissing AuditStatus = "missing"
+	// AuditStale means that the copy holds other data than its source Secret
+	AuditStale AuditStatus = "stale"
+	// AuditUnmanaged means that a Secret not managed by the injector holds the name, which the conflict policy decides about
+	AuditUnmanaged AuditStatus = "unmanaged"
+	// AuditSourceMissing means that the source Secret does not exist
+	AuditSourceMissing AuditStatus = "source-missing"
+	// AuditFailed means that the reference could not be checked, or the Ingress cannot use its source Secret
+	AuditFailed AuditStatus = "failed"
+)
+
+var (
+	auditReferences = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "tls_secret_injector_audit_references",
+		Help: "Number of Secrets referenced by Ingresses per status found by the last audit",
+	}, []string{"status"})
+	auditRepairs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tls_secret_injector_audit_repairs_total",
+		Help: "Number of missing copies created by the audits, and of stale copies found up to date after being resynced",
+	}, []string{"status"})
+	auditLastRun = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "tls_secret_injector_audit_last_run_timestamp_seconds",
+		Help: "Time at which the last audit completed",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(auditReferences, auditRepairs, auditLastRun)
+}
+
+// AuditReport holds the results of an audit, listing the references that were not up to date
+type AuditReport struct {
+	StartTime      time.Time           `json:"startTime"`
+	CompletionTime time.Time           `json:"completionTime"`
+	Ingresses      int                 `json:"ingresses"`
+	Counts         map[AuditStatus]int `json:"counts"`
+	// Resynced is the number of stale copies enqueued by the previous audit that were found up to date
+	Resynced   int          `json:"resynced"`
+	References []AuditEntry `json:"references"`
+}
+
+// AuditEntry holds the audit result of a Secret referenced by an Ingress
+type AuditEntry struct {
+	Ingress  string      `json:"ingress"`
+	Secret   string      `json:"secret"`
+	Source   string      `json:"source,omitempty"`
+	Status   AuditStatus `json:"status"`
+	Repaired bool        `json:"repaired"`
+	// Enqueued means that the source Secret of a stale copy was resynced, which the next audit verifies
+	Enqueued bool   `json:"enqueued"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Auditor periodically checks the Secrets referenced by all Ingresses against their source Secrets, creating the
+// missing copies and resyncing the stale ones, to make up for missed events
+type Auditor struct {
+	*copier
+
+	resync   func(ctx context.Context, name types.NamespacedName)
+	interval time.Duration
+
+	mutex  sync.Mutex
+	report *AuditReport
+
+	// enqueued holds the stale copies whose source Secrets the last audit resynced
+	enqueued map[string]bool
+}
+
+// NewAuditor returns a pointer to Auditor, where resync enqueues a source Secret to update its copies
+func NewAuditor(client client.Client, secretSource backend.SecretSource, policies *policy.Resolver, recorder record.EventRecorder, ownerReferences bool, resync func(ctx context.Context, name types.NamespacedName), interval time.Duration) *Auditor {
+	writer := replica.NewWriter(client, secretSource, policies, recorder)
+
+	return &Auditor{
+		copier:   newCopier(client, secretSource, policies, writer, ownerReferences),
+		resync:   resync,
+		interval: interval,
+		enqueued: map[string]bool{},
+	}
+}
+
+// Start audits the Ingresses right away and then every interval until the context is done
+func (a *Auditor) Start(ctx context.Context) error {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		a.run(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// run audits the Ingresses, and publishes the report and its metrics
+func (a *Auditor) run(ctx context.Context) {
+	report, err := a.Audit(ctx)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	a.mutex.Lock()
+	a.report = report
+	a.mutex.Unlock()
+
+	auditReferences.Reset()
+	for status, count := range report.Counts {
+		auditReferences.WithLabelValues(string(status)).Set(float64(count))
+	}
+	auditLastRun.Set(float64(report.CompletionTime.Unix()))
+
+	log.Infof("Audited %d Ingresses: %v", report.Ingresses, report.Counts)
+}
+
+// NeedLeaderElection makes sure that only the leader repairs copies
+func (a *Auditor) NeedLeaderElection() bool {
+	return true
+}
+
+// ServeHTTP writes the report of the last audit as JSON
+func (a *Auditor) ServeHTTP(writer http.ResponseWriter, _ *http.Request) {
+	a.mutex.Lock()
+	report := a.report
+	a.mutex.Unlock()
+
+	if report == nil {
+		http.Error(writer, "no audit has completed yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(writer).Encode(report)
+	if err != nil {
+		log.Errorf("could not write the audit report: %v", err)
+	}
+}
+
+// Audit checks the Secrets referenced by all Ingresses outside of the source, and repairs their copies
+func (a *Auditor) Audit(ctx context.Context) (*AuditReport, error) {
+	// Fetch the intermediates Secrets once for the whole audit
+	ctx = replica.WithIntermediatesCache(ctx)
+
+	report := &AuditReport{
+		StartTime: time.Now(),
+		Counts:    map[AuditStatus]int{},
+	}
+
+	ingressList := &networkingv1.IngressList{}
+
+	err := a.client.List(ctx, ingressList)
+	if err != nil {
+		return nil, fmt.Errorf("could not list Ingresses: %v", err)
+	}
+
+	// Resync every stale source Secret once, however many copies of it are stale
+	resynced := map[types.NamespacedName]bool{}
+	enqueued := map[string]bool{}
+
+	for i := range ingressList.Items {
+		ingress := &ingressList.Items[i]
+		if a.source.IsSourceNamespace(ctx, ingress.Namespace) {
+			continue
+		}
+
+		report.Ingresses++
+
+		for _, ingressTLS := range ingress.Spec.TLS {
+			if ingressTLS.SecretName == "" {
+				continue
+			}
+
+			entry := a.auditReference(ctx, ingress, ingressTLS.SecretName, resynced)
+
+			report.Counts[entry.Status]++
+			if entry.Status != AuditOK {
+				report.References = append(report.References, entry)
+			}
+			if entry.Repaired {
+				auditRepairs.WithLabelValues(string(entry.Status)).Inc()
+			}
+
+			// The resync of a stale copy is only verified by finding it up to date in the next audit
+			if entry.Enqueued {
+				enqueued[entry.Secret] = true
+			}
+			if entry.Status == AuditOK && a.enqueued[entry.Secret] {
+				report.Resynced++
+				auditRepairs.WithLabelValues(string(AuditStale)).Inc()
+				delete(a.enqueued, entry.Secret)
+			}
+		}
+	}
+
+	a.enqueued = enqueued
+	report.CompletionTime = time.Now()
+
+	return report, nil
+}
+
+func (a *Auditor) auditReference(ctx context.Context, ingress *networkingv1.Ingress, secretName string, resynced map[types.NamespacedName]bool) (entry AuditEntry) {
+	targetSecretName := types.NamespacedName{
+		Namespace: ingress.Namespace,
+		Name:      secretName,
+	}
+
+	entry = AuditEntry{
+		Ingress: types.NamespacedName{Namespace: ingress.Namespace, Name: ingress.Name}.String(),
+		Secret:  targetSecretName.String(),
+	}
+
+	fail := func(status AuditStatus, err error) AuditEntry {
+		entry.Status = status
+		entry.Error = err.Error()
+		return entry
+	}
+
+	// Fetch the copy, which is missing when the injector never saw the Ingress, only reading its metadata
+	targetMetadata := replica.NewSecretMetadata()
+
+	err := a.client.Get(ctx, targetSecretName, targetMetadata)
+	missing := errors.IsNotFound(err)
+	if err != nil && !missing {
+		return fail(AuditFailed, fmt.Errorf("could not fetch the target Secret [%s]: %v", targetSecretName, err))
+	}
+
+	// Leave the Secrets not managed by the injector to the conflict policy applied when the Ingress is reconciled
+	if !missing && !replica.IsManaged(targetMetadata) {
+		entry.Status = AuditUnmanaged
+		return
+	}
+
+	sourceSecret, err := a.getSourceSecret(ctx, ingress, secretName)
+	if errors.IsNotFound(err) {
+		return fail(AuditSourceMissing, err)
+	}
+	if err != nil {
+		return fail(AuditFailed, fmt.Errorf("could not fetch the source Secret [%s]: %v", secretName, err))
+	}
+
+	sourceSecretName := types.NamespacedName{
+		Namespace: sourceSecret.Namespace,
+		Name:      sourceSecret.Name,
+	}
+	entry.Source = sourceSecretName.String()
+
+	err = checkSourceSecret(ingress, sourceSecret)
+	if err != nil {
+		return fail(AuditFailed, err)
+	}
+
+	if missing {
+		entry.Status = AuditMissing
+
+		err = a.checkTargetPolicy(ctx, ingress, sourceSecret, targetSecretName.Namespace)
+		if err != nil {
+			return fail(AuditFailed, err)
+		}
+
+		created, err := a.writer.Create(ctx, sourceSecret, targetSecretName, a.newOwnerReferences(ingress))
+		if err != nil {
+			return fail(AuditMissing, fmt.Errorf("failed to create the target Secret [%s]: %v", targetSecretName, err))
+		}
+
+		entry.Repaired = created
+		if created {
+			log.Infof("Successfully created missing Secret [%s] found by the audit", targetSecretName)
+		}
+
+		return
+	}
+
+	data, err := a.writer.Data(ctx, sourceSecret, targetSecretName.Namespace)
+	if err != nil {
+		return fail(AuditFailed, fmt.Errorf("could not resolve the data of the target Secret [%s]: %v", targetSecretName, err))
+	}
+
+	// Only read the data of the copies last written with the data of the source Secret, to tell whether they were changed
+	// since they were written
+	if replica.IsRecorded(targetMetadata, data) {
+		targetSecret := &corev1.Secret{}
+
+		err = a.client.Get(ctx, targetSecretName, targetSecret)
+		if err != nil {
+			return fail(AuditFailed, fmt.Errorf("could not fetch the target Secret [%s]: %v", targetSecretName, err))
+		}
+
+		upToDate, err := a.writer.IsUpToDate(ctx, sourceSecret, targetSecret, data)
+		if err != nil {
+			return fail(AuditFailed, fmt.Errorf("could not check the target Secret [%s]: %v", targetSecretName, err))
+		}
+		if upToDate {
+			entry.Status = AuditOK
+			return
+		}
+	}
+
+	// Let the Secret controller update the stale copies, which checks the data and rolls it out first
+	entry.Status = AuditStale
+
+	if !resynced[sourceSecretName] {
+		log.Infof("Resyncing source Secret [%s] as the audit found its copy [%s] stale", sourceSecretName, targetSecretName)
+
+		a.resync(ctx, sourceSecretName)
+		resynced[sourceSecretName] = true
+	}
+
+	entry.Enqueued = true
+	return
+}
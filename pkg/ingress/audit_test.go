@@ -0,0 +1,183 @@
+package ingress
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/index"
+	"tls-secret-injector/pkg/index/indexfake"
+	"tls-secret-injector/pkg/policy"
+	"tls-secret-injector/pkg/replica"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAudit(t *testing.T) {
+	dataHash := replica.DataHash(newSecret("source").Data)
+
+	upToDateSecret := newManagedSecret("target")
+	upToDateSecret.Annotations = map[string]string{replica.DataHashAnnotation: dataHash, replica.WrittenHashAnnotation: dataHash}
+
+	// A copy written from the current data but changed since
+	changedSecret := newManagedSecret("target")
+	changedSecret.Annotations = map[string]string{replica.DataHashAnnotation: dataHash, replica.WrittenHashAnnotation: dataHash}
+	changedSecret.Data[corev1.TLSCertKey] = []byte("changed certificate")
+
+	tests := map[string]struct {
+		objects          []client.Object
+		expectedStatus   AuditStatus
+		expectedRepaired bool
+		expectedEnqueued bool
+		expectedResync   []types.NamespacedName
+		expectCopy       bool
+	}{
+		"up to date copy": {
+			objects:        []client.Object{newSecret("source"), upToDateSecret},
+			expectedStatus: AuditOK,
+			expectCopy:     true,
+		},
+		"missing copy": {
+			objects:          []client.Object{newSecret("source")},
+			expectedStatus:   AuditMissing,
+			expectedRepaired: true,
+			expectCopy:       true,
+		},
+		"stale copy": {
+			objects:          []client.Object{newSecret("source"), newManagedSecret("target")},
+			expectedStatus:   AuditStale,
+			expectedEnqueued: true,
+			expectedResync:   []types.NamespacedName{{Namespace: "source", Name: "tls-example-io"}},
+			expectCopy:       true,
+		},
+		"changed copy": {
+			objects:          []client.Object{newSecret("source"), changedSecret},
+			expectedStatus:   AuditStale,
+			expectedEnqueued: true,
+			expectedResync:   []types.NamespacedName{{Namespace: "source", Name: "tls-example-io"}},
+			expectCopy:       true,
+		},
+		"unmanaged Secret": {
+			objects:        []client.Object{newSecret("source"), newSecret("target")},
+			expectedStatus: AuditUnmanaged,
+			expectCopy:     true,
+		},
+		"missing source": {
+			expectedStatus: AuditSourceMissing,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Create a client and the auditor
+			fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(append(test.objects, newIngress("target"), newIngress("source"))...).Build(), index.Register)
+			policies := policy.NewResolver(fakeClient, nil, policy.Policy{Conflict: policy.ConflictSkip})
+			secretSource := backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil)
+
+			var resynced []types.NamespacedName
+			resync := func(_ context.Context, name types.NamespacedName) {
+				resynced = append(resynced, name)
+			}
+
+			auditor := NewAuditor(fakeClient, secretSource, policies, record.NewFakeRecorder(10), true, resync, 0)
+
+			// Audit and check the report, which skips the Ingresses of the source
+			report, err := auditor.Audit(context.TODO())
+			assert.NoError(t, err)
+
+			assert.Equal(t, 1, report.Ingresses)
+			assert.Equal(t, map[AuditStatus]int{test.expectedStatus: 1}, report.Counts)
+			assert.Equal(t, test.expectedResync, resynced)
+
+			if test.expectedStatus == AuditOK {
+				assert.Empty(t, report.References)
+			} else if assert.Len(t, report.References, 1) {
+				assert.Equal(t, "target/example-io", report.References[0].Ingress)
+				assert.Equal(t, "target/tls-example-io", report.References[0].Secret)
+				assert.Equal(t, test.expectedRepaired, report.References[0].Repaired)
+				assert.Equal(t, test.expectedEnqueued, report.References[0].Enqueued)
+			}
+
+			// Check whether the copy exists after the repairs
+			err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: "target", Name: "tls-example-io"}, &corev1.Secret{})
+			assert.Equal(t, test.expectCopy, err == nil)
+		})
+	}
+}
+
+func TestAuditResynced(t *testing.T) {
+	// Create a client and the auditor with a stale copy
+	fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(newSecret("source"), newManagedSecret("target"), newIngress("target")).Build(), index.Register)
+	policies := policy.NewResolver(fakeClient, nil, policy.Policy{Conflict: policy.ConflictSkip})
+	secretSource := backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil)
+	writer := replica.NewWriter(fakeClient, secretSource, policies, record.NewFakeRecorder(10))
+
+	// Let the resync update the copy, as the Secret controller would
+	resync := func(ctx context.Context, name types.NamespacedName) {
+		sourceSecret, err := secretSource.Get(ctx, name.Name)
+		assert.NoError(t, err)
+
+		targetSecret := &corev1.Secret{}
+		assert.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Namespace: "target", Name: name.Name}, targetSecret))
+		assert.NoError(t, writer.Update(ctx, sourceSecret, targetSecret, sourceSecret.Data))
+	}
+
+	auditor := NewAuditor(fakeClient, secretSource, policies, record.NewFakeRecorder(10), true, resync, 0)
+
+	// Verify that the stale copy is only reported as enqueued, and as resynced once the next audit finds it up to date
+	report, err := auditor.Audit(context.TODO())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Counts[AuditStale])
+	assert.Equal(t, 0, report.Resynced)
+
+	report, err = auditor.Audit(context.TODO())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Counts[AuditOK])
+	assert.Equal(t, 1, report.Resynced)
+
+	report, err = auditor.Audit(context.TODO())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, report.Resynced)
+}
+
+func TestAuditReport(t *testing.T) {
+	auditor := &Auditor{}
+
+	// No report is served before the first audit completes
+	recorder := httptest.NewRecorder()
+	auditor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/audit", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	auditor.report = &AuditReport{
+		Ingresses: 1,
+		Counts:    map[AuditStatus]int{AuditStale: 1},
+		References: []AuditEntry{
+			{Ingress: "target/example-io", Secret: "target/tls-example-io", Source: "source/tls-example-io", Status: AuditStale, Enqueued: true},
+		},
+	}
+
+	recorder = httptest.NewRecorder()
+	auditor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/audit", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+	report := &AuditReport{}
+	err := json.Unmarshal(recorder.Body.Bytes(), report)
+
+	assert.NoError(t, err)
+	assert.Equal(t, auditor.report.References, report.References)
+	assert.Equal(t, 1, report.Counts[AuditStale])
+}
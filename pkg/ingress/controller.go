@@ -3,6 +3,11 @@ package ingress
 import (
 	"fmt"
 
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/policy"
+	"tls-secret-injector/pkg/replica"
+	"tls-secret-injector/pkg/tracing"
+
 	log "github.com/sirupsen/logrus"
 
 	networkingv1 "k8s.io/api/networking/v1"
@@ -15,16 +20,19 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
-func NewController(mgr manager.Manager, sourceNamespace string) error {
+func NewController(mgr manager.Manager, secretSource backend.SecretSource, policies *policy.Resolver, ownerReferences bool) error {
+	// Setup the writer of the Secrets copied for Ingresses
+	writer := replica.NewWriter(mgr.GetClient(), secretSource, policies, mgr.GetEventRecorderFor("tls-secret-injector"))
+
 	// Setup the webhooks
 	server := mgr.GetWebhookServer()
 	server.Register("/mutate", &webhook.Admission{
-		Handler: newMutator(mgr.GetClient(), sourceNamespace),
+		Handler: newMutator(mgr.GetClient(), secretSource, policies, writer, ownerReferences),
 	})
 
 	// Setup the reconciler
 	ingressController, err := controller.New("ingress", mgr, controller.Options{
-		Reconciler: newReconciler(mgr.GetClient(), sourceNamespace),
+		Reconciler: tracing.Reconciler("ingress", newReconciler(mgr.GetClient(), secretSource, policies, writer, ownerReferences)),
 	})
 	if err != nil {
 		return fmt.Errorf("unable to set up Ingress controller: %v", err)
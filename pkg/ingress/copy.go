@@ -2,8 +2,16 @@ package ingress
 
 import (
 	"context"
+	"fmt"
+	"strings"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/policy"
+	"tls-secret-injector/pkg/replica"
+	"tls-secret-injector/pkg/tracing"
 
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
@@ -13,7 +21,28 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func copySecretsFromIngress(client client.Client, ctx context.Context, ingress *networkingv1.Ingress, sourceNamespace, targetNamespace string) []string {
+// SourceAnnotationPrefix prefixes the Ingress annotations mapping a secretName to an explicit namespace/name source
+const SourceAnnotationPrefix = "tls-secret-injector/source."
+
+type copier struct {
+	client          client.Client
+	source          backend.SecretSource
+	policies        *policy.Resolver
+	writer          *replica.Writer
+	ownerReferences bool
+}
+
+func newCopier(client client.Client, secretSource backend.SecretSource, policies *policy.Resolver, writer *replica.Writer, ownerReferences bool) *copier {
+	return &copier{
+		client:          client,
+		source:          secretSource,
+		policies:        policies,
+		writer:          writer,
+		ownerReferences: ownerReferences,
+	}
+}
+
+func (c *copier) copySecretsFromIngress(ctx context.Context, ingress *networkingv1.Ingress, targetNamespace string) []string {
 	var createdSecrets []string
 
 	for _, ingressTLS := range ingress.Spec.TLS {
@@ -23,55 +52,13 @@ func copySecretsFromIngress(client client.Client, ctx context.Context, ingress *
 			Namespace: targetNamespace,
 			Name:      ingressTLS.SecretName,
 		}
-		targetSecret := &corev1.Secret{}
-
-		// Check if we need to create the target Secret
-		err := client.Get(ctx, targetSecretName, targetSecret)
-		if !errors.IsNotFound(err) {
-			log.Debugf("Skipping creation of the target Secret [%s] as it already exists", targetSecretName)
-			continue
-		}
-
-		// Fetch the source Secret
-		sourceSecretName := types.NamespacedName{
-			Namespace: sourceNamespace,
-			Name:      ingressTLS.SecretName,
-		}
-		sourceSecret := &corev1.Secret{}
 
-		err = client.Get(ctx, sourceSecretName, sourceSecret)
+		created, err := c.copySecret(ctx, ingress, targetSecretName)
 		if err != nil {
-			log.Errorf("could not fetch the source Secret [%s]: %v", sourceSecretName, err)
-			continue
-		}
-
-		// Copy Secret data from source to target
-		targetSecret = &corev1.Secret{
-			TypeMeta: metav1.TypeMeta{
-				APIVersion: corev1.SchemeGroupVersion.Version,
-				Kind:       "Secret",
-			},
-			ObjectMeta: metav1.ObjectMeta{
-				Namespace: targetSecretName.Namespace,
-				Name:      targetSecretName.Name,
-				Labels: map[string]string{
-					"app.kubernetes.io/name":          "tls-secret-injector",
-					"tls-secret-injector/source-name": targetSecretName.Name,
-				},
-			},
-			Type: sourceSecret.Type,
-			Data: sourceSecret.Data,
-		}
-
-		err = client.Create(ctx, targetSecret)
-		if errors.IsAlreadyExists(err) {
-			// While we already check before if the target Secret exists there could be another request being made for
-			// another Ingress that uses the same Secret.
-			log.Debugf("Skipping creation of the target Secret [%s] as it already exists", targetSecretName)
+			log.Error(err)
 			continue
 		}
-		if err != nil {
-			log.Errorf("failed to create the target Secret [%s]: %v", targetSecretName, err)
+		if !created {
 			continue
 		}
 
@@ -81,3 +68,129 @@ func copySecretsFromIngress(client client.Client, ctx context.Context, ingress *
 
 	return createdSecrets
 }
+
+// copySecret creates the target Secret used by the Ingress from its source Secret, unless it already exists, and
+// returns whether it was created
+func (c *copier) copySecret(ctx context.Context, ingress *networkingv1.Ingress, targetSecretName types.NamespacedName) (created bool, err error) {
+	ctx, span := tracing.Start(ctx, "Copy Secret", attribute.String("k8s.namespace", targetSecretName.Namespace), attribute.String("k8s.name", targetSecretName.Name))
+	defer func() {
+		span.SetAttributes(attribute.Bool("created", created))
+		tracing.End(span, err)
+	}()
+
+	targetSecret := replica.NewSecretMetadata()
+
+	// Check if we need to create the target Secret, only reading its metadata
+	err = c.client.Get(ctx, targetSecretName, targetSecret)
+	if err == nil && replica.IsManaged(targetSecret) {
+		// Pushed copies are kept for their source Secret, whichever Ingresses use them
+		if c.ownerReferences && !replica.IsPushed(targetSecret) && !isOwnedBy(targetSecret, ingress) {
+			addOwnerReference(c.client, ctx, ingress, targetSecretName)
+		}
+
+		log.Debugf("Skipping creation of the target Secret [%s] as it already exists", targetSecretName)
+		return false, nil
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return false, fmt.Errorf("could not fetch the target Secret [%s]: %v", targetSecretName, err)
+	}
+
+	// Fetch the source Secret
+	sourceSecret, err := c.getSourceSecret(ctx, ingress, targetSecretName.Name)
+	if err != nil {
+		return false, fmt.Errorf("could not fetch the source Secret [%s]: %v", targetSecretName.Name, err)
+	}
+
+	err = checkSourceSecret(ingress, sourceSecret)
+	if err != nil {
+		return false, err
+	}
+
+	err = c.checkTargetPolicy(ctx, ingress, sourceSecret, targetSecretName.Namespace)
+	if err != nil {
+		return false, err
+	}
+
+	// Copy Secret data from source to target, unless the policy keeps an existing Secret
+	created, err = c.writer.Create(ctx, sourceSecret, targetSecretName, c.newOwnerReferences(ingress))
+	if err != nil {
+		return false, fmt.Errorf("failed to create the target Secret [%s]: %v", targetSecretName, err)
+	}
+	if !created {
+		log.Debugf("Skipping creation of the target Secret [%s] as it already exists", targetSecretName)
+	}
+
+	return created, nil
+}
+
+// checkSourceSecret returns an error when the Ingress cannot use the source Secret
+func checkSourceSecret(ingress *networkingv1.Ingress, sourceSecret *corev1.Secret) error {
+	// Ingresses can only terminate TLS with a valid TLS Secret
+	if sourceSecret.Type != corev1.SecretTypeTLS {
+		return fmt.Errorf("could not use the source Secret [%s/%s] for Ingress [%s/%s] as its type is %s", sourceSecret.Namespace, sourceSecret.Name, ingress.Namespace, ingress.Name, sourceSecret.Type)
+	}
+
+	return replica.Validate(sourceSecret)
+}
+
+// checkTargetPolicy makes sure that the policy of the target namespace copies the private key the Ingress needs
+func (c *copier) checkTargetPolicy(ctx context.Context, ingress *networkingv1.Ingress, sourceSecret *corev1.Secret, namespace string) error {
+	targetPolicy, err := c.policies.For(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	if targetPolicy.Public != "" {
+		return fmt.Errorf("could not copy the source Secret [%s/%s] for Ingress [%s/%s] as policy [%s] only copies public certificates", sourceSecret.Namespace, sourceSecret.Name, ingress.Namespace, ingress.Name, targetPolicy.Name)
+	}
+
+	return nil
+}
+
+// newOwnerReferences returns the owner references of the Secrets copied for the Ingress
+func (c *copier) newOwnerReferences(ingress *networkingv1.Ingress) []metav1.OwnerReference {
+	// Let the garbage collector delete the target Secret together with the last Ingress using it
+	if !c.ownerReferences || ingress.UID == "" {
+		return nil
+	}
+
+	return []metav1.OwnerReference{newOwnerReference(ingress)}
+}
+
+// SourceReference returns the namespace/name of the Secret the Ingress maps the secretName to through an annotation,
+// and whether it has such an annotation at all
+func SourceReference(ingress *networkingv1.Ingress, secretName string) (types.NamespacedName, bool, error) {
+	reference, ok := ingress.Annotations[SourceAnnotationPrefix+secretName]
+	if !ok {
+		return types.NamespacedName{}, false, nil
+	}
+
+	parts := strings.SplitN(reference, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return types.NamespacedName{}, true, fmt.Errorf("invalid source [%s] in annotation of Ingress [%s/%s], expected namespace/name", reference, ingress.Namespace, ingress.Name)
+	}
+
+	return types.NamespacedName{
+		Namespace: parts[0],
+		Name:      parts[1],
+	}, true, nil
+}
+
+// getSourceSecret fetches the Secret the Ingress maps the secretName to through an annotation, falling back to the
+// Secret with the same name in the source
+func (c *copier) getSourceSecret(ctx context.Context, ingress *networkingv1.Ingress, secretName string) (*corev1.Secret, error) {
+	sourceSecretName, ok, err := SourceReference(ingress, secretName)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return c.source.Get(ctx, secretName)
+	}
+
+	// Check if the namespace of the Ingress may copy from the referenced Secret
+	err = c.policies.CheckSource(ctx, ingress.Namespace, sourceSecretName)
+	if err != nil {
+		return nil, err
+	}
+
+	return backend.GetReferenced(ctx, c.source, c.client, sourceSecretName)
+}
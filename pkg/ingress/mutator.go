@@ -5,7 +5,13 @@ import (
 	"fmt"
 	"net/http"
 
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/policy"
+	"tls-secret-injector/pkg/replica"
+	"tls-secret-injector/pkg/tracing"
+
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 
 	networkingv1 "k8s.io/api/networking/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -13,24 +19,38 @@ import (
 )
 
 type mutator struct {
-	client          client.Client
-	sourceNamespace string
+	*copier
 
 	decoder *admission.Decoder
 }
 
-func newMutator(client client.Client, sourceNamespace string) *mutator {
+func newMutator(client client.Client, secretSource backend.SecretSource, policies *policy.Resolver, writer *replica.Writer, ownerReferences bool) *mutator {
 	return &mutator{
-		client:          client,
-		sourceNamespace: sourceNamespace,
+		copier: newCopier(client, secretSource, policies, writer, ownerReferences),
 	}
 }
 
-func (m *mutator) Handle(ctx context.Context, request admission.Request) admission.Response {
+func (m *mutator) Handle(ctx context.Context, request admission.Request) (response admission.Response) {
+	ctx, span := tracing.Start(ctx, "Admission Ingress",
+		attribute.String("admission.uid", string(request.UID)),
+		attribute.String("admission.operation", string(request.Operation)),
+		attribute.String("k8s.namespace", request.Namespace),
+		attribute.String("k8s.name", request.Name),
+	)
+	defer func() {
+		var err error
+		if !response.Allowed && response.Result != nil {
+			err = fmt.Errorf("%s", response.Result.Message)
+		}
+
+		span.SetAttributes(attribute.Bool("admission.allowed", response.Allowed))
+		tracing.End(span, err)
+	}()
+
 	log.Debugf("Received request to mutate Ingress [%s/%s]", request.Namespace, request.Name)
 
 	// Check if the request is the same as the source
-	if request.Namespace == m.sourceNamespace {
+	if m.source.IsSourceNamespace(ctx, request.Namespace) {
 		reason := fmt.Sprintf("Skipping mutation of Ingress [%s/%s] from the same namespace as the source", request.Namespace, request.Name)
 		log.Debug(reason)
 		return admission.Allowed(reason)
@@ -39,7 +59,9 @@ func (m *mutator) Handle(ctx context.Context, request admission.Request) admissi
 	// Decode the Ingress from the request
 	ingress := &networkingv1.Ingress{}
 
+	_, decodeSpan := tracing.Start(ctx, "Decode Ingress")
 	err := m.decoder.Decode(request, ingress)
+	tracing.End(decodeSpan, err)
 	if err != nil {
 		err = fmt.Errorf("failed to decode Ingress [%s/%s]: %v", request.Namespace, request.Namespace, err)
 		log.Error(err)
@@ -47,7 +69,7 @@ func (m *mutator) Handle(ctx context.Context, request admission.Request) admissi
 	}
 
 	// Create new Secrets by copying Secrets from the source namespace
-	createdSecrets := copySecretsFromIngress(m.client, ctx, ingress, m.sourceNamespace, request.Namespace)
+	createdSecrets := m.copySecretsFromIngress(ctx, ingress, request.Namespace)
 
 	if len(createdSecrets) == 0 {
 		return admission.Allowed("No new Secrets created")
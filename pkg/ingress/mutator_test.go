@@ -5,6 +5,12 @@ import (
 	"reflect"
 	"testing"
 
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/index"
+	"tls-secret-injector/pkg/index/indexfake"
+	"tls-secret-injector/pkg/policy"
+	"tls-secret-injector/pkg/replica"
+
 	"github.com/stretchr/testify/assert"
 	admissionv1 "k8s.io/api/admission/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -14,15 +20,25 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/json"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 func TestHandle(t *testing.T) {
+	teamSecret := newSecret("team")
+	teamSecret.Name = "tls-team-io"
+
+	annotatedIngress := newIngress("target")
+	annotatedIngress.Annotations = map[string]string{
+		SourceAnnotationPrefix + "tls-example-io": "team/tls-team-io",
+	}
+
 	tests := map[string]struct {
 		ingress   networkingv1.Ingress
 		objects   []client.Object
+		policies  []policy.Policy
 		newSecret corev1.Secret
 		reason    string
 	}{
@@ -46,13 +62,36 @@ func TestHandle(t *testing.T) {
 			},
 			reason: "No new Secrets created",
 		},
+		"create target secret from annotated source": {
+			ingress: *annotatedIngress,
+			objects: []client.Object{
+				newSecret("source"),
+				teamSecret,
+			},
+			policies: []policy.Policy{
+				{Name: "teams", Namespaces: []string{"target"}, AllowedSources: []string{"team/*"}},
+			},
+			newSecret: *newSecret("target"),
+			reason:    "Successfully created Secrets [target/tls-example-io]",
+		},
+		"skip creation of target secret from unauthorised source": {
+			ingress: *annotatedIngress,
+			objects: []client.Object{
+				newSecret("source"),
+				teamSecret,
+			},
+			reason: "No new Secrets created",
+		},
 	}
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
 			// Create a client and the mutator
-			fakeClient := fake.NewClientBuilder().WithObjects(test.objects...).Build()
-			mutator := newMutator(fakeClient, "source")
+			fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(test.objects...).Build(), index.Register)
+			policies := policy.NewResolver(fakeClient, test.policies, policy.Policy{Conflict: policy.ConflictSkip})
+			secretSource := backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil)
+			writer := replica.NewWriter(fakeClient, secretSource, policies, record.NewFakeRecorder(10))
+			mutator := newMutator(fakeClient, secretSource, policies, writer, true)
 
 			decoder, _ := admission.NewDecoder(scheme.Scheme)
 			_ = mutator.InjectDecoder(decoder)
@@ -0,0 +1,158 @@
+package ingress
+
+import (
+	"context"
+
+	"tls-secret-injector/pkg/index"
+	"tls-secret-injector/pkg/replica"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newOwnerReference(ingress *networkingv1.Ingress) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: networkingv1.SchemeGroupVersion.String(),
+		Kind:       "Ingress",
+		Name:       ingress.Name,
+		UID:        ingress.UID,
+	}
+}
+
+func isOwnedBy(secret metav1.Object, ingress *networkingv1.Ingress) bool {
+	for _, ownerReference := range secret.GetOwnerReferences() {
+		if ownerReference.UID == ingress.UID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addOwnerReference makes the Ingress one of the owners of a Secret copied before
+func addOwnerReference(client client.Client, ctx context.Context, ingress *networkingv1.Ingress, targetSecretName types.NamespacedName) {
+	// The UID is not known yet while the Ingress is being created
+	if ingress.UID == "" {
+		return
+	}
+
+	targetSecret := &corev1.Secret{}
+
+	err := client.Get(ctx, targetSecretName, targetSecret)
+	if err != nil {
+		log.Errorf("could not fetch the target Secret [%s]: %v", targetSecretName, err)
+		return
+	}
+
+	if !replica.IsManaged(targetSecret) || replica.IsPushed(targetSecret) || isOwnedBy(targetSecret, ingress) {
+		return
+	}
+
+	targetSecret.OwnerReferences = append(targetSecret.OwnerReferences, newOwnerReference(ingress))
+
+	err = client.Update(ctx, targetSecret)
+	if err != nil {
+		log.Errorf("failed to add Ingress [%s/%s] as owner of Secret [%s/%s]: %v", ingress.Namespace, ingress.Name, targetSecret.Namespace, targetSecret.Name, err)
+		return
+	}
+
+	log.Infof("Successfully added Ingress [%s/%s] as owner of Secret [%s/%s]", ingress.Namespace, ingress.Name, targetSecret.Namespace, targetSecret.Name)
+}
+
+// releaseSecretsFromIngress removes the Ingress from the owners of the Secrets it no longer uses, deleting the Secrets
+// that are left without any owner. ConfigMaps copied for the Ingress before public copies were rejected for Ingresses
+// are released alike.
+func releaseSecretsFromIngress(k8sClient client.Client, ctx context.Context, ingress *networkingv1.Ingress) {
+	usedSecrets := map[string]bool{}
+	for _, ingressTLS := range ingress.Spec.TLS {
+		usedSecrets[ingressTLS.SecretName] = true
+	}
+
+	// Find the copies through their metadata, only reading the copies the Ingress is released from
+	for _, kind := range []string{"Secret", "ConfigMap"} {
+		copyList := &metav1.PartialObjectMetadataList{}
+		copyList.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind(kind + "List"))
+
+		err := k8sClient.List(ctx, copyList, client.InNamespace(ingress.Namespace), client.MatchingLabels(replica.ManagedLabels()))
+		if err != nil {
+			log.Errorf("could not list %ss in namespace [%s]: %v", kind, ingress.Namespace, err)
+			return
+		}
+
+		for i := range copyList.Items {
+			copyMetadata := &copyList.Items[i]
+
+			// Pushed copies are kept for their source Secret, even when an Ingress owned them before they were pushed
+			if usedSecrets[copyMetadata.Name] || replica.IsPushed(copyMetadata) || !isOwnedBy(copyMetadata, ingress) {
+				continue
+			}
+
+			var targetCopy client.Object = &corev1.Secret{}
+			if kind == "ConfigMap" {
+				targetCopy = &corev1.ConfigMap{}
+			}
+
+			err = k8sClient.Get(ctx, client.ObjectKeyFromObject(copyMetadata), targetCopy)
+			if err != nil {
+				log.Errorf("could not fetch the %s [%s/%s]: %v", kind, copyMetadata.Namespace, copyMetadata.Name, err)
+				continue
+			}
+
+			releaseCopy(k8sClient, ctx, ingress, targetCopy)
+		}
+	}
+}
+
+// releaseCopy removes the Ingress from the owners of the copy, deleting the copy when it is left without any owner
+func releaseCopy(k8sClient client.Client, ctx context.Context, ingress *networkingv1.Ingress, targetCopy client.Object) {
+	kind := "Secret"
+	if _, ok := targetCopy.(*corev1.ConfigMap); ok {
+		kind = "ConfigMap"
+	}
+
+	targetCopyName := client.ObjectKeyFromObject(targetCopy)
+
+	var ownerReferences []metav1.OwnerReference
+	for _, ownerReference := range targetCopy.GetOwnerReferences() {
+		if ownerReference.UID != ingress.UID {
+			ownerReferences = append(ownerReferences, ownerReference)
+		}
+	}
+
+	// Delete the copy ourselves, as the garbage collector ignores objects without owners, unless another Ingress still
+	// uses it without owning it
+	if len(ownerReferences) == 0 {
+		ingresses, err := index.NewLookup(k8sClient).IngressesUsing(ctx, targetCopyName)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if len(ingresses) == 0 {
+			err = k8sClient.Delete(ctx, targetCopy)
+			if err != nil {
+				log.Errorf("failed to delete %s [%s] no longer used by any Ingress: %v", kind, targetCopyName, err)
+				return
+			}
+
+			log.Infof("Successfully deleted %s [%s] no longer used by any Ingress", kind, targetCopyName)
+			return
+		}
+
+		log.Debugf("Keeping %s [%s] as it is still used by Ingress [%s/%s]", kind, targetCopyName, ingresses[0].Namespace, ingresses[0].Name)
+	}
+
+	targetCopy.SetOwnerReferences(ownerReferences)
+
+	err := k8sClient.Update(ctx, targetCopy)
+	if err != nil {
+		log.Errorf("failed to remove Ingress [%s/%s] as owner of %s [%s]: %v", ingress.Namespace, ingress.Name, kind, targetCopyName, err)
+		return
+	}
+
+	log.Infof("Successfully removed Ingress [%s/%s] as owner of %s [%s]", ingress.Namespace, ingress.Name, kind, targetCopyName)
+}
@@ -4,6 +4,10 @@ import (
 	"context"
 	"fmt"
 
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/policy"
+	"tls-secret-injector/pkg/replica"
+
 	log "github.com/sirupsen/logrus"
 
 	networkingv1 "k8s.io/api/networking/v1"
@@ -13,22 +17,23 @@ import (
 )
 
 type reconciler struct {
-	client          client.Client
-	sourceNamespace string
+	*copier
 }
 
-func newReconciler(client client.Client, sourceNamespace string) *reconciler {
+func newReconciler(client client.Client, secretSource backend.SecretSource, policies *policy.Resolver, writer *replica.Writer, ownerReferences bool) *reconciler {
 	return &reconciler{
-		client:          client,
-		sourceNamespace: sourceNamespace,
+		copier: newCopier(client, secretSource, policies, writer, ownerReferences),
 	}
 }
 
 func (r *reconciler) Reconcile(ctx context.Context, request reconcile.Request) (result reconcile.Result, err error) {
 	log.Debugf("Received request to reconcile Ingress [%s]", request.NamespacedName)
 
+	// Fetch the intermediates Secrets once for all copies
+	ctx = replica.WithIntermediatesCache(ctx)
+
 	// Check if the request is the same as the source
-	if request.Namespace == r.sourceNamespace {
+	if r.source.IsSourceNamespace(ctx, request.Namespace) {
 		log.Debugf("Skipping mutation of Ingress [%s/%s] from the same namespace as the source", request.Namespace, request.Name)
 		return
 	}
@@ -48,7 +53,12 @@ func (r *reconciler) Reconcile(ctx context.Context, request reconcile.Request) (
 	}
 
 	// Create new Secrets by copying Secrets from the source namespace
-	copySecretsFromIngress(r.client, ctx, ingress, r.sourceNamespace, request.Namespace)
+	r.copySecretsFromIngress(ctx, ingress, request.Namespace)
+
+	// Release the Secrets no longer used by the Ingress
+	if r.ownerReferences {
+		releaseSecretsFromIngress(r.client, ctx, ingress)
+	}
 
 	return
 }
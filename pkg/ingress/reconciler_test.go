@@ -5,10 +5,19 @@ import (
 	"reflect"
 	"testing"
 
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/index"
+	"tls-secret-injector/pkg/index/indexfake"
+	"tls-secret-injector/pkg/policy"
+	"tls-secret-injector/pkg/replica"
+
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -48,8 +57,11 @@ func TestReconcile(t *testing.T) {
 			test.objects = append(test.objects, &test.ingress)
 
 			// Create a client and the reconciler
-			fakeClient := fake.NewClientBuilder().WithObjects(test.objects...).Build()
-			reconciler := newReconciler(fakeClient, "source")
+			fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(test.objects...).Build(), index.Register)
+			policies := policy.NewResolver(fakeClient, nil, policy.Policy{Conflict: policy.ConflictSkip})
+			secretSource := backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil)
+			writer := replica.NewWriter(fakeClient, secretSource, policies, record.NewFakeRecorder(10))
+			reconciler := newReconciler(fakeClient, secretSource, policies, writer, true)
 
 			// Reconcile and check for errors
 			request := reconcile.Request{
@@ -80,3 +92,188 @@ func TestReconcile(t *testing.T) {
 		})
 	}
 }
+
+func TestReconcileOwnerReferences(t *testing.T) {
+	ingress := newIngress("target")
+	ingress.UID = "ingress-uid"
+
+	otherIngress := newIngress("target")
+	otherIngress.Name = "other-example-io"
+	otherIngress.UID = "other-ingress-uid"
+
+	tests := map[string]struct {
+		ingress         networkingv1.Ingress
+		objects         []client.Object
+		ownerReferences []metav1.OwnerReference
+		deleted         bool
+	}{
+		"own created target secret": {
+			ingress: *ingress,
+			objects: []client.Object{
+				newSecret("source"),
+			},
+			ownerReferences: []metav1.OwnerReference{newOwnerReference(ingress)},
+		},
+		"own existing target secret": {
+			ingress: *ingress,
+			objects: []client.Object{
+				newSecret("source"),
+				newManagedSecret("target", newOwnerReference(otherIngress)),
+			},
+			ownerReferences: []metav1.OwnerReference{newOwnerReference(otherIngress), newOwnerReference(ingress)},
+		},
+		"release unused target secret": {
+			ingress: networkingv1.Ingress{ObjectMeta: ingress.ObjectMeta},
+			objects: []client.Object{
+				newManagedSecret("target", newOwnerReference(otherIngress), newOwnerReference(ingress)),
+			},
+			ownerReferences: []metav1.OwnerReference{newOwnerReference(otherIngress)},
+		},
+		"delete unused target secret": {
+			ingress: networkingv1.Ingress{ObjectMeta: ingress.ObjectMeta},
+			objects: []client.Object{
+				newManagedSecret("target", newOwnerReference(ingress)),
+			},
+			deleted: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.objects = append(test.objects, &test.ingress)
+
+			// Create a client and the reconciler
+			fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(test.objects...).Build(), index.Register)
+			policies := policy.NewResolver(fakeClient, nil, policy.Policy{Conflict: policy.ConflictSkip})
+			secretSource := backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil)
+			writer := replica.NewWriter(fakeClient, secretSource, policies, record.NewFakeRecorder(10))
+			reconciler := newReconciler(fakeClient, secretSource, policies, writer, true)
+
+			// Reconcile and check for errors
+			request := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: test.ingress.Namespace,
+					Name:      test.ingress.Name,
+				},
+			}
+
+			_, err := reconciler.Reconcile(context.TODO(), request)
+			assert.NoError(t, err)
+
+			// Check the owners of the target Secret
+			targetSecretName := types.NamespacedName{
+				Namespace: "target",
+				Name:      "tls-example-io",
+			}
+
+			var targetSecret corev1.Secret
+			err = fakeClient.Get(context.TODO(), targetSecretName, &targetSecret)
+
+			if test.deleted {
+				assert.True(t, errors.IsNotFound(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.ownerReferences, targetSecret.OwnerReferences)
+		})
+	}
+}
+
+func TestReconcilePushedSecret(t *testing.T) {
+	ingress := newIngress("target")
+	ingress.UID = "ingress-uid"
+
+	pushedSecret := newManagedSecret("target")
+	pushedSecret.Annotations = map[string]string{replica.PushedAnnotation: "true"}
+
+	// Create a client and the reconciler adding the Ingresses to the owners of their Secrets
+	fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(newSecret("source"), pushedSecret, ingress).Build(), index.Register)
+	policies := policy.NewResolver(fakeClient, nil, policy.Policy{Conflict: policy.ConflictSkip})
+	secretSource := backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil)
+	writer := replica.NewWriter(fakeClient, secretSource, policies, record.NewFakeRecorder(10))
+	reconciler := newReconciler(fakeClient, secretSource, policies, writer, true)
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "target", Name: ingress.Name}}
+	targetSecretName := types.NamespacedName{Namespace: "target", Name: "tls-example-io"}
+
+	// Verify that the Ingress does not own the pushed copy, which the garbage collector would delete along with it
+	_, err := reconciler.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+
+	targetSecret := &corev1.Secret{}
+	assert.NoError(t, fakeClient.Get(context.TODO(), targetSecretName, targetSecret))
+	assert.Empty(t, targetSecret.OwnerReferences)
+
+	// Verify that the pushed copy survives the Ingress no longer using it, and then the Ingress being deleted
+	usedIngress := &networkingv1.Ingress{}
+	assert.NoError(t, fakeClient.Get(context.TODO(), request.NamespacedName, usedIngress))
+
+	usedIngress.Spec.TLS = nil
+	assert.NoError(t, fakeClient.Update(context.TODO(), usedIngress))
+
+	_, err = reconciler.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+
+	// Without the Ingress among its owners, the garbage collector keeps the copy once the Ingress is deleted
+	assert.NoError(t, fakeClient.Delete(context.TODO(), usedIngress))
+
+	assert.NoError(t, fakeClient.Get(context.TODO(), targetSecretName, targetSecret))
+	assert.Empty(t, targetSecret.OwnerReferences)
+}
+
+func TestReconcilePublicPolicy(t *testing.T) {
+	for _, public := range []policy.PublicOutput{policy.PublicSecret, policy.PublicConfigMap} {
+		t.Run(string(public), func(t *testing.T) {
+			ingress := newIngress("target")
+
+			// Create a client and the reconciler with a policy only copying the public certificates
+			fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(newSecret("source"), ingress).Build(), index.Register)
+			policies := policy.NewResolver(fakeClient, nil, policy.Policy{Conflict: policy.ConflictSkip, Public: public})
+			secretSource := backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil)
+			writer := replica.NewWriter(fakeClient, secretSource, policies, record.NewFakeRecorder(10))
+			reconciler := newReconciler(fakeClient, secretSource, policies, writer, true)
+
+			_, err := reconciler.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "target", Name: ingress.Name}})
+			assert.NoError(t, err)
+
+			// Verify that no copy without the private key was made for the Ingress
+			targetName := types.NamespacedName{Namespace: "target", Name: "tls-example-io"}
+
+			err = fakeClient.Get(context.TODO(), targetName, &corev1.Secret{})
+			assert.True(t, errors.IsNotFound(err))
+
+			err = fakeClient.Get(context.TODO(), targetName, &corev1.ConfigMap{})
+			assert.True(t, errors.IsNotFound(err))
+		})
+	}
+}
+
+func TestReconcileReleaseConfigMap(t *testing.T) {
+	ingress := newIngress("target")
+	ingress.UID = "ingress-uid"
+	ingress.Spec.TLS = nil
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "target",
+			Name:            "tls-example-io",
+			Labels:          replica.Labels("tls-example-io"),
+			OwnerReferences: []metav1.OwnerReference{newOwnerReference(ingress)},
+		},
+	}
+
+	// Create a client and the reconciler
+	fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(configMap, ingress).Build(), index.Register)
+	policies := policy.NewResolver(fakeClient, nil, policy.Policy{Conflict: policy.ConflictSkip})
+	secretSource := backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil)
+	writer := replica.NewWriter(fakeClient, secretSource, policies, record.NewFakeRecorder(10))
+	reconciler := newReconciler(fakeClient, secretSource, policies, writer, true)
+
+	_, err := reconciler.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "target", Name: ingress.Name}})
+	assert.NoError(t, err)
+
+	// Verify that the ConfigMap no longer used by the Ingress was deleted
+	err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: "target", Name: "tls-example-io"}, &corev1.ConfigMap{})
+	assert.True(t, errors.IsNotFound(err))
+}
@@ -44,3 +44,14 @@ func newSecret(namespace string) *corev1.Secret {
 		},
 	}
 }
+
+func newManagedSecret(namespace string, ownerReferences ...metav1.OwnerReference) *corev1.Secret {
+	secret := newSecret(namespace)
+	secret.Labels = map[string]string{
+		"app.kubernetes.io/name":          "tls-secret-injector",
+		"tls-secret-injector/source-name": secret.Name,
+	}
+	secret.OwnerReferences = ownerReferences
+
+	return secret
+}
@@ -0,0 +1,69 @@
+package namespace
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/policy"
+	"tls-secret-injector/pkg/replica"
+	"tls-secret-injector/pkg/tracing"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+func NewController(mgr manager.Manager, secretSource backend.SecretSource, policies *policy.Resolver, selector labels.Selector, secretNames []string, resync func(ctx context.Context, name types.NamespacedName)) error {
+	// Setup the reconciler
+	writer := replica.NewWriter(mgr.GetClient(), secretSource, policies, mgr.GetEventRecorderFor("tls-secret-injector"))
+
+	namespaceController, err := controller.New("namespace", mgr, controller.Options{
+		Reconciler: tracing.Reconciler("namespace", newReconciler(mgr.GetClient(), secretSource, writer, selector, secretNames, resync)),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to set up Namespace controller: %v", err)
+	}
+
+	// Watch Namespace and enqueue Namespace object key
+	err = namespaceController.Watch(
+		&source.Kind{
+			Type: &corev1.Namespace{},
+		},
+		&handler.EnqueueRequestForObject{},
+		predicate.Funcs{
+			UpdateFunc: func(event event.UpdateEvent) bool {
+				// Only the labels of a namespace decide whether Secrets are provisioned into it
+				return !reflect.DeepEqual(event.ObjectOld.GetLabels(), event.ObjectNew.GetLabels())
+			},
+			DeleteFunc: func(event event.DeleteEvent) bool {
+				log.Debugf(
+					"Skipping reconciliation of Namespace [%s] as it has been deleted",
+					event.Object.GetName(),
+				)
+				return false
+			},
+			GenericFunc: func(event event.GenericEvent) bool {
+				log.Debugf(
+					"Skipping reconciliation of Namespace [%s] for the generic event type",
+					event.Object.GetName(),
+				)
+				return false
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("unable to watch Namespace: %v", err)
+	}
+
+	return nil
+}
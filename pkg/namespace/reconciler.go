@@ -0,0 +1,193 @@
+package namespace
+
+import (
+	"context"
+	"fmt"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/index"
+	"tls-secret-injector/pkg/replica"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+type reconciler struct {
+	client      client.Client
+	source      backend.SecretSource
+	writer      *replica.Writer
+	selector    labels.Selector
+	secretNames []string
+
+	// resync enqueues a source Secret into the Secret controller, which updates its outdated copies
+	resync func(ctx context.Context, name types.NamespacedName)
+}
+
+func newReconciler(client client.Client, secretSource backend.SecretSource, writer *replica.Writer, selector labels.Selector, secretNames []string, resync func(ctx context.Context, name types.NamespacedName)) *reconciler {
+	return &reconciler{
+		client:      client,
+		source:      secretSource,
+		writer:      writer,
+		selector:    selector,
+		secretNames: secretNames,
+		resync:      resync,
+	}
+}
+
+func (r *reconciler) Reconcile(ctx context.Context, request reconcile.Request) (result reconcile.Result, err error) {
+	log.Debugf("Received request to reconcile Namespace [%s]", request.Name)
+
+	// Fetch the Namespace from cache
+	namespace := &corev1.Namespace{}
+
+	err = r.client.Get(ctx, request.NamespacedName, namespace)
+	if errors.IsNotFound(err) {
+		log.Debugf("Skipping reconciliation of Namespace [%s] as it no longer exists: %v", request.Name, err)
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("could not fetch the Namespace [%s]: %v", request.Name, err)
+		log.Error(err)
+		return
+	}
+
+	// The copies owned by a terminating namespace are deleted together with it
+	if namespace.Status.Phase == corev1.NamespaceTerminating || r.source.IsSourceNamespace(ctx, namespace.Name) {
+		log.Debugf("Skipping reconciliation of Namespace [%s] as it is terminating or a source namespace", request.Name)
+		return
+	}
+
+	if !r.selector.Matches(labels.Set(namespace.Labels)) {
+		err = r.releaseSecrets(ctx, namespace)
+		if err != nil {
+			log.Error(err)
+		}
+		return
+	}
+
+	// Copy the configured source Secrets into the namespace, making it their owner
+	ownerReferences := []metav1.OwnerReference{
+		{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Namespace",
+			Name:       namespace.Name,
+			UID:        namespace.UID,
+		},
+	}
+
+	for _, secretName := range r.secretNames {
+		err = r.provisionSecret(ctx, namespace, secretName, ownerReferences)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+	}
+
+	return
+}
+
+// provisionSecret copies the source Secret into the namespace, leaving an existing Secret to the conflict policy, and
+// resyncs the source Secret when its existing copy is outdated
+func (r *reconciler) provisionSecret(ctx context.Context, namespace *corev1.Namespace, secretName string, ownerReferences []metav1.OwnerReference) error {
+	targetSecretName := types.NamespacedName{
+		Namespace: namespace.Name,
+		Name:      secretName,
+	}
+
+	sourceSecret, err := r.source.Get(ctx, secretName)
+	if err != nil {
+		return fmt.Errorf("could not fetch the source Secret [%s]: %v", secretName, err)
+	}
+
+	created, err := r.writer.Create(ctx, sourceSecret, targetSecretName, ownerReferences)
+	if err != nil {
+		return fmt.Errorf("failed to create the target Secret [%s]: %v", targetSecretName, err)
+	}
+
+	if created {
+		log.Infof("Successfully provisioned Secret [%s]", targetSecretName)
+		return nil
+	}
+
+	// Let the Secret controller update the outdated copy, which checks the data and rolls it out first
+	outdated, err := r.writer.IsOutdated(ctx, sourceSecret, targetSecretName)
+	if err != nil {
+		return err
+	}
+	if outdated {
+		sourceSecretName := types.NamespacedName{Namespace: sourceSecret.Namespace, Name: sourceSecret.Name}
+
+		log.Infof("Resyncing source Secret [%s] as its provisioned copy [%s] is outdated", sourceSecretName, targetSecretName)
+		r.resync(ctx, sourceSecretName)
+	}
+
+	return nil
+}
+
+// releaseSecrets removes the namespace from the owners of the copies provisioned into it, deleting the copies that
+// are left without any owner
+func (r *reconciler) releaseSecrets(ctx context.Context, namespace *corev1.Namespace) error {
+	secretList := &corev1.SecretList{}
+
+	err := r.client.List(ctx, secretList, client.InNamespace(namespace.Name), client.MatchingLabels(replica.ManagedLabels()))
+	if err != nil {
+		return fmt.Errorf("could not list Secrets in namespace [%s]: %v", namespace.Name, err)
+	}
+
+	for i := range secretList.Items {
+		targetSecret := &secretList.Items[i]
+		targetSecretName := types.NamespacedName{
+			Namespace: targetSecret.Namespace,
+			Name:      targetSecret.Name,
+		}
+
+		var ownerReferences []metav1.OwnerReference
+		for _, ownerReference := range targetSecret.OwnerReferences {
+			if ownerReference.UID != namespace.UID {
+				ownerReferences = append(ownerReferences, ownerReference)
+			}
+		}
+
+		if len(ownerReferences) == len(targetSecret.OwnerReferences) {
+			continue
+		}
+
+		// Keep the copies that Ingresses use without owning them, such as when owner references are disabled
+		if len(ownerReferences) == 0 {
+			var ingresses []networkingv1.Ingress
+
+			ingresses, err = index.NewLookup(r.client).IngressesUsing(ctx, targetSecretName)
+			if err != nil {
+				return err
+			}
+			if len(ingresses) == 0 {
+				err = r.client.Delete(ctx, targetSecret)
+				if err != nil {
+					return fmt.Errorf("failed to delete Secret [%s] no longer provisioned: %v", targetSecretName, err)
+				}
+
+				log.Infof("Successfully deleted Secret [%s] no longer provisioned", targetSecretName)
+				continue
+			}
+
+			log.Debugf("Keeping Secret [%s] no longer provisioned as it is still used by Ingress [%s/%s]", targetSecretName, ingresses[0].Namespace, ingresses[0].Name)
+		}
+
+		targetSecret.OwnerReferences = ownerReferences
+
+		err = r.client.Update(ctx, targetSecret)
+		if err != nil {
+			return fmt.Errorf("failed to remove Namespace [%s] as owner of Secret [%s]: %v", namespace.Name, targetSecretName, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package namespace
+
+import (
+	"context"
+	"testing"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/index"
+	"tls-secret-injector/pkg/index/indexfake"
+	"tls-secret-injector/pkg/policy"
+	"tls-secret-injector/pkg/replica"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcile(t *testing.T) {
+	sourceSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "tls-wildcard-example-io",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       []byte("certificate"),
+			corev1.TLSPrivateKeyKey: []byte("private key"),
+		},
+	}
+
+	namespaceOwnerReference := metav1.OwnerReference{
+		APIVersion: "v1",
+		Kind:       "Namespace",
+		Name:       "preview-123",
+		UID:        "namespace-uid",
+	}
+
+	newProvisionedSecret := func(ownerReferences ...metav1.OwnerReference) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "preview-123",
+				Name:            sourceSecret.Name,
+				Labels:          replica.Labels(sourceSecret.Name),
+				OwnerReferences: ownerReferences,
+			},
+			Type: corev1.SecretTypeTLS,
+			Data: sourceSecret.Data,
+		}
+	}
+
+	tests := map[string]struct {
+		labels          map[string]string
+		conflict        policy.ConflictPolicy
+		objects         []client.Object
+		ownerReferences []metav1.OwnerReference
+		deleted         bool
+		resyncs         []types.NamespacedName
+	}{
+		"provision matching namespace": {
+			labels:          map[string]string{"environment": "preview"},
+			ownerReferences: []metav1.OwnerReference{namespaceOwnerReference},
+		},
+		"resync outdated secret of matching namespace": {
+			labels: map[string]string{"environment": "preview"},
+			objects: []client.Object{
+				newProvisionedSecret(namespaceOwnerReference),
+			},
+			ownerReferences: []metav1.OwnerReference{namespaceOwnerReference},
+			resyncs:         []types.NamespacedName{{Namespace: sourceSecret.Namespace, Name: sourceSecret.Name}},
+		},
+		"adopt existing secret of matching namespace": {
+			labels:   map[string]string{"environment": "preview"},
+			conflict: policy.ConflictAdopt,
+			objects: []client.Object{
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "preview-123",
+						Name:      sourceSecret.Name,
+					},
+					Type: corev1.SecretTypeTLS,
+					Data: map[string][]byte{corev1.TLSCertKey: []byte("hand-copied certificate")},
+				},
+			},
+			ownerReferences: []metav1.OwnerReference{namespaceOwnerReference},
+		},
+		"release namespace no longer matching": {
+			objects: []client.Object{
+				newProvisionedSecret(namespaceOwnerReference, metav1.OwnerReference{UID: "ingress-uid"}),
+			},
+			ownerReferences: []metav1.OwnerReference{{UID: "ingress-uid"}},
+		},
+		"delete secret of namespace no longer matching": {
+			objects: []client.Object{
+				newProvisionedSecret(namespaceOwnerReference),
+			},
+			deleted: true,
+		},
+		"skip namespace not matching": {
+			deleted: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			namespace := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "preview-123",
+					UID:    "namespace-uid",
+					Labels: test.labels,
+				},
+			}
+			test.objects = append(test.objects, sourceSecret, namespace)
+
+			// Create a client and the reconciler
+			fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(test.objects...).Build(), index.Register)
+			conflict := test.conflict
+			if conflict == "" {
+				conflict = policy.ConflictSkip
+			}
+			policies := policy.NewResolver(fakeClient, nil, policy.Policy{Conflict: conflict})
+			secretSource := backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil)
+			writer := replica.NewWriter(fakeClient, secretSource, policies, record.NewFakeRecorder(10))
+			selector, _ := labels.Parse("environment=preview")
+
+			var resyncs []types.NamespacedName
+			resync := func(ctx context.Context, name types.NamespacedName) {
+				resyncs = append(resyncs, name)
+			}
+
+			reconciler := newReconciler(fakeClient, secretSource, writer, selector, []string{sourceSecret.Name}, resync)
+
+			// Reconcile and check for errors
+			request := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name: namespace.Name,
+				},
+			}
+
+			_, err := reconciler.Reconcile(context.TODO(), request)
+			assert.NoError(t, err)
+			assert.Equal(t, test.resyncs, resyncs)
+
+			// Check the provisioned Secret
+			var targetSecret corev1.Secret
+			err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: namespace.Name, Name: sourceSecret.Name}, &targetSecret)
+
+			if test.deleted {
+				assert.True(t, errors.IsNotFound(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.True(t, replica.IsManaged(&targetSecret))
+			assert.Equal(t, "certificate", string(targetSecret.Data[corev1.TLSCertKey]))
+			assert.Equal(t, test.ownerReferences, targetSecret.OwnerReferences)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package policy
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Chain defines how the certificate chain written into the copies is completed
+type Chain struct {
+	// IntermediatesSecret names the source Secret holding the intermediate certificates appended to the tls.crt
+	IntermediatesSecret string `json:"intermediatesSecret"`
+	// IntermediatesKey is the key of the intermediates Secret holding them, tls.crt by default
+	IntermediatesKey string `json:"intermediatesKey,omitempty"`
+}
+
+// Key returns the key of the intermediates Secret holding the intermediate certificates
+func (c *Chain) Key() string {
+	if c.IntermediatesKey == "" {
+		return corev1.TLSCertKey
+	}
+
+	return c.IntermediatesKey
+}
+
+// Validate returns an error when the chain does not name its intermediates Secret
+func (c *Chain) Validate() error {
+	if c == nil {
+		return nil
+	}
+
+	if c.IntermediatesSecret == "" {
+		return fmt.Errorf("no intermediates Secret")
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// KeyMapping defines which keys of the source Secret are written into the copies, and under which names
+type KeyMapping struct {
+	// Include lists the keys that are copied, all of them when empty
+	Include []string `json:"include,omitempty"`
+	// Exclude lists the keys that are never copied
+	Exclude []string `json:"exclude,omitempty"`
+	// Rename maps keys of the source Secret to the keys they are written under
+	Rename map[string]string `json:"rename,omitempty"`
+	// Combined names a derived key holding the tls.crt followed by the tls.key of the source Secret
+	Combined string `json:"combined,omitempty"`
+}
+
+// Apply returns the data written into a copy of a Secret holding the given data
+func (m *KeyMapping) Apply(data map[string][]byte) map[string][]byte {
+	if m == nil {
+		return data
+	}
+
+	mappedData := map[string][]byte{}
+
+	for key, value := range data {
+		if len(m.Include) > 0 && !contains(m.Include, key) || contains(m.Exclude, key) {
+			continue
+		}
+
+		if newKey, ok := m.Rename[key]; ok {
+			key = newKey
+		}
+
+		mappedData[key] = value
+	}
+
+	certificate, hasCertificate := data[corev1.TLSCertKey]
+	privateKey, hasPrivateKey := data[corev1.TLSPrivateKeyKey]
+
+	if m.Combined != "" && hasCertificate && hasPrivateKey {
+		combined := strings.TrimRight(string(certificate), "\n") + "\n" + string(privateKey)
+		mappedData[m.Combined] = []byte(combined)
+	}
+
+	return mappedData
+}
+
+// Validate returns an error when the mapping writes invalid keys or several keys under the same name
+func (m *KeyMapping) Validate() error {
+	if m == nil {
+		return nil
+	}
+
+	written := map[string]string{}
+
+	for key, newKey := range m.Rename {
+		if errs := validation.IsConfigMapKey(newKey); len(errs) > 0 {
+			return fmt.Errorf("invalid key [%s] to rename [%s] to: %s", newKey, key, strings.Join(errs, ", "))
+		}
+
+		if otherKey, ok := written[newKey]; ok {
+			return fmt.Errorf("keys [%s] and [%s] are both renamed to [%s]", otherKey, key, newKey)
+		}
+		written[newKey] = key
+	}
+
+	if m.Combined != "" {
+		if errs := validation.IsConfigMapKey(m.Combined); len(errs) > 0 {
+			return fmt.Errorf("invalid combined key [%s]: %s", m.Combined, strings.Join(errs, ", "))
+		}
+
+		if key, ok := written[m.Combined]; ok {
+			return fmt.Errorf("key [%s] is renamed to the combined key [%s]", key, m.Combined)
+		}
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,62 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestApply(t *testing.T) {
+	data := map[string][]byte{
+		corev1.TLSCertKey:       []byte("certificate\n"),
+		corev1.TLSPrivateKeyKey: []byte("private key\n"),
+		"ca.crt":                []byte("ca certificate\n"),
+	}
+
+	tests := map[string]struct {
+		mapping *KeyMapping
+		data    map[string][]byte
+	}{
+		"keep all keys without mapping": {
+			data: data,
+		},
+		"include keys": {
+			mapping: &KeyMapping{Include: []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey}},
+			data: map[string][]byte{
+				corev1.TLSCertKey:       []byte("certificate\n"),
+				corev1.TLSPrivateKeyKey: []byte("private key\n"),
+			},
+		},
+		"exclude and rename keys": {
+			mapping: &KeyMapping{
+				Exclude: []string{"ca.crt"},
+				Rename:  map[string]string{corev1.TLSCertKey: "cert.pem", corev1.TLSPrivateKeyKey: "key.pem"},
+			},
+			data: map[string][]byte{
+				"cert.pem": []byte("certificate\n"),
+				"key.pem":  []byte("private key\n"),
+			},
+		},
+		"derive combined key": {
+			mapping: &KeyMapping{Include: []string{"ca.crt"}, Combined: "tls.pem"},
+			data: map[string][]byte{
+				"ca.crt":  []byte("ca certificate\n"),
+				"tls.pem": []byte("certificate\nprivate key\n"),
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.data, test.mapping.Apply(data))
+		})
+	}
+}
+
+func TestValidateKeyMapping(t *testing.T) {
+	assert.NoError(t, (&KeyMapping{Rename: map[string]string{corev1.TLSCertKey: "cert.pem"}, Combined: "tls.pem"}).Validate())
+	assert.Error(t, (&KeyMapping{Rename: map[string]string{corev1.TLSCertKey: "cert/pem"}}).Validate())
+	assert.Error(t, (&KeyMapping{Rename: map[string]string{corev1.TLSCertKey: "cert.pem", corev1.TLSPrivateKeyKey: "cert.pem"}}).Validate())
+	assert.Error(t, (&KeyMapping{Rename: map[string]string{corev1.TLSCertKey: "tls.pem"}, Combined: "tls.pem"}).Validate())
+}
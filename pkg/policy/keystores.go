@@ -0,0 +1,45 @@
+package policy
+
+import (
+	"fmt"
+)
+
+// KeystoreFormat is the format of the keystores generated into the copies
+type KeystoreFormat string
+
+const (
+	// KeystorePKCS12 generates the keystore.p12 and truststore.p12 keys
+	KeystorePKCS12 KeystoreFormat = "pkcs12"
+	// KeystoreJKS generates the keystore.jks and truststore.jks keys
+	KeystoreJKS KeystoreFormat = "jks"
+)
+
+// Keystores defines the keystores generated from the tls.crt, tls.key and ca.crt of the source Secret
+type Keystores struct {
+	// Formats lists the formats of the generated keystores
+	Formats []KeystoreFormat `json:"formats"`
+	// PasswordSecret names the Secret of the target namespace whose password key protects the keystores, which is
+	// generated into the copies when empty
+	PasswordSecret string `json:"passwordSecret,omitempty"`
+}
+
+// Validate returns an error when the keystores hold unknown formats
+func (k *Keystores) Validate() error {
+	if k == nil {
+		return nil
+	}
+
+	if len(k.Formats) == 0 {
+		return fmt.Errorf("no keystore formats")
+	}
+
+	for _, format := range k.Formats {
+		switch format {
+		case KeystorePKCS12, KeystoreJKS:
+		default:
+			return fmt.Errorf("unknown keystore format [%s]", format)
+		}
+	}
+
+	return nil
+}
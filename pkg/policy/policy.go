@@ -0,0 +1,272 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+
+	"tls-secret-injector/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// ConflictPolicy decides what happens when a copy would replace an existing Secret not managed by the injector
+type ConflictPolicy string
+
+const (
+	// ConflictSkip leaves the existing Secret alone
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictAdopt takes over the existing Secret, keeping its metadata, and manages it from then on
+	ConflictAdopt ConflictPolicy = "adopt"
+	// ConflictOverwrite replaces the existing Secret with a new copy
+	ConflictOverwrite ConflictPolicy = "overwrite"
+)
+
+// Policy defines how Secrets are copied into the namespaces it applies to
+type Policy struct {
+	// Name identifies the policy in logs and Events
+	Name string `json:"name"`
+
+	// Namespaces lists glob patterns of the namespaces the policy applies to
+	Namespaces []string `json:"namespaces,omitempty"`
+	// NamespaceSelector selects the namespaces the policy applies to by label
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// AllowedSources lists glob patterns of the namespace/name Secrets that Ingresses may reference through annotations
+	AllowedSources []string `json:"allowedSources,omitempty"`
+
+	// Conflict decides what happens to existing Secrets not managed by the injector
+	Conflict ConflictPolicy `json:"conflict,omitempty"`
+
+	// Keys defines which keys are written into the copies, and under which names
+	Keys *KeyMapping `json:"keys,omitempty"`
+
+	// Keystores defines the keystores generated into the copies for consumers that cannot read PEM
+	Keystores *Keystores `json:"keystores,omitempty"`
+
+	// Chain defines how the certificate chain written into the copies is completed
+	Chain *Chain `json:"chain,omitempty"`
+
+	// Public restricts the copies to the public certificates, written into a Secret or a ConfigMap
+	Public PublicOutput `json:"public,omitempty"`
+}
+
+type config struct {
+	Policies []Policy `json:"policies"`
+}
+
+// AllowsSource returns whether Secrets may be copied from the given source Secret referenced through annotations
+func (p *Policy) AllowsSource(namespace, name string) bool {
+	for _, pattern := range p.AllowedSources {
+		if matched, _ := path.Match(pattern, namespace+"/"+name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Load reads the policies from a YAML file
+func Load(filename string) ([]Policy, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("could not read policy file [%s]: %v", filename, err)
+	}
+
+	policyConfig := &config{}
+
+	err = yaml.UnmarshalStrict(content, policyConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy file [%s]: %v", filename, err)
+	}
+
+	for _, policy := range policyConfig.Policies {
+		err = policy.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy [%s] in file [%s]: %v", policy.Name, filename, err)
+		}
+	}
+
+	return policyConfig.Policies, nil
+}
+
+// Validate returns an error when the policy holds unknown values
+func (p *Policy) Validate() error {
+	switch p.Conflict {
+	case "", ConflictSkip, ConflictAdopt, ConflictOverwrite:
+	default:
+		return fmt.Errorf("unknown conflict policy [%s]", p.Conflict)
+	}
+
+	switch p.Public {
+	case "", PublicSecret, PublicConfigMap:
+	default:
+		return fmt.Errorf("unknown public output [%s]", p.Public)
+	}
+
+	if p.Public != "" && p.Keystores != nil {
+		return fmt.Errorf("keystores cannot be generated into public copies")
+	}
+
+	err := p.Keys.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid key mapping: %v", err)
+	}
+
+	err = p.Keystores.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid keystores: %v", err)
+	}
+
+	err = p.Chain.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid chain: %v", err)
+	}
+
+	return nil
+}
+
+// withDefaults returns a copy of the policy where the unset fields are taken from the defaults
+func (p Policy) withDefaults(defaults Policy) *Policy {
+	if p.Conflict == "" {
+		p.Conflict = defaults.Conflict
+	}
+	if p.Keys == nil {
+		p.Keys = defaults.Keys
+	}
+	if p.Keystores == nil {
+		p.Keystores = defaults.Keystores
+	}
+	if p.Chain == nil {
+		p.Chain = defaults.Chain
+	}
+	if p.Public == "" {
+		p.Public = defaults.Public
+	}
+
+	return &p
+}
+
+// Resolver finds the policy applying to a namespace, which is the first one matching it
+type Resolver struct {
+	client   client.Client
+	policies []Policy
+	defaults Policy
+}
+
+// NewResolver returns a pointer to Resolver, where the defaults apply to the fields the policies do not set
+func NewResolver(client client.Client, policies []Policy, defaults Policy) *Resolver {
+	defaults.Name = "default"
+
+	return &Resolver{
+		client:   client,
+		policies: policies,
+		defaults: defaults,
+	}
+}
+
+// For returns the policy applying to the namespace, or the default policy when none does
+func (r *Resolver) For(ctx context.Context, namespace string) (targetPolicy *Policy, err error) {
+	ctx, span := tracing.Start(ctx, "Resolve policy", attribute.String("k8s.namespace", namespace))
+	defer func() {
+		if targetPolicy != nil {
+			span.SetAttributes(attribute.String("policy", targetPolicy.Name))
+		}
+		tracing.End(span, err)
+	}()
+
+	return r.resolve(ctx, namespace)
+}
+
+func (r *Resolver) resolve(ctx context.Context, namespace string) (*Policy, error) {
+	var namespaceLabels labels.Set
+
+	for i := range r.policies {
+		policy := &r.policies[i]
+
+		for _, pattern := range policy.Namespaces {
+			if matched, _ := path.Match(pattern, namespace); matched {
+				return policy.withDefaults(r.defaults), nil
+			}
+		}
+
+		if policy.NamespaceSelector == nil {
+			continue
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(policy.NamespaceSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace selector in policy [%s]: %v", policy.Name, err)
+		}
+
+		// Only fetch the namespace once some policy needs its labels
+		if namespaceLabels == nil {
+			targetNamespace := &corev1.Namespace{}
+
+			err = r.client.Get(ctx, types.NamespacedName{Name: namespace}, targetNamespace)
+			if err != nil {
+				return nil, fmt.Errorf("could not fetch namespace [%s]: %v", namespace, err)
+			}
+
+			namespaceLabels = labels.Set(targetNamespace.Labels)
+		}
+
+		if selector.Matches(namespaceLabels) {
+			return policy.withDefaults(r.defaults), nil
+		}
+	}
+
+	return r.defaults.withDefaults(r.defaults), nil
+}
+
+// CheckSource returns an error when the policy of the namespace does not allow copying from the Secret referenced
+// through annotations
+func (r *Resolver) CheckSource(ctx context.Context, namespace string, sourceSecretName types.NamespacedName) error {
+	namespacePolicy, err := r.For(ctx, namespace)
+	if err != nil {
+		return err
+	}
+
+	if !namespacePolicy.AllowsSource(sourceSecretName.Namespace, sourceSecretName.Name) {
+		return fmt.Errorf("policy [%s] does not allow namespace [%s] to copy from Secret [%s]", namespacePolicy.Name, namespace, sourceSecretName)
+	}
+
+	return nil
+}
+
+// IsIntermediatesSecret returns whether some policy completes the certificate chains with the named source Secret
+func (r *Resolver) IsIntermediatesSecret(name string) bool {
+	if r.defaults.Chain != nil && r.defaults.Chain.IntermediatesSecret == name {
+		return true
+	}
+
+	for _, policy := range r.policies {
+		if policy.Chain != nil && policy.Chain.IntermediatesSecret == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsPasswordSecret returns whether some policy protects the keystores of the copies with the named password Secret
+func (r *Resolver) IsPasswordSecret(name string) bool {
+	if r.defaults.Keystores != nil && r.defaults.Keystores.PasswordSecret == name {
+		return true
+	}
+
+	for _, policy := range r.policies {
+		if policy.Keystores != nil && policy.Keystores.PasswordSecret == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,64 @@
+package policy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestFor(t *testing.T) {
+	policies := []Policy{
+		{
+			Name:       "preview",
+			Namespaces: []string{"preview-*"},
+			Conflict:   ConflictOverwrite,
+		},
+		{
+			Name: "teams",
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"team": "payments"},
+			},
+		},
+	}
+
+	fakeClient := fake.NewClientBuilder().WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments", Labels: map[string]string{"team": "payments"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+	).Build()
+
+	resolver := NewResolver(fakeClient, policies, Policy{Conflict: ConflictSkip})
+
+	tests := map[string]struct {
+		name     string
+		conflict ConflictPolicy
+	}{
+		"preview-123": {name: "preview", conflict: ConflictOverwrite},
+		"payments":    {name: "teams", conflict: ConflictSkip},
+		"other":       {name: "default", conflict: ConflictSkip},
+	}
+
+	for namespace, test := range tests {
+		t.Run(namespace, func(t *testing.T) {
+			policy, err := resolver.For(context.TODO(), namespace)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.name, policy.Name)
+			assert.Equal(t, test.conflict, policy.Conflict)
+		})
+	}
+}
+
+func TestAllowsSource(t *testing.T) {
+	policy := &Policy{
+		AllowedSources: []string{"shared/*", "team-a/tls-team-a-io"},
+	}
+
+	assert.True(t, policy.AllowsSource("shared", "tls-example-io"))
+	assert.True(t, policy.AllowsSource("team-a", "tls-team-a-io"))
+	assert.False(t, policy.AllowsSource("team-a", "tls-example-io"))
+	assert.False(t, (&Policy{}).AllowsSource("shared", "tls-example-io"))
+}
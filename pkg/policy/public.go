@@ -0,0 +1,34 @@
+package policy
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PublicOutput restricts the copies to the public certificates of the source Secret
+type PublicOutput string
+
+const (
+	// PublicSecret writes the public certificates into an Opaque Secret
+	PublicSecret PublicOutput = "secret"
+	// PublicConfigMap writes the public certificates into a ConfigMap
+	PublicConfigMap PublicOutput = "configMap"
+)
+
+// PublicKeys lists the keys holding the public certificates of a Secret
+var PublicKeys = []string{corev1.TLSCertKey, "ca.crt"}
+
+// Filter returns the public certificates of the data, or all of it when the output is not restricted
+func (o PublicOutput) Filter(data map[string][]byte) map[string][]byte {
+	if o == "" {
+		return data
+	}
+
+	publicData := map[string][]byte{}
+	for _, key := range PublicKeys {
+		if value, ok := data[key]; ok {
+			publicData[key] = value
+		}
+	}
+
+	return publicData
+}
@@ -0,0 +1,66 @@
+package replica
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type intermediatesCacheKey struct{}
+
+// intermediatesCache holds the intermediates Secrets fetched during a single reconcile
+type intermediatesCache struct {
+	secrets map[types.NamespacedName]*corev1.Secret
+	lock    sync.Mutex
+}
+
+// WithIntermediatesCache returns a context under which every intermediates Secret is fetched only once, for the calls
+// of a single reconcile writing many copies
+func WithIntermediatesCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, intermediatesCacheKey{}, &intermediatesCache{
+		secrets: map[types.NamespacedName]*corev1.Secret{},
+	})
+}
+
+// getIntermediates returns the named intermediates Secret, from the namespace of the source Secret when Ingresses
+// reference it through annotations, and from the source otherwise
+func (w *Writer) getIntermediates(ctx context.Context, sourceSecret *corev1.Secret, name string) (*corev1.Secret, error) {
+	intermediatesName := types.NamespacedName{
+		Name: name,
+	}
+	if !w.source.IsSourceNamespace(ctx, sourceSecret.Namespace) {
+		intermediatesName.Namespace = sourceSecret.Namespace
+	}
+
+	cache, _ := ctx.Value(intermediatesCacheKey{}).(*intermediatesCache)
+	if cache != nil {
+		cache.lock.Lock()
+		defer cache.lock.Unlock()
+
+		if intermediatesSecret, ok := cache.secrets[intermediatesName]; ok {
+			return intermediatesSecret, nil
+		}
+	}
+
+	var intermediatesSecret *corev1.Secret
+	var err error
+
+	if intermediatesName.Namespace == "" {
+		intermediatesSecret, err = w.source.Get(ctx, name)
+	} else {
+		intermediatesSecret = &corev1.Secret{}
+		err = w.client.Get(ctx, intermediatesName, intermediatesSecret)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch the intermediates Secret [%s]: %v", intermediatesName, err)
+	}
+
+	if cache != nil {
+		cache.secrets[intermediatesName] = intermediatesSecret
+	}
+
+	return intermediatesSecret, nil
+}
@@ -0,0 +1,124 @@
+package replica
+
+import (
+	"context"
+	"fmt"
+
+	"tls-secret-injector/pkg/policy"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// createConfigMap creates a ConfigMap holding the public certificates of the source Secret and returns whether it was
+// written. An existing ConfigMap not managed by the injector is taken over unless the conflict policy skips it.
+func (w *Writer) createConfigMap(ctx context.Context, targetPolicy *policy.Policy, sourceSecret *corev1.Secret, data map[string][]byte, targetName types.NamespacedName, ownerReferences []metav1.OwnerReference) (bool, error) {
+	targetConfigMap := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.Version,
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       targetName.Namespace,
+			Name:            targetName.Name,
+			Labels:          Labels(sourceSecret.Name),
+			OwnerReferences: ownerReferences,
+		},
+	}
+	RenderConfigMap(sourceSecret, targetConfigMap, data)
+
+	// Check for an existing ConfigMap first, as the conflict would otherwise be run into each time, only reading its
+	// metadata
+	existingMetadata := NewConfigMapMetadata()
+
+	err := w.client.Get(ctx, targetName, existingMetadata)
+	if errors.IsNotFound(err) {
+		err = w.client.Create(ctx, targetConfigMap)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.IsAlreadyExists(err) {
+			return false, err
+		}
+
+		// Another request could have created the copy in the meantime
+		err = w.client.Get(ctx, targetName, existingMetadata)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if IsManaged(existingMetadata) {
+		return false, nil
+	}
+
+	if targetPolicy.Conflict != policy.ConflictAdopt && targetPolicy.Conflict != policy.ConflictOverwrite {
+		log.Debugf("Skipping creation of the target ConfigMap [%s] as it already exists and is not managed", targetName)
+
+		if w.isFirstSkip(existingMetadata.UID) {
+			w.recorder.Eventf(
+				existingMetadata,
+				corev1.EventTypeNormal,
+				"ConflictSkipped",
+				"Skipped copying Secret [%s/%s] as this ConfigMap is not managed by tls-secret-injector",
+				sourceSecret.Namespace,
+				sourceSecret.Name,
+			)
+		}
+		return false, nil
+	}
+
+	// ConfigMaps have no type, so they are always taken over in place
+	existingConfigMap := &corev1.ConfigMap{}
+
+	err = w.client.Get(ctx, targetName, existingConfigMap)
+	if err != nil {
+		return false, err
+	}
+
+	if existingConfigMap.Labels == nil {
+		existingConfigMap.Labels = map[string]string{}
+	}
+	for key, value := range Labels(sourceSecret.Name) {
+		existingConfigMap.Labels[key] = value
+	}
+
+	for _, ownerReference := range ownerReferences {
+		if !hasOwnerReference(existingConfigMap, ownerReference) {
+			existingConfigMap.OwnerReferences = append(existingConfigMap.OwnerReferences, ownerReference)
+		}
+	}
+
+	RenderConfigMap(sourceSecret, existingConfigMap, data)
+
+	err = w.client.Update(ctx, existingConfigMap)
+	if err != nil {
+		return false, fmt.Errorf("failed to adopt ConfigMap [%s]: %v", targetName, err)
+	}
+
+	log.Infof("Successfully adopted ConfigMap [%s]", targetName)
+	w.recorder.Eventf(
+		existingConfigMap,
+		corev1.EventTypeNormal,
+		"Adopted",
+		"Adopted this ConfigMap as a copy of Secret [%s/%s]",
+		sourceSecret.Namespace,
+		sourceSecret.Name,
+	)
+
+	return true, nil
+}
+
+// RenderConfigMap writes the data into the ConfigMap and records where the data comes from
+func RenderConfigMap(sourceSecret *corev1.Secret, targetConfigMap *corev1.ConfigMap, data map[string][]byte) {
+	targetConfigMap.Data = map[string]string{}
+	for key, value := range data {
+		targetConfigMap.Data[key] = string(value)
+	}
+
+	Annotate(targetConfigMap, sourceSecret, data)
+}
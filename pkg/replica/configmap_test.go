@@ -0,0 +1,113 @@
+package replica
+
+import (
+	"context"
+	"testing"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/policy"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCreatePublic(t *testing.T) {
+	sourceSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "tls-example-io",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       []byte("certificate"),
+			corev1.TLSPrivateKeyKey: []byte("private key"),
+			CABundleKey:             []byte("ca certificate"),
+		},
+	}
+
+	publicData := map[string]string{
+		corev1.TLSCertKey: "certificate",
+		CABundleKey:       "ca certificate",
+	}
+
+	tests := map[string]struct {
+		public    policy.PublicOutput
+		conflict  policy.ConflictPolicy
+		existing  *corev1.ConfigMap
+		written   bool
+		configMap map[string]string
+	}{
+		"create public secret": {
+			public:  policy.PublicSecret,
+			written: true,
+		},
+		"create configmap": {
+			public:    policy.PublicConfigMap,
+			written:   true,
+			configMap: publicData,
+		},
+		"skip existing configmap": {
+			public:    policy.PublicConfigMap,
+			conflict:  policy.ConflictSkip,
+			existing:  &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "target", Name: "tls-example-io"}, Data: map[string]string{"ca.crt": "other"}},
+			configMap: map[string]string{"ca.crt": "other"},
+		},
+		"adopt existing configmap": {
+			public:    policy.PublicConfigMap,
+			conflict:  policy.ConflictAdopt,
+			existing:  &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "target", Name: "tls-example-io"}, Data: map[string]string{"ca.crt": "other"}},
+			written:   true,
+			configMap: publicData,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var objects []client.Object
+			if test.existing != nil {
+				objects = append(objects, test.existing)
+			}
+
+			// Create a client and the writer
+			fakeClient := fake.NewClientBuilder().WithObjects(objects...).Build()
+			policies := policy.NewResolver(fakeClient, nil, policy.Policy{Conflict: test.conflict, Public: test.public})
+			writer := NewWriter(fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), policies, record.NewFakeRecorder(10))
+
+			targetName := types.NamespacedName{
+				Namespace: "target",
+				Name:      "tls-example-io",
+			}
+
+			written, err := writer.Create(context.TODO(), sourceSecret, targetName, nil)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.written, written)
+
+			// Check that only the public certificates were written
+			targetSecret := &corev1.Secret{}
+			err = fakeClient.Get(context.TODO(), targetName, targetSecret)
+
+			if test.configMap != nil {
+				assert.True(t, errors.IsNotFound(err))
+
+				targetConfigMap := &corev1.ConfigMap{}
+				err = fakeClient.Get(context.TODO(), targetName, targetConfigMap)
+
+				assert.NoError(t, err)
+				assert.Equal(t, test.configMap, targetConfigMap.Data)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, corev1.SecretTypeOpaque, targetSecret.Type)
+			assert.NotContains(t, targetSecret.Data, corev1.TLSPrivateKeyKey)
+			assert.Equal(t, "certificate", string(targetSecret.Data[corev1.TLSCertKey]))
+		})
+	}
+}
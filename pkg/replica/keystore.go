@@ -0,0 +1,183 @@
+package replica
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"tls-secret-injector/pkg/certificate"
+	"tls-secret-injector/pkg/policy"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// PKCS12KeystoreKey holds the private key and certificate chain as a PKCS #12 keystore
+	PKCS12KeystoreKey = "keystore.p12"
+	// PKCS12TruststoreKey holds the CA bundle as a PKCS #12 truststore
+	PKCS12TruststoreKey = "truststore.p12"
+	// JKSKeystoreKey holds the private key and certificate chain as a Java keystore
+	JKSKeystoreKey = "keystore.jks"
+	// JKSTruststoreKey holds the CA bundle as a Java keystore
+	JKSTruststoreKey = "truststore.jks"
+	// KeystorePasswordKey holds the generated password of the keystores
+	KeystorePasswordKey = "keystore.password"
+	// PasswordSecretKey is the key of the password Secret holding the password of the keystores
+	PasswordSecretKey = "password"
+)
+
+// withKeystores returns a copy of the data along with the keystores generated from the source Secret, and the hash of
+// the keystore settings they were generated with
+func (w *Writer) withKeystores(ctx context.Context, keystores *policy.Keystores, sourceSecret *corev1.Secret, namespace string, data map[string][]byte, existingData map[string][]byte) (map[string][]byte, string, error) {
+	password, generated, err := w.keystorePassword(ctx, keystores, namespace, existingData)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keystoreData, err := generateKeystores(keystores, sourceSecret, data, password)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if generated {
+		keystoreData[KeystorePasswordKey] = []byte(password)
+	}
+
+	return keystoreData, keystoresHash(keystores, password, generated), nil
+}
+
+// keystoresHash returns the hash of the keystore settings and of the password read from the password Secret, which
+// decide along with the data whether the keystores of a copy are up to date. Generated passwords are kept in the copy,
+// and left out as they do not change.
+func keystoresHash(keystores *policy.Keystores, password string, generated bool) string {
+	var formats []string
+	for _, format := range keystores.Formats {
+		formats = append(formats, string(format))
+	}
+
+	settings := map[string][]byte{
+		"formats":        []byte(strings.Join(formats, ",")),
+		"passwordSecret": []byte(keystores.PasswordSecret),
+	}
+	if !generated {
+		settings["password"] = []byte(password)
+	}
+
+	return DataHash(settings)
+}
+
+// expectedKeystoresHash returns the hash of the keystore settings the copy should have been generated with, or an
+// empty hash when the copy holds no keystores
+func (w *Writer) expectedKeystoresHash(ctx context.Context, targetPolicy *policy.Policy, sourceSecret *corev1.Secret, targetSecret *corev1.Secret) (string, error) {
+	if targetPolicy.Keystores == nil || sourceSecret.Type != corev1.SecretTypeTLS {
+		return "", nil
+	}
+
+	password, generated, err := w.keystorePassword(ctx, targetPolicy.Keystores, targetSecret.Namespace, targetSecret.Data)
+	if err != nil {
+		return "", err
+	}
+
+	return keystoresHash(targetPolicy.Keystores, password, generated), nil
+}
+
+// generateKeystores returns a copy of the data along with the keystores generated from the source Secret
+func generateKeystores(keystores *policy.Keystores, sourceSecret *corev1.Secret, data map[string][]byte, password string) (map[string][]byte, error) {
+	certificates, err := certificate.ParseCertificates(sourceSecret.Data[corev1.TLSCertKey])
+	if err != nil {
+		return nil, fmt.Errorf("could not read the certificates of Secret [%s/%s]: %v", sourceSecret.Namespace, sourceSecret.Name, err)
+	}
+
+	privateKey, err := certificate.ParsePrivateKey(sourceSecret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("could not read the private key of Secret [%s/%s]: %v", sourceSecret.Namespace, sourceSecret.Name, err)
+	}
+
+	// The truststores are only generated when the source Secret holds a CA bundle
+	var caCertificates []*x509.Certificate
+	if _, ok := sourceSecret.Data[CABundleKey]; ok {
+		caCertificates, err = certificate.ParseCertificates(sourceSecret.Data[CABundleKey])
+		if err != nil {
+			return nil, fmt.Errorf("could not read the CA bundle of Secret [%s/%s]: %v", sourceSecret.Namespace, sourceSecret.Name, err)
+		}
+	}
+
+	keystoreData := map[string][]byte{}
+	for key, value := range data {
+		keystoreData[key] = value
+	}
+
+	for _, format := range keystores.Formats {
+		var keystoreKey, truststoreKey string
+		var keystore, truststore []byte
+
+		switch format {
+		case policy.KeystorePKCS12:
+			keystoreKey, truststoreKey = PKCS12KeystoreKey, PKCS12TruststoreKey
+
+			keystore, err = certificate.EncodePKCS12(privateKey, certificates, password)
+			if err == nil && caCertificates != nil {
+				truststore, err = certificate.EncodePKCS12TrustStore(caCertificates, password)
+			}
+
+		case policy.KeystoreJKS:
+			keystoreKey, truststoreKey = JKSKeystoreKey, JKSTruststoreKey
+
+			keystore, err = certificate.EncodeJKS(privateKey, certificates, password)
+			if err == nil && caCertificates != nil {
+				truststore, err = certificate.EncodeJKSTrustStore(caCertificates, password)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not generate the %s keystores of Secret [%s/%s]: %v", format, sourceSecret.Namespace, sourceSecret.Name, err)
+		}
+
+		keystoreData[keystoreKey] = keystore
+		if truststore != nil {
+			keystoreData[truststoreKey] = truststore
+		}
+	}
+
+	return keystoreData, nil
+}
+
+// keystorePassword returns the password of the keystores and whether it is generated, in which case the password
+// already generated into the copy is kept
+func (w *Writer) keystorePassword(ctx context.Context, keystores *policy.Keystores, namespace string, existingData map[string][]byte) (string, bool, error) {
+	if keystores.PasswordSecret != "" {
+		passwordSecretName := types.NamespacedName{
+			Namespace: namespace,
+			Name:      keystores.PasswordSecret,
+		}
+		passwordSecret := &corev1.Secret{}
+
+		err := w.client.Get(ctx, passwordSecretName, passwordSecret)
+		if err != nil {
+			return "", false, fmt.Errorf("could not fetch the keystore password Secret [%s]: %v", passwordSecretName, err)
+		}
+
+		password, ok := passwordSecret.Data[PasswordSecretKey]
+		if !ok || len(password) == 0 {
+			return "", false, fmt.Errorf("keystore password Secret [%s] has no %s key", passwordSecretName, PasswordSecretKey)
+		}
+
+		return string(password), false, nil
+	}
+
+	if password, ok := existingData[KeystorePasswordKey]; ok && len(password) > 0 {
+		return string(password), true, nil
+	}
+
+	randomBytes := make([]byte, 18)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", false, fmt.Errorf("could not generate a keystore password: %v", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(randomBytes), true, nil
+}
@@ -0,0 +1,172 @@
+package replica
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/policy"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestKeystores(t *testing.T) {
+	sourceSecret := newKeystoreSourceSecret(t)
+
+	passwordSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "target",
+			Name:      "keystore-password",
+		},
+		Data: map[string][]byte{
+			PasswordSecretKey: []byte("changeit"),
+		},
+	}
+
+	tests := map[string]struct {
+		keystores *policy.Keystores
+		keys      []string
+		password  string
+	}{
+		"generate keystores with generated password": {
+			keystores: &policy.Keystores{Formats: []policy.KeystoreFormat{policy.KeystorePKCS12, policy.KeystoreJKS}},
+			keys:      []string{PKCS12KeystoreKey, PKCS12TruststoreKey, JKSKeystoreKey, JKSTruststoreKey, KeystorePasswordKey},
+		},
+		"generate keystores with password from Secret": {
+			keystores: &policy.Keystores{Formats: []policy.KeystoreFormat{policy.KeystorePKCS12}, PasswordSecret: passwordSecret.Name},
+			keys:      []string{PKCS12KeystoreKey, PKCS12TruststoreKey},
+			password:  "changeit",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Create a client and the writer
+			fakeClient := fake.NewClientBuilder().WithObjects(passwordSecret).Build()
+			writer := NewWriter(fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), policy.NewResolver(fakeClient, nil, policy.Policy{Conflict: policy.ConflictSkip, Keystores: test.keystores}), record.NewFakeRecorder(10))
+
+			targetSecretName := types.NamespacedName{
+				Namespace: "target",
+				Name:      sourceSecret.Name,
+			}
+
+			written, err := writer.Create(context.TODO(), sourceSecret, targetSecretName, nil)
+
+			assert.True(t, written)
+			assert.NoError(t, err)
+
+			// Check the generated keystores, which are up to date along with the data
+			targetSecret := &corev1.Secret{}
+			err = fakeClient.Get(context.TODO(), targetSecretName, targetSecret)
+
+			assert.NoError(t, err)
+			for _, key := range test.keys {
+				assert.NotEmpty(t, targetSecret.Data[key], key)
+			}
+			assert.Len(t, targetSecret.Data, len(sourceSecret.Data)+len(test.keys))
+
+			upToDate, err := writer.IsUpToDate(context.TODO(), sourceSecret, targetSecret, sourceSecret.Data)
+			assert.NoError(t, err)
+			assert.True(t, upToDate)
+
+			password := test.password
+			if password == "" {
+				password = string(targetSecret.Data[KeystorePasswordKey])
+			}
+
+			_, _, err = pkcs12.Decode(targetSecret.Data[PKCS12KeystoreKey], password)
+			assert.NoError(t, err)
+
+			// Render the copy again and verify that the generated password is kept
+			err = writer.Render(context.TODO(), sourceSecret, targetSecret, sourceSecret.Data)
+
+			assert.NoError(t, err)
+			_, _, err = pkcs12.Decode(targetSecret.Data[PKCS12KeystoreKey], password)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestKeystoresOutdated(t *testing.T) {
+	sourceSecret := newKeystoreSourceSecret(t)
+
+	passwordSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "target",
+			Name:      "keystore-password",
+		},
+		Data: map[string][]byte{
+			PasswordSecretKey: []byte("changeit"),
+		},
+	}
+
+	// Create a copy without keystores
+	fakeClient := fake.NewClientBuilder().WithObjects(passwordSecret).Build()
+	secretSource := backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil)
+
+	newWriter := func(keystores *policy.Keystores) *Writer {
+		return NewWriter(fakeClient, secretSource, policy.NewResolver(fakeClient, nil, policy.Policy{Keystores: keystores}), record.NewFakeRecorder(10))
+	}
+
+	targetSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "target",
+			Name:      sourceSecret.Name,
+		},
+	}
+	assert.NoError(t, newWriter(nil).Render(context.TODO(), sourceSecret, targetSecret, sourceSecret.Data))
+
+	// Verify that enabling the keystores, changing their formats and rotating their password outdate the copy
+	pkcs12Writer := newWriter(&policy.Keystores{Formats: []policy.KeystoreFormat{policy.KeystorePKCS12}, PasswordSecret: passwordSecret.Name})
+	jksWriter := newWriter(&policy.Keystores{Formats: []policy.KeystoreFormat{policy.KeystoreJKS}, PasswordSecret: passwordSecret.Name})
+
+	upToDate, err := pkcs12Writer.IsUpToDate(context.TODO(), sourceSecret, targetSecret, sourceSecret.Data)
+	assert.NoError(t, err)
+	assert.False(t, upToDate, "keystores enabled")
+
+	assert.NoError(t, pkcs12Writer.Render(context.TODO(), sourceSecret, targetSecret, sourceSecret.Data))
+
+	upToDate, err = pkcs12Writer.IsUpToDate(context.TODO(), sourceSecret, targetSecret, sourceSecret.Data)
+	assert.NoError(t, err)
+	assert.True(t, upToDate, "keystores rendered")
+
+	upToDate, err = jksWriter.IsUpToDate(context.TODO(), sourceSecret, targetSecret, sourceSecret.Data)
+	assert.NoError(t, err)
+	assert.False(t, upToDate, "formats changed")
+
+	passwordSecret.Data[PasswordSecretKey] = []byte("rotated")
+	assert.NoError(t, fakeClient.Update(context.TODO(), passwordSecret))
+
+	upToDate, err = pkcs12Writer.IsUpToDate(context.TODO(), sourceSecret, targetSecret, sourceSecret.Data)
+	assert.NoError(t, err)
+	assert.False(t, upToDate, "password rotated")
+}
+
+// newKeystoreSourceSecret returns a TLS Secret holding a self-signed certificate, its private key and a CA bundle
+func newKeystoreSourceSecret(t *testing.T) *corev1.Secret {
+	leaf, privateKey := newTestCertificate(t, "example.io", nil, nil)
+
+	privateKeyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	assert.NoError(t, err)
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "tls-example-io",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       pemCertificate(leaf),
+			corev1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyDER}),
+			CABundleKey:             pemCertificate(leaf),
+		},
+	}
+}
@@ -0,0 +1,66 @@
+package replica
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Quarantine marks the copies of the source Secret with the hash of its current data, which keeps every controller from
+// copying that data anywhere until it changes again, also after a restart
+func (w *Writer) Quarantine(ctx context.Context, sourceSecret *corev1.Secret, targetSecretNames []types.NamespacedName) error {
+	hash := DataHash(sourceSecret.Data)
+
+	for _, targetSecretName := range targetSecretNames {
+		targetSecret := &corev1.Secret{}
+
+		err := w.client.Get(ctx, targetSecretName, targetSecret)
+		if err != nil {
+			return fmt.Errorf("could not fetch the target Secret [%s]: %v", targetSecretName, err)
+		}
+
+		if targetSecret.Annotations[QuarantinedAnnotation] == hash {
+			continue
+		}
+
+		if targetSecret.Annotations == nil {
+			targetSecret.Annotations = map[string]string{}
+		}
+		targetSecret.Annotations[QuarantinedAnnotation] = hash
+
+		err = w.client.Update(ctx, targetSecret)
+		if err != nil {
+			return fmt.Errorf("could not mark the target Secret [%s] as quarantined: %v", targetSecretName, err)
+		}
+	}
+
+	return nil
+}
+
+// IsQuarantined returns whether the current data of the source Secret was quarantined, as recorded on its copies
+func (w *Writer) IsQuarantined(ctx context.Context, sourceSecret *corev1.Secret) (bool, error) {
+	metadataList := &metav1.PartialObjectMetadataList{}
+	metadataList.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("SecretList"))
+
+	err := w.client.List(ctx, metadataList, client.MatchingLabels(Labels(sourceSecret.Name)))
+	if err != nil {
+		return false, fmt.Errorf("could not list the copies of Secret [%s/%s]: %v", sourceSecret.Namespace, sourceSecret.Name, err)
+	}
+
+	hash := DataHash(sourceSecret.Data)
+	for _, targetSecretMetadata := range metadataList.Items {
+		// Copies made before their provenance was recorded can only come from the source
+		annotations := targetSecretMetadata.GetAnnotations()
+		sourceNamespace, ok := annotations[SourceNamespaceAnnotation]
+
+		if (!ok || sourceNamespace == sourceSecret.Namespace) && annotations[QuarantinedAnnotation] == hash {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,179 @@
+package replica
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"tls-secret-injector/pkg/certificate"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// NameLabel marks the objects managed by the injector
+	NameLabel = "app.kubernetes.io/name"
+	// SourceNameLabel holds the name of the source Secret a copy was made from
+	SourceNameLabel = "tls-secret-injector/source-name"
+
+	// SourceNamespaceAnnotation holds the namespace of the source Secret a copy was made from
+	SourceNamespaceAnnotation = "tls-secret-injector/source-namespace"
+	// SourceUIDAnnotation holds the UID of the source Secret a copy was made from
+	SourceUIDAnnotation = "tls-secret-injector/source-uid"
+	// SourceResourceVersionAnnotation holds the resourceVersion of the source Secret when it was last copied
+	SourceResourceVersionAnnotation = "tls-secret-injector/source-resource-version"
+	// FingerprintAnnotation holds the SHA-256 fingerprint of the copied certificate
+	FingerprintAnnotation = "tls-secret-injector/certificate-fingerprint"
+	// ChainFingerprintAnnotation holds the SHA-256 fingerprint of the whole copied certificate chain
+	ChainFingerprintAnnotation = "tls-secret-injector/chain-fingerprint"
+	// DataHashAnnotation holds the hash of the data written to the copy
+	DataHashAnnotation = "tls-secret-injector/data-hash"
+	// WrittenHashAnnotation holds the hash of all data written to the copy, including the keystores derived from it, to
+	// tell whether the copy was changed since
+	WrittenHashAnnotation = "tls-secret-injector/written-hash"
+	// KeystoresHashAnnotation holds the hash of the keystore settings and of the password Secret the keystores of the
+	// copy were generated with
+	KeystoresHashAnnotation = "tls-secret-injector/keystores-hash"
+	// VersionAnnotation holds the version of the source Secret kept in the history that the data comes from
+	VersionAnnotation = "tls-secret-injector/version"
+	// LastSyncAnnotation holds the time at which the data of the copy was last written
+	LastSyncAnnotation = "tls-secret-injector/last-sync"
+	// RolloutHaltedAnnotation holds the hash of the data whose rollout was halted as a canary Ingress reported errors
+	RolloutHaltedAnnotation = "tls-secret-injector/rollout-halted"
+	// QuarantinedAnnotation holds the hash of the data of the source Secret that failed the pre-flight checks, which is
+	// not copied until the source Secret changes again
+	QuarantinedAnnotation = "tls-secret-injector/quarantined"
+	// PushedAnnotation marks the copies made for the namespaces a source Secret declares through annotations, which are
+	// kept regardless of the Ingresses using them
+	PushedAnnotation = "tls-secret-injector/pushed"
+	// ResyncRequestedAnnotation holds the time at which a resync of the source Secret was requested from a replica
+	// that is not the leader, which the leader watches for
+	ResyncRequestedAnnotation = "tls-secret-injector/resync-requested"
+
+	managerName = "tls-secret-injector"
+)
+
+// NewSecretMetadata returns an empty Secret holding only metadata, to read Secrets through the metadata cache
+func NewSecretMetadata() *metav1.PartialObjectMetadata {
+	secretMetadata := &metav1.PartialObjectMetadata{}
+	secretMetadata.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+
+	return secretMetadata
+}
+
+// NewConfigMapMetadata returns an empty ConfigMap holding only metadata, to read ConfigMaps through the metadata cache
+func NewConfigMapMetadata() *metav1.PartialObjectMetadata {
+	configMapMetadata := &metav1.PartialObjectMetadata{}
+	configMapMetadata.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+
+	return configMapMetadata
+}
+
+// ManagedLabels returns the labels shared by all objects managed by the injector
+func ManagedLabels() map[string]string {
+	return map[string]string{
+		NameLabel: managerName,
+	}
+}
+
+// Labels returns the labels marking an object as a copy of the given source Secret
+func Labels(sourceName string) map[string]string {
+	labels := ManagedLabels()
+	labels[SourceNameLabel] = sourceName
+
+	return labels
+}
+
+// IsManaged returns whether the object is a copy managed by the injector
+func IsManaged(object metav1.Object) bool {
+	return object.GetLabels()[NameLabel] == managerName
+}
+
+// IsPushed returns whether the copy was made for a namespace its source Secret declares through annotations
+func IsPushed(object metav1.Object) bool {
+	return object.GetAnnotations()[PushedAnnotation] == "true"
+}
+
+// IsRecorded returns whether the copy was last written with the given data, as recorded in its metadata
+func IsRecorded(target metav1.Object, data map[string][]byte) bool {
+	return target.GetAnnotations()[DataHashAnnotation] == DataHash(data)
+}
+
+// IsUpToDate returns whether the copy was last written with the given data, and still holds the data written then
+func IsUpToDate(target *corev1.Secret, data map[string][]byte) bool {
+	return IsRecorded(target, data) && target.Annotations[WrittenHashAnnotation] == DataHash(target.Data)
+}
+
+// IsConfigMapUpToDate returns whether the ConfigMap was last written with the given data, and still holds it
+func IsConfigMapUpToDate(target *corev1.ConfigMap, data map[string][]byte) bool {
+	if !IsRecorded(target, data) || len(target.Data) != len(data) || len(target.BinaryData) > 0 {
+		return false
+	}
+
+	for key, value := range data {
+		if target.Data[key] != string(value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Annotate records on the copy where its data comes from
+func Annotate(target metav1.Object, source *corev1.Secret, data map[string][]byte) {
+	annotations := target.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	provenance := map[string]string{
+		SourceNamespaceAnnotation:       source.Namespace,
+		SourceUIDAnnotation:             string(source.UID),
+		SourceResourceVersionAnnotation: source.ResourceVersion,
+		FingerprintAnnotation:           certificate.Fingerprint(data[corev1.TLSCertKey]),
+		ChainFingerprintAnnotation:      certificate.ChainFingerprint(data[corev1.TLSCertKey]),
+		VersionAnnotation:               source.Annotations[VersionAnnotation],
+	}
+
+	// Sources outside of Kubernetes, or not kept in the history, do not have all of these
+	for key, value := range provenance {
+		if value == "" {
+			delete(annotations, key)
+		} else {
+			annotations[key] = value
+		}
+	}
+
+	// A halted rollout and a quarantine only hold back the data they were decided for
+	if annotations[RolloutHaltedAnnotation] != DataHash(data) {
+		delete(annotations, RolloutHaltedAnnotation)
+	}
+	if annotations[QuarantinedAnnotation] != DataHash(source.Data) {
+		delete(annotations, QuarantinedAnnotation)
+	}
+
+	annotations[DataHashAnnotation] = DataHash(data)
+	annotations[LastSyncAnnotation] = time.Now().UTC().Format(time.RFC3339)
+
+	target.SetAnnotations(annotations)
+}
+
+// DataHash returns a SHA-256 hash over all keys and values of the data
+func DataHash(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%d:%s%d:", len(key), key, len(data[key]))
+		hash.Write(data[key])
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
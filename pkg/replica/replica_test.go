@@ -0,0 +1,81 @@
+package replica
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDataHash(t *testing.T) {
+	data := map[string][]byte{
+		corev1.TLSCertKey:       []byte("certificate"),
+		corev1.TLSPrivateKeyKey: []byte("private key"),
+	}
+
+	assert.Equal(t, DataHash(data), DataHash(map[string][]byte{
+		corev1.TLSPrivateKeyKey: []byte("private key"),
+		corev1.TLSCertKey:       []byte("certificate"),
+	}))
+	assert.NotEqual(t, DataHash(data), DataHash(map[string][]byte{
+		corev1.TLSCertKey:       []byte("certificateprivate key"),
+		corev1.TLSPrivateKeyKey: []byte(""),
+	}))
+}
+
+func TestAnnotate(t *testing.T) {
+	source := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "tls-example-io",
+			ResourceVersion: "3",
+		},
+		Data: map[string][]byte{
+			corev1.TLSCertKey: []byte("certificate"),
+		},
+	}
+
+	target := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				SourceUIDAnnotation: "outdated-uid",
+			},
+		},
+	}
+
+	assert.False(t, IsRecorded(target, source.Data))
+
+	Annotate(target, source, source.Data)
+
+	assert.True(t, IsRecorded(target, source.Data))
+	assert.Equal(t, "3", target.Annotations[SourceResourceVersionAnnotation])
+	assert.NotContains(t, target.Annotations, SourceUIDAnnotation)
+	assert.NotContains(t, target.Annotations, SourceNamespaceAnnotation)
+	assert.NotContains(t, target.Annotations, FingerprintAnnotation)
+}
+
+func TestIsUpToDate(t *testing.T) {
+	data := map[string][]byte{
+		corev1.TLSCertKey: []byte("certificate"),
+	}
+
+	target := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				DataHashAnnotation:    DataHash(data),
+				WrittenHashAnnotation: DataHash(data),
+			},
+		},
+		Data: map[string][]byte{
+			corev1.TLSCertKey: []byte("certificate"),
+		},
+	}
+
+	assert.True(t, IsUpToDate(target, data))
+
+	// Check that a copy changed since it was written is not up to date, even though its metadata is
+	target.Data[corev1.TLSCertKey] = []byte("changed certificate")
+
+	assert.True(t, IsRecorded(target, data))
+	assert.False(t, IsUpToDate(target, data))
+}
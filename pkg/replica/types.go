@@ -0,0 +1,62 @@
+package replica
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"tls-secret-injector/pkg/certificate"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// CABundleKey is the key holding the PEM certificates of an Opaque CA bundle Secret
+const CABundleKey = "ca.crt"
+
+// Types holds the Secret types that are replicated
+type Types []corev1.SecretType
+
+// Allows returns whether Secrets of the given type are replicated
+func (t Types) Allows(secretType corev1.SecretType) bool {
+	for _, allowedType := range t {
+		if allowedType == secretType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate returns an error when the Secret does not hold the data its type requires
+func Validate(secret *corev1.Secret) error {
+	switch secret.Type {
+	case corev1.SecretTypeTLS:
+		for _, key := range []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey} {
+			if len(secret.Data[key]) == 0 {
+				return fmt.Errorf("TLS Secret [%s/%s] has no %s key", secret.Namespace, secret.Name, key)
+			}
+		}
+
+	case corev1.SecretTypeDockerConfigJson:
+		var dockerConfig struct {
+			Auths map[string]json.RawMessage `json:"auths"`
+		}
+
+		err := json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], &dockerConfig)
+		if err != nil {
+			return fmt.Errorf("docker config Secret [%s/%s] has an invalid %s key: %v", secret.Namespace, secret.Name, corev1.DockerConfigJsonKey, err)
+		}
+		if len(dockerConfig.Auths) == 0 {
+			return fmt.Errorf("docker config Secret [%s/%s] has no registry credentials", secret.Namespace, secret.Name)
+		}
+
+	case corev1.SecretTypeOpaque:
+		if certificate.Fingerprint(secret.Data[CABundleKey]) == "" {
+			return fmt.Errorf("CA bundle Secret [%s/%s] has no certificate in its %s key", secret.Namespace, secret.Name, CABundleKey)
+		}
+
+	default:
+		return fmt.Errorf("unsupported type %s of Secret [%s/%s]", secret.Type, secret.Namespace, secret.Name)
+	}
+
+	return nil
+}
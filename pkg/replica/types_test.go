@@ -0,0 +1,84 @@
+package replica
+
+import (
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestValidate(t *testing.T) {
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("certificate")})
+
+	tests := map[string]struct {
+		secretType corev1.SecretType
+		data       map[string][]byte
+		valid      bool
+	}{
+		"valid TLS": {
+			secretType: corev1.SecretTypeTLS,
+			data: map[string][]byte{
+				corev1.TLSCertKey:       []byte("certificate"),
+				corev1.TLSPrivateKeyKey: []byte("private key"),
+			},
+			valid: true,
+		},
+		"TLS without private key": {
+			secretType: corev1.SecretTypeTLS,
+			data: map[string][]byte{
+				corev1.TLSCertKey: []byte("certificate"),
+			},
+		},
+		"valid Docker config": {
+			secretType: corev1.SecretTypeDockerConfigJson,
+			data: map[string][]byte{
+				corev1.DockerConfigJsonKey: []byte(`{"auths":{"registry.example.io":{"auth":"dXNlcjpwYXNz"}}}`),
+			},
+			valid: true,
+		},
+		"Docker config without credentials": {
+			secretType: corev1.SecretTypeDockerConfigJson,
+			data: map[string][]byte{
+				corev1.DockerConfigJsonKey: []byte(`{}`),
+			},
+		},
+		"invalid Docker config": {
+			secretType: corev1.SecretTypeDockerConfigJson,
+			data: map[string][]byte{
+				corev1.DockerConfigJsonKey: []byte(`auths`),
+			},
+		},
+		"valid CA bundle": {
+			secretType: corev1.SecretTypeOpaque,
+			data: map[string][]byte{
+				CABundleKey: caBundle,
+			},
+			valid: true,
+		},
+		"CA bundle without certificate": {
+			secretType: corev1.SecretTypeOpaque,
+			data: map[string][]byte{
+				CABundleKey: []byte("certificate"),
+			},
+		},
+		"unsupported type": {
+			secretType: corev1.SecretTypeBasicAuth,
+			data: map[string][]byte{
+				corev1.BasicAuthUsernameKey: []byte("user"),
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := Validate(&corev1.Secret{Type: test.secretType, Data: test.data})
+
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,628 @@
+package replica
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/certificate"
+	"tls-secret-injector/pkg/policy"
+	"tls-secret-injector/pkg/tracing"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Writer writes the copies of source Secrets, resolving conflicts with existing Secrets according to the policies
+type Writer struct {
+	client   client.Client
+	source   backend.SecretSource
+	policies *policy.Resolver
+	recorder record.EventRecorder
+
+	// skipped holds the UIDs of the existing Secrets already reported as skipped
+	skipped     map[types.UID]bool
+	skippedLock sync.Mutex
+}
+
+// NewWriter returns a pointer to Writer
+func NewWriter(client client.Client, secretSource backend.SecretSource, policies *policy.Resolver, recorder record.EventRecorder) *Writer {
+	return &Writer{
+		client:   client,
+		source:   secretSource,
+		policies: policies,
+		recorder: recorder,
+		skipped:  map[types.UID]bool{},
+	}
+}
+
+// Create creates a copy of the source Secret and returns whether it was written. When a Secret not managed by the
+// injector already exists under the same name, the conflict policy of its namespace decides what happens to it.
+func (w *Writer) Create(ctx context.Context, sourceSecret *corev1.Secret, targetSecretName types.NamespacedName, ownerReferences []metav1.OwnerReference) (bool, error) {
+	return w.create(ctx, sourceSecret, targetSecretName, ownerReferences, false)
+}
+
+// Push creates a copy of the source Secret in a namespace the source Secret declares through annotations, and returns
+// whether it was written. The copy is marked as pushed, so it is kept regardless of the Ingresses using it, which
+// also applies to a copy made for Ingresses before.
+func (w *Writer) Push(ctx context.Context, sourceSecret *corev1.Secret, targetSecretName types.NamespacedName) (bool, error) {
+	created, err := w.create(ctx, sourceSecret, targetSecretName, nil, true)
+	if err != nil || created {
+		return created, err
+	}
+
+	return false, w.markPushed(ctx, targetSecretName)
+}
+
+func (w *Writer) create(ctx context.Context, sourceSecret *corev1.Secret, targetSecretName types.NamespacedName, ownerReferences []metav1.OwnerReference, pushed bool) (bool, error) {
+	// Data that failed the pre-flight checks is not copied by any controller
+	quarantined, err := w.IsQuarantined(ctx, sourceSecret)
+	if err != nil {
+		return false, err
+	}
+	if quarantined {
+		return false, fmt.Errorf("could not copy the source Secret [%s/%s] to [%s] as its data is quarantined", sourceSecret.Namespace, sourceSecret.Name, targetSecretName)
+	}
+
+	targetPolicy, err := w.policies.For(ctx, targetSecretName.Namespace)
+	if err != nil {
+		return false, err
+	}
+
+	sourceSecret, err = w.assembleChain(ctx, targetPolicy, sourceSecret)
+	if err != nil {
+		return false, err
+	}
+
+	data := copyData(targetPolicy, sourceSecret)
+
+	if targetPolicy.Public == policy.PublicConfigMap {
+		return w.createConfigMap(ctx, targetPolicy, sourceSecret, data, targetSecretName, ownerReferences)
+	}
+
+	// Check for an existing Secret first, only reading its metadata, as the conflict would otherwise be run into each time
+	existingMetadata := NewSecretMetadata()
+
+	err = w.client.Get(ctx, targetSecretName, existingMetadata)
+	if err == nil {
+		return w.resolveExisting(ctx, targetPolicy, sourceSecret, data, existingMetadata, ownerReferences, pushed)
+	}
+	if !errors.IsNotFound(err) {
+		return false, err
+	}
+
+	targetSecret := newCopy(sourceSecret, data, targetSecretName, ownerReferences)
+	if pushed {
+		targetSecret.Annotations = map[string]string{PushedAnnotation: "true"}
+	}
+
+	err = w.render(ctx, targetPolicy, sourceSecret, targetSecret, data)
+	if err != nil {
+		return false, err
+	}
+
+	err = w.client.Create(ctx, targetSecret)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		return false, err
+	}
+
+	// Another request could have created the copy in the meantime
+	err = w.client.Get(ctx, targetSecretName, existingMetadata)
+	if err != nil {
+		return false, err
+	}
+
+	return w.resolveExisting(ctx, targetPolicy, sourceSecret, data, existingMetadata, ownerReferences, pushed)
+}
+
+// resolveExisting resolves the conflict with the existing Secret unless the injector already manages it, and returns
+// whether the copy was written. Skipped Secrets are reported only once, as they are run into on every call.
+func (w *Writer) resolveExisting(ctx context.Context, targetPolicy *policy.Policy, sourceSecret *corev1.Secret, data map[string][]byte, existingMetadata *metav1.PartialObjectMetadata, ownerReferences []metav1.OwnerReference, pushed bool) (bool, error) {
+	if IsManaged(existingMetadata) {
+		return false, nil
+	}
+
+	if targetPolicy.Conflict != policy.ConflictAdopt && targetPolicy.Conflict != policy.ConflictOverwrite {
+		log.Debugf("Skipping creation of the target Secret [%s/%s] as it already exists and is not managed", existingMetadata.Namespace, existingMetadata.Name)
+
+		if w.isFirstSkip(existingMetadata.UID) {
+			w.recorder.Eventf(
+				existingMetadata,
+				corev1.EventTypeNormal,
+				"ConflictSkipped",
+				"Skipped copying Secret [%s/%s] as this Secret is not managed by tls-secret-injector",
+				sourceSecret.Namespace,
+				sourceSecret.Name,
+			)
+		}
+		return false, nil
+	}
+
+	existingSecret := &corev1.Secret{}
+
+	err := w.client.Get(ctx, types.NamespacedName{Namespace: existingMetadata.Namespace, Name: existingMetadata.Name}, existingSecret)
+	if err != nil {
+		return false, err
+	}
+
+	return w.resolveConflict(ctx, targetPolicy, sourceSecret, data, existingSecret, ownerReferences, pushed)
+}
+
+// markPushed marks the existing copy as pushed, removing the Ingresses from its owners so that neither the garbage
+// collector nor the release of the Secrets of an Ingress deletes it
+func (w *Writer) markPushed(ctx context.Context, targetSecretName types.NamespacedName) error {
+	existingMetadata := NewSecretMetadata()
+
+	// Copies written as ConfigMaps, and Secrets skipped by the conflict policy, have no such Secret to mark
+	err := w.client.Get(ctx, targetSecretName, existingMetadata)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not fetch the target Secret [%s]: %v", targetSecretName, err)
+	}
+	if !IsManaged(existingMetadata) || IsPushed(existingMetadata) && !hasIngressOwner(existingMetadata) {
+		return nil
+	}
+
+	existingSecret := &corev1.Secret{}
+
+	err = w.client.Get(ctx, targetSecretName, existingSecret)
+	if err != nil {
+		return fmt.Errorf("could not fetch the target Secret [%s]: %v", targetSecretName, err)
+	}
+
+	if existingSecret.Annotations == nil {
+		existingSecret.Annotations = map[string]string{}
+	}
+	existingSecret.Annotations[PushedAnnotation] = "true"
+
+	var ownerReferences []metav1.OwnerReference
+	for _, ownerReference := range existingSecret.OwnerReferences {
+		if ownerReference.Kind != "Ingress" {
+			ownerReferences = append(ownerReferences, ownerReference)
+		}
+	}
+	existingSecret.OwnerReferences = ownerReferences
+
+	err = w.client.Update(ctx, existingSecret)
+	if err != nil {
+		return fmt.Errorf("failed to mark Secret [%s] as pushed: %v", targetSecretName, err)
+	}
+
+	log.Infof("Marked Secret [%s] as pushed, keeping it regardless of the Ingresses using it", targetSecretName)
+
+	return nil
+}
+
+// isFirstSkip returns whether the existing Secret with the UID is skipped for the first time
+func (w *Writer) isFirstSkip(uid types.UID) bool {
+	w.skippedLock.Lock()
+	defer w.skippedLock.Unlock()
+
+	if w.skipped[uid] {
+		return false
+	}
+
+	w.skipped[uid] = true
+	return true
+}
+
+// resolveConflict adopts or overwrites the existing Secret as the conflict policy decides, and returns whether the copy
+// was written
+func (w *Writer) resolveConflict(ctx context.Context, targetPolicy *policy.Policy, sourceSecret *corev1.Secret, data map[string][]byte, existingSecret *corev1.Secret, ownerReferences []metav1.OwnerReference, pushed bool) (written bool, err error) {
+	ctx, span := tracing.Start(ctx, "Resolve conflict",
+		attribute.String("policy", targetPolicy.Name),
+		attribute.String("conflict", string(targetPolicy.Conflict)),
+		attribute.String("k8s.namespace", existingSecret.Namespace),
+		attribute.String("k8s.name", existingSecret.Name),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if targetPolicy.Conflict == policy.ConflictAdopt {
+		err = w.adopt(ctx, targetPolicy, sourceSecret, data, existingSecret, ownerReferences, pushed)
+	} else {
+		err = w.overwrite(ctx, targetPolicy, sourceSecret, data, existingSecret, ownerReferences, pushed)
+	}
+
+	return err == nil, err
+}
+
+// Adopt takes over an existing Secret not managed by the injector, keeping its metadata while replacing its data with
+// the data of the source Secret
+func (w *Writer) Adopt(ctx context.Context, sourceSecret *corev1.Secret, existingSecret *corev1.Secret, ownerReferences []metav1.OwnerReference) error {
+	targetPolicy, err := w.policies.For(ctx, existingSecret.Namespace)
+	if err != nil {
+		return err
+	}
+
+	sourceSecret, err = w.assembleChain(ctx, targetPolicy, sourceSecret)
+	if err != nil {
+		return err
+	}
+
+	return w.adopt(ctx, targetPolicy, sourceSecret, copyData(targetPolicy, sourceSecret), existingSecret, ownerReferences, false)
+}
+
+// IsOutdated returns whether the Secret under the name is a copy of the source Secret that was not last written with
+// its data, only reading its metadata
+func (w *Writer) IsOutdated(ctx context.Context, sourceSecret *corev1.Secret, targetSecretName types.NamespacedName) (bool, error) {
+	targetMetadata := NewSecretMetadata()
+
+	err := w.client.Get(ctx, targetSecretName, targetMetadata)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not fetch the target Secret [%s]: %v", targetSecretName, err)
+	}
+
+	// Copies made before their provenance was recorded can only come from the source
+	sourceNamespace, ok := targetMetadata.Annotations[SourceNamespaceAnnotation]
+	if !IsManaged(targetMetadata) || targetMetadata.Labels[SourceNameLabel] != sourceSecret.Name || ok && sourceNamespace != sourceSecret.Namespace {
+		return false, nil
+	}
+
+	data, err := w.Data(ctx, sourceSecret, targetSecretName.Namespace)
+	if err != nil {
+		return false, err
+	}
+
+	return !IsRecorded(targetMetadata, data), nil
+}
+
+// Data returns the data of the source Secret that is written into its copies in the namespace
+func (w *Writer) Data(ctx context.Context, sourceSecret *corev1.Secret, namespace string) (map[string][]byte, error) {
+	targetPolicy, err := w.policies.For(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceSecret, err = w.assembleChain(ctx, targetPolicy, sourceSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return copyData(targetPolicy, sourceSecret), nil
+}
+
+// CheckSource returns an error when the policy of the namespace no longer allows copying from the source Secret
+// referenced through annotations
+func (w *Writer) CheckSource(ctx context.Context, sourceSecret *corev1.Secret, namespace string) error {
+	return w.policies.CheckSource(ctx, namespace, types.NamespacedName{
+		Namespace: sourceSecret.Namespace,
+		Name:      sourceSecret.Name,
+	})
+}
+
+// IsPublic returns whether the policy of the namespace restricts the copies to the public certificates
+func (w *Writer) IsPublic(ctx context.Context, namespace string) (bool, error) {
+	targetPolicy, err := w.policies.For(ctx, namespace)
+	if err != nil {
+		return false, err
+	}
+
+	return targetPolicy.Public != "", nil
+}
+
+// Render writes the data into the copy along with the keystores the policy of its namespace asks for, and records
+// where the data comes from
+func (w *Writer) Render(ctx context.Context, sourceSecret *corev1.Secret, targetSecret *corev1.Secret, data map[string][]byte) error {
+	targetPolicy, err := w.policies.For(ctx, targetSecret.Namespace)
+	if err != nil {
+		return err
+	}
+
+	sourceSecret, err = w.assembleChain(ctx, targetPolicy, sourceSecret)
+	if err != nil {
+		return err
+	}
+
+	return w.render(ctx, targetPolicy, sourceSecret, targetSecret, data)
+}
+
+// IsUpToDate returns whether the copy still holds the data last written to it from the given data, with keystores
+// generated under the current settings and password of the policy of its namespace
+func (w *Writer) IsUpToDate(ctx context.Context, sourceSecret *corev1.Secret, targetSecret *corev1.Secret, data map[string][]byte) (bool, error) {
+	if !IsUpToDate(targetSecret, data) {
+		return false, nil
+	}
+
+	targetPolicy, err := w.policies.For(ctx, targetSecret.Namespace)
+	if err != nil {
+		return false, err
+	}
+
+	hash, err := w.expectedKeystoresHash(ctx, targetPolicy, sourceSecret, targetSecret)
+	if err != nil {
+		return false, err
+	}
+
+	return targetSecret.Annotations[KeystoresHashAnnotation] == hash, nil
+}
+
+// Update writes the data into the existing copy. The copy is recreated when it needs another type, as the type of a
+// Secret cannot be changed, for example once the key mapping of its policy renames tls.crt or tls.key.
+func (w *Writer) Update(ctx context.Context, sourceSecret *corev1.Secret, targetSecret *corev1.Secret, data map[string][]byte) error {
+	targetType := copyType(sourceSecret.Type, data)
+	if targetSecret.Type == targetType {
+		err := w.Render(ctx, sourceSecret, targetSecret, data)
+		if err != nil {
+			return err
+		}
+
+		return w.client.Update(ctx, targetSecret)
+	}
+
+	// Keep the metadata and the data of the copy, so the keystores keep their password
+	recreatedSecret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.Version,
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       targetSecret.Namespace,
+			Name:            targetSecret.Name,
+			Labels:          targetSecret.Labels,
+			Annotations:     targetSecret.Annotations,
+			OwnerReferences: targetSecret.OwnerReferences,
+		},
+		Type: targetType,
+		Data: targetSecret.Data,
+	}
+
+	err := w.Render(ctx, sourceSecret, recreatedSecret, data)
+	if err != nil {
+		return err
+	}
+
+	// Only delete the copy as it was read, so concurrent changes are retried as conflicts
+	err = w.client.Delete(ctx, targetSecret, client.Preconditions{UID: &targetSecret.UID, ResourceVersion: &targetSecret.ResourceVersion})
+	if err != nil {
+		return err
+	}
+
+	err = w.client.Create(ctx, recreatedSecret)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Recreated Secret [%s/%s] as its type changed from %s to %s", targetSecret.Namespace, targetSecret.Name, targetSecret.Type, targetType)
+	w.recorder.Eventf(
+		recreatedSecret,
+		corev1.EventTypeNormal,
+		"Recreated",
+		"Recreated this copy of Secret [%s/%s] as its type changed from %s to %s",
+		sourceSecret.Namespace,
+		sourceSecret.Name,
+		targetSecret.Type,
+		targetType,
+	)
+
+	return nil
+}
+
+// assembleChain returns the source Secret with the intermediate certificates the policy asks for appended to its tls.crt
+func (w *Writer) assembleChain(ctx context.Context, targetPolicy *policy.Policy, sourceSecret *corev1.Secret) (*corev1.Secret, error) {
+	if targetPolicy.Chain == nil || sourceSecret.Type != corev1.SecretTypeTLS {
+		return sourceSecret, nil
+	}
+
+	intermediatesSecret, err := w.getIntermediates(ctx, sourceSecret, targetPolicy.Chain.IntermediatesSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := certificate.AssembleChain(sourceSecret.Data[corev1.TLSCertKey], intermediatesSecret.Data[targetPolicy.Chain.Key()])
+	if err != nil {
+		return nil, fmt.Errorf("could not assemble the certificate chain of Secret [%s/%s]: %v", sourceSecret.Namespace, sourceSecret.Name, err)
+	}
+
+	chainedSecret := sourceSecret.DeepCopy()
+	chainedSecret.Data[corev1.TLSCertKey] = chain
+
+	return chainedSecret, nil
+}
+
+func (w *Writer) render(ctx context.Context, targetPolicy *policy.Policy, sourceSecret *corev1.Secret, targetSecret *corev1.Secret, data map[string][]byte) error {
+	writtenData := data
+
+	// The keystores are derived from the data, and are also regenerated once their settings or password change
+	var hash string
+	if targetPolicy.Keystores != nil && sourceSecret.Type == corev1.SecretTypeTLS {
+		var err error
+
+		writtenData, hash, err = w.withKeystores(ctx, targetPolicy.Keystores, sourceSecret, targetSecret.Namespace, data, targetSecret.Data)
+		if err != nil {
+			return err
+		}
+	}
+
+	targetSecret.Data = writtenData
+	Annotate(targetSecret, sourceSecret, data)
+	targetSecret.Annotations[WrittenHashAnnotation] = DataHash(writtenData)
+
+	if hash == "" {
+		delete(targetSecret.Annotations, KeystoresHashAnnotation)
+	} else {
+		targetSecret.Annotations[KeystoresHashAnnotation] = hash
+	}
+
+	return nil
+}
+
+func (w *Writer) adopt(ctx context.Context, targetPolicy *policy.Policy, sourceSecret *corev1.Secret, data map[string][]byte, existingSecret *corev1.Secret, ownerReferences []metav1.OwnerReference, pushed bool) error {
+	existingSecretName := types.NamespacedName{
+		Namespace: existingSecret.Namespace,
+		Name:      existingSecret.Name,
+	}
+
+	// The type of a Secret cannot be changed
+	targetType := copyType(sourceSecret.Type, data)
+	if existingSecret.Type != targetType {
+		w.recorder.Eventf(
+			existingSecret,
+			corev1.EventTypeWarning,
+			"AdoptionFailed",
+			"Cannot adopt this Secret of type %s as a copy of Secret [%s/%s] of type %s",
+			existingSecret.Type,
+			sourceSecret.Namespace,
+			sourceSecret.Name,
+			targetType,
+		)
+		return fmt.Errorf("cannot adopt Secret [%s] of type %s as a copy of type %s", existingSecretName, existingSecret.Type, targetType)
+	}
+
+	if existingSecret.Labels == nil {
+		existingSecret.Labels = map[string]string{}
+	}
+	for key, value := range Labels(sourceSecret.Name) {
+		existingSecret.Labels[key] = value
+	}
+
+	for _, ownerReference := range ownerReferences {
+		if !hasOwnerReference(existingSecret, ownerReference) {
+			existingSecret.OwnerReferences = append(existingSecret.OwnerReferences, ownerReference)
+		}
+	}
+
+	if pushed {
+		if existingSecret.Annotations == nil {
+			existingSecret.Annotations = map[string]string{}
+		}
+		existingSecret.Annotations[PushedAnnotation] = "true"
+	}
+
+	err := w.render(ctx, targetPolicy, sourceSecret, existingSecret, data)
+	if err != nil {
+		return fmt.Errorf("failed to adopt Secret [%s]: %v", existingSecretName, err)
+	}
+
+	err = w.client.Update(ctx, existingSecret)
+	if err != nil {
+		return fmt.Errorf("failed to adopt Secret [%s]: %v", existingSecretName, err)
+	}
+
+	log.Infof("Successfully adopted Secret [%s]", existingSecretName)
+	w.recorder.Eventf(
+		existingSecret,
+		corev1.EventTypeNormal,
+		"Adopted",
+		"Adopted this Secret as a copy of Secret [%s/%s]",
+		sourceSecret.Namespace,
+		sourceSecret.Name,
+	)
+
+	return nil
+}
+
+// overwrite replaces an existing Secret not managed by the injector with a new copy
+func (w *Writer) overwrite(ctx context.Context, targetPolicy *policy.Policy, sourceSecret *corev1.Secret, data map[string][]byte, existingSecret *corev1.Secret, ownerReferences []metav1.OwnerReference, pushed bool) error {
+	existingSecretName := types.NamespacedName{
+		Namespace: existingSecret.Namespace,
+		Name:      existingSecret.Name,
+	}
+
+	targetSecret := newCopy(sourceSecret, data, existingSecretName, ownerReferences)
+	if pushed {
+		targetSecret.Annotations = map[string]string{PushedAnnotation: "true"}
+	}
+
+	// Render the copy before deleting the existing Secret, so that a failure leaves the existing Secret in place
+	err := w.render(ctx, targetPolicy, sourceSecret, targetSecret, data)
+	if err != nil {
+		return fmt.Errorf("failed to overwrite Secret [%s]: %v", existingSecretName, err)
+	}
+
+	// Delete and create the Secret, as its type might be different
+	err = w.client.Delete(ctx, existingSecret, client.Preconditions{UID: &existingSecret.UID})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete Secret [%s] to overwrite it: %v", existingSecretName, err)
+	}
+
+	err = w.client.Create(ctx, targetSecret)
+	if err != nil {
+		return fmt.Errorf("failed to overwrite Secret [%s]: %v", existingSecretName, err)
+	}
+
+	log.Infof("Successfully overwrote Secret [%s]", existingSecretName)
+	w.recorder.Eventf(
+		targetSecret,
+		corev1.EventTypeNormal,
+		"Overwritten",
+		"Overwrote the existing Secret with a copy of Secret [%s/%s]",
+		sourceSecret.Namespace,
+		sourceSecret.Name,
+	)
+
+	return nil
+}
+
+func newCopy(sourceSecret *corev1.Secret, data map[string][]byte, targetSecretName types.NamespacedName, ownerReferences []metav1.OwnerReference) *corev1.Secret {
+	targetSecret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.Version,
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       targetSecretName.Namespace,
+			Name:            targetSecretName.Name,
+			Labels:          Labels(sourceSecret.Name),
+			OwnerReferences: ownerReferences,
+		},
+		Type: copyType(sourceSecret.Type, data),
+	}
+
+	return targetSecret
+}
+
+// copyData returns the data of the source Secret written into its copies under the policy
+func copyData(targetPolicy *policy.Policy, sourceSecret *corev1.Secret) map[string][]byte {
+	return targetPolicy.Keys.Apply(targetPolicy.Public.Filter(sourceSecret.Data))
+}
+
+// copyType returns the type of a copy holding the data, which can no longer be a TLS Secret once its keys are renamed
+func copyType(sourceType corev1.SecretType, data map[string][]byte) corev1.SecretType {
+	if sourceType != corev1.SecretTypeTLS {
+		return sourceType
+	}
+
+	_, hasCertificate := data[corev1.TLSCertKey]
+	_, hasPrivateKey := data[corev1.TLSPrivateKeyKey]
+	if !hasCertificate || !hasPrivateKey {
+		return corev1.SecretTypeOpaque
+	}
+
+	return sourceType
+}
+
+func hasIngressOwner(object metav1.Object) bool {
+	for _, ownerReference := range object.GetOwnerReferences() {
+		if ownerReference.Kind == "Ingress" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasOwnerReference(object metav1.Object, ownerReference metav1.OwnerReference) bool {
+	for _, existingOwnerReference := range object.GetOwnerReferences() {
+		if existingOwnerReference.UID == ownerReference.UID {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,442 @@
+package replica
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/certificate"
+	"tls-secret-injector/pkg/policy"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCreate(t *testing.T) {
+	sourceSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "tls-example-io",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       []byte("certificate"),
+			corev1.TLSPrivateKeyKey: []byte("private key"),
+		},
+	}
+
+	newExistingSecret := func(secretType corev1.SecretType) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "target",
+				Name:      "tls-example-io",
+				Labels: map[string]string{
+					"team": "payments",
+				},
+			},
+			Type: secretType,
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       []byte("hand-copied certificate"),
+				corev1.TLSPrivateKeyKey: []byte("hand-copied private key"),
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		conflict       policy.ConflictPolicy
+		keys           *policy.KeyMapping
+		keystores      *policy.Keystores
+		existingSecret *corev1.Secret
+		written        bool
+		failed         bool
+		certificate    string
+		labels         map[string]string
+		event          string
+	}{
+		"create target secret": {
+			conflict:    policy.ConflictSkip,
+			written:     true,
+			certificate: "certificate",
+			labels:      Labels("tls-example-io"),
+		},
+		"create target secret with renamed keys": {
+			conflict:    policy.ConflictSkip,
+			keys:        &policy.KeyMapping{Rename: map[string]string{corev1.TLSCertKey: "cert.pem"}},
+			written:     true,
+			certificate: "",
+			labels:      Labels("tls-example-io"),
+		},
+		"skip existing secret": {
+			conflict:       policy.ConflictSkip,
+			existingSecret: newExistingSecret(corev1.SecretTypeTLS),
+			certificate:    "hand-copied certificate",
+			labels:         map[string]string{"team": "payments"},
+			event:          "Normal ConflictSkipped",
+		},
+		"adopt existing secret": {
+			conflict:       policy.ConflictAdopt,
+			existingSecret: newExistingSecret(corev1.SecretTypeTLS),
+			written:        true,
+			certificate:    "certificate",
+			labels: map[string]string{
+				"team":          "payments",
+				NameLabel:       "tls-secret-injector",
+				SourceNameLabel: "tls-example-io",
+			},
+			event: "Normal Adopted",
+		},
+		"fail to adopt existing secret of another type": {
+			conflict:       policy.ConflictAdopt,
+			existingSecret: newExistingSecret(corev1.SecretTypeOpaque),
+			failed:         true,
+			certificate:    "hand-copied certificate",
+			labels:         map[string]string{"team": "payments"},
+			event:          "Warning AdoptionFailed",
+		},
+		"overwrite existing secret": {
+			conflict:       policy.ConflictOverwrite,
+			existingSecret: newExistingSecret(corev1.SecretTypeOpaque),
+			written:        true,
+			certificate:    "certificate",
+			labels:         Labels("tls-example-io"),
+			event:          "Normal Overwritten",
+		},
+		"keep existing secret when the copy cannot be rendered": {
+			conflict:       policy.ConflictOverwrite,
+			keystores:      &policy.Keystores{Formats: []policy.KeystoreFormat{policy.KeystorePKCS12}, PasswordSecret: "missing-password"},
+			existingSecret: newExistingSecret(corev1.SecretTypeOpaque),
+			failed:         true,
+			certificate:    "hand-copied certificate",
+			labels:         map[string]string{"team": "payments"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clientBuilder := fake.NewClientBuilder()
+			if test.existingSecret != nil {
+				clientBuilder = clientBuilder.WithObjects(test.existingSecret)
+			}
+
+			// Create a client and the writer
+			fakeClient := clientBuilder.Build()
+			recorder := record.NewFakeRecorder(10)
+			writer := NewWriter(fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), policy.NewResolver(fakeClient, nil, policy.Policy{Conflict: test.conflict, Keys: test.keys, Keystores: test.keystores}), recorder)
+
+			targetSecretName := types.NamespacedName{
+				Namespace: "target",
+				Name:      "tls-example-io",
+			}
+
+			written, err := writer.Create(context.TODO(), sourceSecret, targetSecretName, nil)
+
+			assert.Equal(t, test.written, written)
+			assert.Equal(t, test.failed, err != nil)
+
+			// Check the resulting target Secret and the emitted Event
+			var targetSecret corev1.Secret
+			err = fakeClient.Get(context.TODO(), targetSecretName, &targetSecret)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.certificate, string(targetSecret.Data[corev1.TLSCertKey]))
+			assert.Equal(t, test.labels, targetSecret.Labels)
+
+			if test.keys != nil {
+				assert.Equal(t, corev1.SecretTypeOpaque, targetSecret.Type)
+				assert.Equal(t, "certificate", string(targetSecret.Data["cert.pem"]))
+			}
+
+			if test.event == "" {
+				assert.Empty(t, recorder.Events)
+			} else {
+				assert.Contains(t, <-recorder.Events, test.event)
+			}
+
+			// Skipped Secrets are only reported the first time
+			if test.event == "Normal ConflictSkipped" {
+				written, err = writer.Create(context.TODO(), sourceSecret, targetSecretName, nil)
+
+				assert.NoError(t, err)
+				assert.False(t, written)
+				assert.Empty(t, recorder.Events)
+			}
+		})
+	}
+}
+
+func TestPushExistingCopy(t *testing.T) {
+	sourceSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "tls-example-io",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       []byte("certificate"),
+			corev1.TLSPrivateKeyKey: []byte("private key"),
+		},
+	}
+
+	// A copy made for an Ingress, which the garbage collector deletes along with it
+	ingressCopy := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "target",
+			Name:      "tls-example-io",
+			Labels:    Labels("tls-example-io"),
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "networking.k8s.io/v1", Kind: "Ingress", Name: "example-io", UID: "ingress-uid"},
+			},
+		},
+		Type: corev1.SecretTypeTLS,
+	}
+
+	fakeClient := fake.NewClientBuilder().WithObjects(sourceSecret, ingressCopy).Build()
+	policies := policy.NewResolver(fakeClient, nil, policy.Policy{Conflict: policy.ConflictSkip})
+	writer := NewWriter(fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), policies, record.NewFakeRecorder(10))
+
+	targetSecretName := types.NamespacedName{Namespace: "target", Name: "tls-example-io"}
+
+	created, err := writer.Push(context.TODO(), sourceSecret, targetSecretName)
+	assert.NoError(t, err)
+	assert.False(t, created)
+
+	// Verify that the copy is kept for its source Secret rather than for the Ingress
+	targetSecret := &corev1.Secret{}
+	assert.NoError(t, fakeClient.Get(context.TODO(), targetSecretName, targetSecret))
+	assert.True(t, IsPushed(targetSecret))
+	assert.Empty(t, targetSecret.OwnerReferences)
+}
+
+func TestUpdateRemappedCopy(t *testing.T) {
+	sourceSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "tls-example-io",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       []byte("certificate"),
+			corev1.TLSPrivateKeyKey: []byte("private key"),
+		},
+	}
+
+	existingCopy := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "target",
+			Name:      "tls-example-io",
+			Labels:    Labels(sourceSecret.Name),
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: sourceSecret.Data,
+	}
+
+	// Create a client and a writer whose policy renames the certificate of the existing TLS copy
+	fakeClient := fake.NewClientBuilder().WithObjects(existingCopy).Build()
+	recorder := record.NewFakeRecorder(10)
+	keys := &policy.KeyMapping{Rename: map[string]string{corev1.TLSCertKey: "cert.pem"}}
+	writer := NewWriter(fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), policy.NewResolver(fakeClient, nil, policy.Policy{Keys: keys}), recorder)
+
+	targetSecretName := types.NamespacedName{Namespace: "target", Name: "tls-example-io"}
+	targetSecret := &corev1.Secret{}
+
+	assert.NoError(t, fakeClient.Get(context.TODO(), targetSecretName, targetSecret))
+
+	data, err := writer.Data(context.TODO(), sourceSecret, targetSecretName.Namespace)
+	assert.NoError(t, err)
+
+	err = writer.Update(context.TODO(), sourceSecret, targetSecret, data)
+	assert.NoError(t, err)
+
+	// Verify that the copy was recreated with the type its data needs
+	updatedSecret := &corev1.Secret{}
+	err = fakeClient.Get(context.TODO(), targetSecretName, updatedSecret)
+
+	assert.NoError(t, err)
+	assert.Equal(t, corev1.SecretTypeOpaque, updatedSecret.Type)
+	assert.Equal(t, "certificate", string(updatedSecret.Data["cert.pem"]))
+	assert.NotContains(t, updatedSecret.Data, corev1.TLSCertKey)
+	assert.True(t, IsUpToDate(updatedSecret, data))
+	assert.Contains(t, <-recorder.Events, "Normal Recreated")
+}
+
+func TestCreateWithChain(t *testing.T) {
+	root, rootKey := newTestCertificate(t, "root", nil, nil)
+	leaf, _ := newTestCertificate(t, "example.io", root, rootKey)
+	other, _ := newTestCertificate(t, "other", nil, nil)
+
+	newSourceSecret := func(namespace string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      "tls-example-io",
+			},
+			Type: corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       pemCertificate(leaf),
+				corev1.TLSPrivateKeyKey: []byte("private key"),
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		namespace    string
+		intermediate *x509.Certificate
+		chain        []byte
+	}{
+		"append intermediates": {
+			namespace:    "source",
+			intermediate: root,
+			chain:        append(pemCertificate(leaf), pemCertificate(root)...),
+		},
+		"append intermediates from the namespace of a referenced Secret": {
+			namespace:    "team",
+			intermediate: root,
+			chain:        append(pemCertificate(leaf), pemCertificate(root)...),
+		},
+		"fail on unrelated intermediates": {
+			namespace:    "source",
+			intermediate: other,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			sourceSecret := newSourceSecret(test.namespace)
+			intermediatesSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: test.namespace,
+					Name:      "intermediates",
+				},
+				Data: map[string][]byte{
+					"ca.crt": pemCertificate(test.intermediate),
+				},
+			}
+
+			// Create a client and the writer with a policy completing the chains
+			fakeClient := fake.NewClientBuilder().WithObjects(intermediatesSecret).Build()
+			policies := policy.NewResolver(fakeClient, nil, policy.Policy{
+				Conflict: policy.ConflictSkip,
+				Chain:    &policy.Chain{IntermediatesSecret: "intermediates", IntermediatesKey: "ca.crt"},
+			})
+			writer := NewWriter(fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), policies, record.NewFakeRecorder(10))
+
+			targetSecretName := types.NamespacedName{
+				Namespace: "target",
+				Name:      "tls-example-io",
+			}
+
+			written, err := writer.Create(context.TODO(), sourceSecret, targetSecretName, nil)
+
+			if test.chain == nil {
+				assert.False(t, written)
+				assert.Error(t, err)
+				return
+			}
+
+			assert.True(t, written)
+			assert.NoError(t, err)
+
+			// Check the chain written into the copy and its recorded fingerprint
+			var targetSecret corev1.Secret
+			err = fakeClient.Get(context.TODO(), targetSecretName, &targetSecret)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.chain, targetSecret.Data[corev1.TLSCertKey])
+			assert.Equal(t, certificate.ChainFingerprint(test.chain), targetSecret.Annotations[ChainFingerprintAnnotation])
+			assert.Equal(t, pemCertificate(leaf), sourceSecret.Data[corev1.TLSCertKey])
+		})
+	}
+}
+
+func TestIntermediatesCache(t *testing.T) {
+	root, rootKey := newTestCertificate(t, "root", nil, nil)
+	leaf, _ := newTestCertificate(t, "example.io", root, rootKey)
+
+	sourceSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "tls-example-io",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       pemCertificate(leaf),
+			corev1.TLSPrivateKeyKey: []byte("private key"),
+		},
+	}
+
+	intermediatesSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "intermediates",
+		},
+		Data: map[string][]byte{
+			"ca.crt": pemCertificate(root),
+		},
+	}
+
+	// Create a client and the writer with a policy completing the chains
+	fakeClient := fake.NewClientBuilder().WithObjects(intermediatesSecret).Build()
+	policies := policy.NewResolver(fakeClient, nil, policy.Policy{
+		Chain: &policy.Chain{IntermediatesSecret: "intermediates", IntermediatesKey: "ca.crt"},
+	})
+	writer := NewWriter(fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), policies, record.NewFakeRecorder(10))
+
+	// Verify that the intermediates Secret is only fetched once under the cache
+	ctx := WithIntermediatesCache(context.TODO())
+
+	_, err := writer.Data(ctx, sourceSecret, "target")
+	assert.NoError(t, err)
+
+	assert.NoError(t, fakeClient.Delete(context.TODO(), intermediatesSecret))
+
+	data, err := writer.Data(ctx, sourceSecret, "other-target")
+	assert.NoError(t, err)
+	assert.Equal(t, append(pemCertificate(leaf), pemCertificate(root)...), data[corev1.TLSCertKey])
+
+	_, err = writer.Data(context.TODO(), sourceSecret, "target")
+	assert.Error(t, err)
+}
+
+// newTestCertificate returns a CA certificate signed by the parent, or self-signed without parent, and its key
+func newTestCertificate(t *testing.T, commonName string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	if parent == nil {
+		parent, parentKey = template, privateKey
+	}
+
+	certificateDER, err := x509.CreateCertificate(rand.Reader, template, parent, &privateKey.PublicKey, parentKey)
+	assert.NoError(t, err)
+
+	parsedCertificate, err := x509.ParseCertificate(certificateDER)
+	assert.NoError(t, err)
+
+	return parsedCertificate, privateKey
+}
+
+func pemCertificate(parsedCertificate *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: parsedCertificate.Raw})
+}
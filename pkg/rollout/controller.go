@@ -0,0 +1,64 @@
+package rollout
+
+import (
+	"fmt"
+	"time"
+
+	"tls-secret-injector/pkg/replica"
+	"tls-secret-injector/pkg/tracing"
+
+	"golang.org/x/time/rate"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// NewController rolls out the opted-in workloads using a copy whenever its data changes, at most once per interval
+func NewController(mgr manager.Manager, interval time.Duration) error {
+	// Setup the reconciler, which handles one copy at a time so that the limiter spreads the rollouts
+	rolloutController, err := controller.New("rollout", mgr, controller.Options{
+		Reconciler:              tracing.Reconciler("rollout", newReconciler(mgr.GetClient(), rate.NewLimiter(rate.Every(interval), 1))),
+		MaxConcurrentReconciles: 1,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to set up rollout controller: %v", err)
+	}
+
+	// Only the copies whose data changed trigger rollouts
+	copyChanged := predicate.Funcs{
+		CreateFunc: func(event event.CreateEvent) bool {
+			return false
+		},
+		UpdateFunc: func(event event.UpdateEvent) bool {
+			return replica.IsManaged(event.ObjectNew) &&
+				event.ObjectOld.GetAnnotations()[replica.DataHashAnnotation] != event.ObjectNew.GetAnnotations()[replica.DataHashAnnotation]
+		},
+		DeleteFunc: func(event event.DeleteEvent) bool {
+			return false
+		},
+		GenericFunc: func(event event.GenericEvent) bool {
+			return false
+		},
+	}
+
+	// Watch the metadata of the copies held by Secrets and ConfigMaps and enqueue their object key
+	for _, copyType := range []client.Object{replica.NewSecretMetadata(), replica.NewConfigMapMetadata()} {
+		err = rolloutController.Watch(
+			&source.Kind{
+				Type: copyType,
+			},
+			&handler.EnqueueRequestForObject{},
+			copyChanged,
+		)
+		if err != nil {
+			return fmt.Errorf("unable to watch copies: %v", err)
+		}
+	}
+
+	return nil
+}
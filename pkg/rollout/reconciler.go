@@ -0,0 +1,175 @@
+package rollout
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"tls-secret-injector/pkg/replica"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// RolloutAnnotation opts a Deployment, StatefulSet or DaemonSet into rollouts whenever a copy it uses changes
+	RolloutAnnotation = "tls-secret-injector/rollout"
+	// ChecksumAnnotation holds on the pod template the checksum of the data of the copies the workload uses
+	ChecksumAnnotation = "tls-secret-injector/checksum"
+)
+
+type reconciler struct {
+	client  client.Client
+	limiter *rate.Limiter
+}
+
+func newReconciler(client client.Client, limiter *rate.Limiter) *reconciler {
+	return &reconciler{
+		client:  client,
+		limiter: limiter,
+	}
+}
+
+func (r *reconciler) Reconcile(ctx context.Context, request reconcile.Request) (result reconcile.Result, err error) {
+	log.Debugf("Received request to roll out the workloads using copy [%s]", request.NamespacedName)
+
+	for _, workload := range workloads {
+		list := workload.newList()
+
+		err = r.client.List(ctx, list, client.InNamespace(request.Namespace))
+		if err != nil {
+			err = fmt.Errorf("could not list %ss in namespace [%s]: %v", workload.kind, request.Namespace, err)
+			log.Error(err)
+			return
+		}
+
+		for _, object := range workload.items(list) {
+			if object.GetAnnotations()[RolloutAnnotation] != "true" {
+				continue
+			}
+
+			err = r.rollout(ctx, workload, object, request.Name)
+			if err != nil {
+				log.Error(err)
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// rollout patches the pod template of the workload using the copy with the checksum of all copies it uses, unless
+// the checksum did not change
+func (r *reconciler) rollout(ctx context.Context, workload workload, object client.Object, copyName string) error {
+	podTemplate := workload.podTemplate(object)
+
+	secretNames, configMapNames := references(podTemplate)
+	if !contains(secretNames, copyName) && !contains(configMapNames, copyName) {
+		return nil
+	}
+
+	checksum, err := r.checksum(ctx, object.GetNamespace(), secretNames, configMapNames)
+	if err != nil {
+		return err
+	}
+
+	if checksum == podTemplate.Annotations[ChecksumAnnotation] {
+		log.Debugf("Skipping rollout of %s [%s/%s] as the copies it uses did not change", workload.kind, object.GetNamespace(), object.GetName())
+		return nil
+	}
+
+	// Spread the rollouts over time across all namespaces
+	err = r.limiter.Wait(ctx)
+	if err != nil {
+		return err
+	}
+
+	patch := client.MergeFrom(object.DeepCopyObject().(client.Object))
+
+	if podTemplate.Annotations == nil {
+		podTemplate.Annotations = map[string]string{}
+	}
+	podTemplate.Annotations[ChecksumAnnotation] = checksum
+
+	err = r.client.Patch(ctx, object, patch)
+	if err != nil {
+		return fmt.Errorf("failed to roll out %s [%s/%s]: %v", workload.kind, object.GetNamespace(), object.GetName(), err)
+	}
+
+	log.Infof("Successfully rolled out %s [%s/%s]", workload.kind, object.GetNamespace(), object.GetName())
+
+	return nil
+}
+
+// checksum returns a hash over the data of the managed copies among the Secrets and ConfigMaps
+func (r *reconciler) checksum(ctx context.Context, namespace string, secretNames []string, configMapNames []string) (string, error) {
+	var entries []string
+
+	for _, secretName := range secretNames {
+		entry, err := r.checksumEntry(ctx, types.NamespacedName{Namespace: namespace, Name: secretName}, replica.NewSecretMetadata())
+		if err != nil {
+			return "", err
+		}
+
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	for _, configMapName := range configMapNames {
+		entry, err := r.checksumEntry(ctx, types.NamespacedName{Namespace: namespace, Name: configMapName}, replica.NewConfigMapMetadata())
+		if err != nil {
+			return "", err
+		}
+
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Strings(entries)
+
+	hash := sha256.New()
+	for _, entry := range entries {
+		fmt.Fprintln(hash, entry)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (r *reconciler) checksumEntry(ctx context.Context, name types.NamespacedName, object *metav1.PartialObjectMetadata) (string, error) {
+	kind := object.GetObjectKind().GroupVersionKind().Kind
+
+	err := r.client.Get(ctx, name, object)
+	if errors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not fetch [%s]: %v", name, err)
+	}
+
+	if !replica.IsManaged(object) {
+		return "", nil
+	}
+
+	return fmt.Sprintf("%s/%s=%s", kind, name.Name, object.GetAnnotations()[replica.DataHashAnnotation]), nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,116 @@
+package rollout
+
+import (
+	"context"
+	"testing"
+
+	"tls-secret-injector/pkg/replica"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcile(t *testing.T) {
+	copiedSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "target",
+			Name:      "tls-example-io",
+			Labels:    replica.Labels("tls-example-io"),
+			Annotations: map[string]string{
+				replica.DataHashAnnotation: "new-hash",
+			},
+		},
+	}
+
+	newDeployment := func(name string, annotations map[string]string, secretName string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "target",
+				Name:        name,
+				Annotations: annotations,
+			},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Volumes: []corev1.Volume{
+							{
+								Name: "tls",
+								VolumeSource: corev1.VolumeSource{
+									Secret: &corev1.SecretVolumeSource{SecretName: secretName},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	optedIn := map[string]string{RolloutAnnotation: "true"}
+
+	tests := map[string]struct {
+		deployment *appsv1.Deployment
+		rolled     bool
+	}{
+		"roll out opted-in deployment": {
+			deployment: newDeployment("api", optedIn, copiedSecret.Name),
+			rolled:     true,
+		},
+		"skip deployment not opted in": {
+			deployment: newDeployment("api", nil, copiedSecret.Name),
+		},
+		"skip deployment using other secrets": {
+			deployment: newDeployment("api", optedIn, "other"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Create a client and the reconciler
+			fakeClient := fake.NewClientBuilder().WithObjects(copiedSecret, test.deployment).Build()
+			reconciler := newReconciler(fakeClient, rate.NewLimiter(rate.Inf, 1))
+
+			// Reconcile and check for errors
+			request := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: copiedSecret.Namespace,
+					Name:      copiedSecret.Name,
+				},
+			}
+
+			_, err := reconciler.Reconcile(context.TODO(), request)
+			assert.NoError(t, err)
+
+			// Check the checksum annotation of the pod template
+			deployment := &appsv1.Deployment{}
+			err = fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(test.deployment), deployment)
+
+			assert.NoError(t, err)
+
+			checksum := deployment.Spec.Template.Annotations[ChecksumAnnotation]
+			assert.Equal(t, test.rolled, checksum != "")
+
+			if !test.rolled {
+				return
+			}
+
+			// Reconcile again and verify that the unchanged workload is not patched
+			resourceVersion := deployment.ResourceVersion
+
+			_, err = reconciler.Reconcile(context.TODO(), request)
+			assert.NoError(t, err)
+
+			err = fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(test.deployment), deployment)
+
+			assert.NoError(t, err)
+			assert.Equal(t, resourceVersion, deployment.ResourceVersion)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package rollout
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// workload gives access to the pod template of the kinds of workloads that can be rolled
+type workload struct {
+	kind        string
+	newList     func() client.ObjectList
+	items       func(list client.ObjectList) []client.Object
+	podTemplate func(object client.Object) *corev1.PodTemplateSpec
+}
+
+var workloads = []workload{
+	{
+		kind: "Deployment",
+		newList: func() client.ObjectList {
+			return &appsv1.DeploymentList{}
+		},
+		items: func(list client.ObjectList) []client.Object {
+			var objects []client.Object
+			for i := range list.(*appsv1.DeploymentList).Items {
+				objects = append(objects, &list.(*appsv1.DeploymentList).Items[i])
+			}
+			return objects
+		},
+		podTemplate: func(object client.Object) *corev1.PodTemplateSpec {
+			return &object.(*appsv1.Deployment).Spec.Template
+		},
+	},
+	{
+		kind: "StatefulSet",
+		newList: func() client.ObjectList {
+			return &appsv1.StatefulSetList{}
+		},
+		items: func(list client.ObjectList) []client.Object {
+			var objects []client.Object
+			for i := range list.(*appsv1.StatefulSetList).Items {
+				objects = append(objects, &list.(*appsv1.StatefulSetList).Items[i])
+			}
+			return objects
+		},
+		podTemplate: func(object client.Object) *corev1.PodTemplateSpec {
+			return &object.(*appsv1.StatefulSet).Spec.Template
+		},
+	},
+	{
+		kind: "DaemonSet",
+		newList: func() client.ObjectList {
+			return &appsv1.DaemonSetList{}
+		},
+		items: func(list client.ObjectList) []client.Object {
+			var objects []client.Object
+			for i := range list.(*appsv1.DaemonSetList).Items {
+				objects = append(objects, &list.(*appsv1.DaemonSetList).Items[i])
+			}
+			return objects
+		},
+		podTemplate: func(object client.Object) *corev1.PodTemplateSpec {
+			return &object.(*appsv1.DaemonSet).Spec.Template
+		},
+	},
+}
+
+// references returns the names of the Secrets and ConfigMaps the pod template mounts or reads its environment from
+func references(podTemplate *corev1.PodTemplateSpec) (secretNames []string, configMapNames []string) {
+	for _, volume := range podTemplate.Spec.Volumes {
+		if volume.Secret != nil {
+			secretNames = append(secretNames, volume.Secret.SecretName)
+		}
+		if volume.ConfigMap != nil {
+			configMapNames = append(configMapNames, volume.ConfigMap.Name)
+		}
+
+		if volume.Projected != nil {
+			for _, projection := range volume.Projected.Sources {
+				if projection.Secret != nil {
+					secretNames = append(secretNames, projection.Secret.Name)
+				}
+				if projection.ConfigMap != nil {
+					configMapNames = append(configMapNames, projection.ConfigMap.Name)
+				}
+			}
+		}
+	}
+
+	containers := append(append([]corev1.Container{}, podTemplate.Spec.InitContainers...), podTemplate.Spec.Containers...)
+	for _, container := range containers {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef != nil {
+				secretNames = append(secretNames, envFrom.SecretRef.Name)
+			}
+			if envFrom.ConfigMapRef != nil {
+				configMapNames = append(configMapNames, envFrom.ConfigMapRef.Name)
+			}
+		}
+
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				secretNames = append(secretNames, env.ValueFrom.SecretKeyRef.Name)
+			}
+			if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil {
+				configMapNames = append(configMapNames, env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,69 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+
+	"tls-secret-injector/pkg/replica"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// updateConfigMaps updates the ConfigMaps holding the public certificates of the source Secret
+func (r *reconciler) updateConfigMaps(ctx context.Context, sourceSecret *corev1.Secret, fromSource bool) error {
+	sourceSecretName := types.NamespacedName{
+		Name: sourceSecret.Name,
+	}
+	if !fromSource {
+		sourceSecretName.Namespace = sourceSecret.Namespace
+	}
+
+	configMapMetadataList, err := r.lookup.ConfigMapCopiesOf(ctx, sourceSecretName)
+	if err != nil {
+		return err
+	}
+
+	for _, targetConfigMapMetadata := range configMapMetadataList {
+		targetConfigMapName := types.NamespacedName{
+			Namespace: targetConfigMapMetadata.ObjectMeta.Namespace,
+			Name:      targetConfigMapMetadata.ObjectMeta.Name,
+		}
+
+		// Skip the ConfigMaps with the same name that were copied from elsewhere
+		if !r.isCopyOf(ctx, &targetConfigMapMetadata, sourceSecret, fromSource) {
+			continue
+		}
+
+		data, err := r.writer.Data(ctx, sourceSecret, targetConfigMapName.Namespace)
+		if err != nil {
+			return fmt.Errorf("could not resolve the data of the target ConfigMap [%s]: %v", targetConfigMapName, err)
+		}
+
+		targetConfigMap := &corev1.ConfigMap{}
+
+		err = r.client.Get(ctx, targetConfigMapName, targetConfigMap)
+		if err != nil {
+			return fmt.Errorf("could not fetch the target ConfigMap [%s]: %v", targetConfigMapName, err)
+		}
+
+		// Skip the update if the target ConfigMap already holds the same data
+		if replica.IsConfigMapUpToDate(targetConfigMap, data) {
+			log.Debugf("Skipping update of ConfigMap [%s] as it is already up to date", targetConfigMapName)
+			continue
+		}
+
+		replica.RenderConfigMap(sourceSecret, targetConfigMap, data)
+
+		err = r.client.Update(ctx, targetConfigMap)
+		if err != nil {
+			return fmt.Errorf("failed to update target ConfigMap [%s]: %v", targetConfigMapName, err)
+		}
+
+		log.Infof("Successfully updated ConfigMap [%s]", targetConfigMapName)
+	}
+
+	return nil
+}
@@ -1,34 +1,77 @@
 package secret
 
 import (
+	"context"
 	"fmt"
+	"reflect"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/history"
+	"tls-secret-injector/pkg/policy"
+	"tls-secret-injector/pkg/replica"
+	"tls-secret-injector/pkg/tracing"
 
 	log "github.com/sirupsen/logrus"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
-func NewController(mgr manager.Manager, sourceNamespace string) error {
+func NewController(mgr manager.Manager, secretSource backend.SecretSource, policies *policy.Resolver, secretTypes replica.Types, fanOut FanOut, waves *Waves, secretHistory *history.History, trigger *Trigger) error {
 	// Setup the reconciler
+	recorder := mgr.GetEventRecorderFor("tls-secret-injector")
+	writer := replica.NewWriter(mgr.GetClient(), secretSource, policies, recorder)
+
+	// Events are read from the API server, as caching them would hold all Events of the cluster in memory
+	secretReconciler := newReconciler(mgr.GetClient(), mgr.GetAPIReader(), secretSource, writer, secretTypes, fanOut, waves, secretHistory, recorder)
+
 	secretController, err := controller.New("secret", mgr, controller.Options{
-		Reconciler: newReconciler(mgr.GetClient(), sourceNamespace),
+		Reconciler: tracing.Reconciler("secret", secretReconciler),
 	})
 	if err != nil {
 		return fmt.Errorf("unable to set up Secret controller: %v", err)
 	}
 
-	// Watch Secret and enqueue Secret object key
+	// Watch the source for changed Secrets, and the Secrets to resync, and enqueue Secret object key
 	err = secretController.Watch(
-		&source.Kind{
-			Type: &corev1.Secret{},
+		&source.Channel{
+			Source: trigger.events,
 		},
 		&handler.EnqueueRequestForObject{},
+	)
+	if err != nil {
+		return fmt.Errorf("unable to watch Secret: %v", err)
+	}
+
+	// Watch Secrets outside of the source, which Ingresses can reference through annotations, and enqueue Secret object key
+	// while only caching their metadata
+	err = secretController.Watch(
+		&source.Kind{
+			Type: replica.NewSecretMetadata(),
+		},
+		handler.EnqueueRequestsFromMapFunc(func(object client.Object) []reconcile.Request {
+			requests := []reconcile.Request{{NamespacedName: client.ObjectKeyFromObject(object)}}
+
+			// The chains of the copies of Secrets referenced through annotations are completed from their namespace
+			if policies.IsIntermediatesSecret(object.GetName()) {
+				for _, name := range sourcesChainedWith(context.Background(), mgr.GetClient(), policies, object.GetName(), func(sourceNamespace string) bool {
+					return sourceNamespace == object.GetNamespace()
+				}) {
+					requests = append(requests, reconcile.Request{NamespacedName: name})
+				}
+			}
+
+			return requests
+		}),
 		predicate.Funcs{
 			CreateFunc: func(event event.CreateEvent) bool {
 				log.Debugf(
@@ -38,6 +81,11 @@ func NewController(mgr manager.Manager, sourceNamespace string) error {
 				)
 				return false
 			},
+			UpdateFunc: func(event event.UpdateEvent) bool {
+				// Secrets in the source are watched through the source itself as well, which the queue deduplicates,
+				// and the reconciler skips the Secrets without copies before fetching them
+				return !replica.IsManaged(event.ObjectNew)
+			},
 			DeleteFunc: func(event event.DeleteEvent) bool {
 				log.Debugf(
 					"Skipping reconciliation of Secret [%s/%s] as it has been deleted",
@@ -60,5 +108,239 @@ func NewController(mgr manager.Manager, sourceNamespace string) error {
 		return fmt.Errorf("unable to watch Secret: %v", err)
 	}
 
+	// Watch the password Secrets of the keystores and enqueue the key of the source Secrets copied into their namespace,
+	// so the keystores are regenerated once the password rotates
+	err = secretController.Watch(
+		&source.Kind{
+			Type: replica.NewSecretMetadata(),
+		},
+		handler.EnqueueRequestsFromMapFunc(func(object client.Object) []reconcile.Request {
+			return mapPasswordSecretToSourceSecrets(mgr.GetClient(), object)
+		}),
+		predicate.NewPredicateFuncs(func(object client.Object) bool {
+			return policies.IsPasswordSecret(object.GetName()) && !replica.IsManaged(object)
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("unable to watch Secret: %v", err)
+	}
+
+	// Watch the copies marked by the status API of the other replicas and enqueue the key of their source Secret, so
+	// resyncs can be requested from any replica
+	err = secretController.Watch(
+		&source.Kind{
+			Type: replica.NewSecretMetadata(),
+		},
+		handler.EnqueueRequestsFromMapFunc(func(object client.Object) []reconcile.Request {
+			return mapResyncRequestToSourceSecret(secretSource, object)
+		}),
+		predicate.Funcs{
+			CreateFunc: func(event event.CreateEvent) bool {
+				return false
+			},
+			UpdateFunc: func(event event.UpdateEvent) bool {
+				requested := event.ObjectNew.GetAnnotations()[replica.ResyncRequestedAnnotation]
+				return replica.IsManaged(event.ObjectNew) && requested != "" && requested != event.ObjectOld.GetAnnotations()[replica.ResyncRequestedAnnotation]
+			},
+			DeleteFunc: func(event event.DeleteEvent) bool {
+				return false
+			},
+			GenericFunc: func(event event.GenericEvent) bool {
+				return false
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("unable to watch Secret: %v", err)
+	}
+
+	// Watch Namespace and enqueue the key of the source Secrets that should be copied into it
+	err = secretController.Watch(
+		&source.Kind{
+			Type: &corev1.Namespace{},
+		},
+		handler.EnqueueRequestsFromMapFunc(func(object client.Object) []reconcile.Request {
+			return mapNamespaceToSourceSecrets(secretReconciler.pushed, object)
+		}),
+		predicate.Funcs{
+			UpdateFunc: func(event event.UpdateEvent) bool {
+				// Only the labels of a namespace decide whether Secrets are copied into it
+				return !reflect.DeepEqual(event.ObjectOld.GetLabels(), event.ObjectNew.GetLabels())
+			},
+			DeleteFunc: func(event event.DeleteEvent) bool {
+				return false
+			},
+			GenericFunc: func(event event.GenericEvent) bool {
+				return false
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("unable to watch Namespace: %v", err)
+	}
+
+	// Fill the index of the pushed source Secrets once, which their reconciles keep up to date from then on
+	err = mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		sourceSecrets, err := secretSource.List(ctx)
+		if err != nil {
+			log.Errorf("could not list the source Secrets: %v", err)
+			return nil
+		}
+
+		for i := range sourceSecrets {
+			sourceSecret := &sourceSecrets[i]
+			secretReconciler.pushed.update(types.NamespacedName{Namespace: sourceSecret.Namespace, Name: sourceSecret.Name}, sourceSecret)
+		}
+
+		return nil
+	}))
+	if err != nil {
+		return fmt.Errorf("unable to index the pushed source Secrets: %v", err)
+	}
+
+	err = mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		return secretSource.Watch(ctx, func(name types.NamespacedName) {
+			changedNames := []types.NamespacedName{name}
+
+			// The chains of the copies in the namespaces whose policy uses the intermediates Secret are completed from it
+			if policies.IsIntermediatesSecret(name.Name) {
+				changedNames = append(changedNames, sourcesChainedWith(ctx, mgr.GetClient(), policies, name.Name, func(sourceNamespace string) bool {
+					return secretSource.IsSourceNamespace(ctx, sourceNamespace)
+				})...)
+			}
+
+			for _, changedName := range changedNames {
+				trigger.Resync(ctx, changedName)
+			}
+		})
+	}))
+	if err != nil {
+		return fmt.Errorf("unable to watch the source Secrets: %v", err)
+	}
+
 	return nil
 }
+
+// sourcesChainedWith returns the keys of the source Secrets whose copies have their chain completed from the named
+// intermediates Secret, which are those in the namespaces whose policy uses it, copied from the matching namespaces
+func sourcesChainedWith(ctx context.Context, reader client.Reader, policies *policy.Resolver, intermediatesSecret string, matches func(sourceNamespace string) bool) []types.NamespacedName {
+	// Only the metadata of the copies is read, from the cache
+	copyList := &metav1.PartialObjectMetadataList{}
+	copyList.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("SecretList"))
+
+	err := reader.List(ctx, copyList, client.MatchingLabels(replica.ManagedLabels()))
+	if err != nil {
+		log.Errorf("could not list the copies: %v", err)
+		return nil
+	}
+
+	var names []types.NamespacedName
+	chained := map[string]bool{}
+	seen := map[types.NamespacedName]bool{}
+
+	for _, copyMetadata := range copyList.Items {
+		usesIntermediates, found := chained[copyMetadata.Namespace]
+		if !found {
+			namespacePolicy, err := policies.For(ctx, copyMetadata.Namespace)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+
+			usesIntermediates = namespacePolicy.Chain != nil && namespacePolicy.Chain.IntermediatesSecret == intermediatesSecret
+			chained[copyMetadata.Namespace] = usesIntermediates
+		}
+
+		sourceSecretName := types.NamespacedName{
+			Namespace: copyMetadata.Annotations[replica.SourceNamespaceAnnotation],
+			Name:      copyMetadata.Labels[replica.SourceNameLabel],
+		}
+		if !usesIntermediates || sourceSecretName.Name == "" || seen[sourceSecretName] || !matches(sourceSecretName.Namespace) {
+			continue
+		}
+
+		seen[sourceSecretName] = true
+		names = append(names, sourceSecretName)
+	}
+
+	return names
+}
+
+func mapNamespaceToSourceSecrets(pushed *pushIndex, object client.Object) []reconcile.Request {
+	namespace, ok := object.(*corev1.Namespace)
+	if !ok {
+		return nil
+	}
+
+	var requests []reconcile.Request
+
+	for _, sourceSecretName := range pushed.sourcesFor(namespace) {
+		log.Debugf("Found source Secret [%s] to be copied into namespace [%s]", sourceSecretName, namespace.Name)
+
+		requests = append(requests, reconcile.Request{
+			NamespacedName: sourceSecretName,
+		})
+	}
+
+	return requests
+}
+
+func mapResyncRequestToSourceSecret(secretSource backend.SecretSource, object client.Object) []reconcile.Request {
+	sourceSecretName := types.NamespacedName{
+		Namespace: object.GetAnnotations()[replica.SourceNamespaceAnnotation],
+		Name:      object.GetLabels()[replica.SourceNameLabel],
+	}
+	if sourceSecretName.Name == "" {
+		return nil
+	}
+
+	// Copies made before their provenance was recorded come from the source Secret taking precedence for their name
+	if _, ok := object.GetAnnotations()[replica.SourceNamespaceAnnotation]; !ok {
+		sourceSecret, err := secretSource.Get(context.Background(), sourceSecretName.Name)
+		if err != nil {
+			log.Errorf("could not fetch the source Secret [%s]: %v", sourceSecretName.Name, err)
+			return nil
+		}
+
+		sourceSecretName.Namespace = sourceSecret.Namespace
+	}
+
+	log.Infof("Resyncing source Secret [%s] as requested on its copy [%s/%s]", sourceSecretName, object.GetNamespace(), object.GetName())
+
+	return []reconcile.Request{{NamespacedName: sourceSecretName}}
+}
+
+func mapPasswordSecretToSourceSecrets(reader client.Reader, object client.Object) []reconcile.Request {
+	// Only the metadata of the copies is read, from the cache
+	copyList := &metav1.PartialObjectMetadataList{}
+	copyList.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("SecretList"))
+
+	err := reader.List(context.Background(), copyList, client.InNamespace(object.GetNamespace()), client.MatchingLabels(replica.ManagedLabels()))
+	if err != nil {
+		log.Errorf("could not list the copies in namespace [%s]: %v", object.GetNamespace(), err)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	seen := map[types.NamespacedName]bool{}
+
+	for _, copyMetadata := range copyList.Items {
+		// Sources outside of Kubernetes have no namespace
+		sourceSecretName := types.NamespacedName{
+			Namespace: copyMetadata.Annotations[replica.SourceNamespaceAnnotation],
+			Name:      copyMetadata.Labels[replica.SourceNameLabel],
+		}
+		if sourceSecretName.Name == "" || seen[sourceSecretName] {
+			continue
+		}
+
+		log.Debugf("Found source Secret [%s] whose keystores are protected by password Secret [%s/%s]", sourceSecretName, object.GetNamespace(), object.GetName())
+
+		seen[sourceSecretName] = true
+		requests = append(requests, reconcile.Request{
+			NamespacedName: sourceSecretName,
+		})
+	}
+
+	return requests
+}
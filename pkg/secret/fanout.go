@@ -0,0 +1,130 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/util/retry"
+)
+
+// FanOut defines how many copies of a source Secret are updated at the same time, and how fast
+type FanOut struct {
+	// Concurrency is the number of copies updated in parallel, at least one
+	Concurrency int
+	// WriteLimit is the number of copies written per second across all source Secrets, or 0 for no limit
+	WriteLimit float64
+}
+
+func (f FanOut) concurrency() int {
+	if f.Concurrency < 1 {
+		return 1
+	}
+
+	return f.Concurrency
+}
+
+func (f FanOut) limiter() *rate.Limiter {
+	if f.WriteLimit <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+
+	return rate.NewLimiter(rate.Limit(f.WriteLimit), f.concurrency())
+}
+
+// updateSecrets copies the data of the source Secret into the given copies in parallel, skipping those still holding
+// the data last written to them, and returns the aggregated errors of the copies that failed. Requeueing the source
+// Secret relies on that skip rather than on tracking the failed copies: the copies updated before are found up to date
+// before waiting on the write limiter, so only the failed copies are written again.
+func (r *reconciler) updateSecrets(ctx context.Context, sourceSecret *corev1.Secret, copies []targetCopy) error {
+	targets := make(chan targetCopy)
+
+	var (
+		wait   sync.WaitGroup
+		mutex  sync.Mutex
+		failed []error
+	)
+
+	for i := 0; i < r.concurrency; i++ {
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+
+			for target := range targets {
+				err := r.updateSecret(ctx, sourceSecret, target)
+				if err != nil {
+					mutex.Lock()
+					failed = append(failed, err)
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, target := range copies {
+		targets <- target
+	}
+
+	close(targets)
+	wait.Wait()
+
+	return utilerrors.NewAggregate(failed)
+}
+
+// updateSecret copies the data of the source Secret into the copy, retrying with backoff when it changed meanwhile
+func (r *reconciler) updateSecret(ctx context.Context, sourceSecret *corev1.Secret, target targetCopy) error {
+	var upToDate bool
+
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		// Fetch the target Secret
+		targetSecret := &corev1.Secret{}
+
+		err := r.client.Get(ctx, target.name, targetSecret)
+		if err != nil {
+			return fmt.Errorf("could not fetch the target Secret [%s]: %v", target.name, err)
+		}
+
+		// Skip the update if the target Secret holds the data last written to it, which was written from the same data
+		// and keystore settings
+		upToDate, err = r.writer.IsUpToDate(ctx, sourceSecret, targetSecret, target.data)
+		if err != nil || upToDate {
+			return err
+		}
+
+		err = r.limiter.Wait(ctx)
+		if err != nil {
+			return fmt.Errorf("could not wait to update target Secret [%s]: %v", target.name, err)
+		}
+
+		// Copy Secret data from source to target, regenerating the keystores derived from it, and leave conflicts as
+		// they are, so they are retried
+		err = r.writer.Update(ctx, sourceSecret, targetSecret, target.data)
+		if err != nil && !errors.IsConflict(err) {
+			return fmt.Errorf("failed to update target Secret [%s]: %v", target.name, err)
+		}
+
+		return err
+	})
+	if errors.IsConflict(err) {
+		return fmt.Errorf("failed to update target Secret [%s]: %v", target.name, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	if upToDate {
+		log.Debugf("Skipping update of Secret [%s] as it is already up to date", target.name)
+		return nil
+	}
+
+	log.Infof("Successfully updated Secret [%s]", target.name)
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/index"
+	"tls-secret-injector/pkg/index/indexfake"
+	"tls-secret-injector/pkg/replica"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// failingClient fails all updates in the broken namespace, and the first update in the busy namespace with a conflict,
+// counting the updates of each namespace
+type failingClient struct {
+	client.Client
+
+	mutex     sync.Mutex
+	conflicts int
+	updates   map[string]int
+}
+
+func (c *failingClient) Update(ctx context.Context, object client.Object, options ...client.UpdateOption) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.updates == nil {
+		c.updates = map[string]int{}
+	}
+	c.updates[object.GetNamespace()]++
+
+	switch object.GetNamespace() {
+	case "broken":
+		return fmt.Errorf("connection refused")
+
+	case "busy":
+		c.conflicts++
+		if c.conflicts == 1 {
+			return errors.NewConflict(corev1.Resource("secrets"), object.GetName(), fmt.Errorf("object has been modified"))
+		}
+	}
+
+	return c.Client.Update(ctx, object, options...)
+}
+
+func TestReconcileFanOut(t *testing.T) {
+	certificatePEM, privateKeyPEM := newTestCertificate(t)
+
+	sourceSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "tls-example-io",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certificatePEM,
+			corev1.TLSPrivateKeyKey: privateKeyPEM,
+		},
+	}
+
+	namespaces := []string{"broken", "busy"}
+	for i := 0; i < 20; i++ {
+		namespaces = append(namespaces, fmt.Sprintf("target-%d", i))
+	}
+
+	objects := []client.Object{sourceSecret}
+	for _, namespace := range namespaces {
+		objects = append(objects, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      "tls-example-io",
+				Labels:    replica.Labels("tls-example-io"),
+			},
+			Type: corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       []byte("outdated certificate"),
+				corev1.TLSPrivateKeyKey: []byte("outdated private key"),
+			},
+		})
+	}
+
+	// Create a client failing some updates and the reconciler updating four copies at a time
+	fakeClient := &failingClient{Client: indexfake.NewClient(fake.NewClientBuilder().WithObjects(objects...).Build(), index.Register)}
+	reconciler := newReconciler(fakeClient, fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), newWriter(fakeClient), replica.Types{corev1.SecretTypeTLS}, FanOut{Concurrency: 4, WriteLimit: 1000}, nil, nil, record.NewFakeRecorder(10))
+
+	// Reconcile and check that the error only reports the broken copy
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: sourceSecret.Namespace,
+			Name:      sourceSecret.Name,
+		},
+	}
+
+	_, err := reconciler.Reconcile(context.TODO(), request)
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "broken/tls-example-io")
+		assert.NotContains(t, err.Error(), "busy/tls-example-io")
+	}
+
+	// Verify that all other copies were updated, including the one retried after a conflict
+	for _, namespace := range namespaces {
+		targetSecret := &corev1.Secret{}
+		err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: "tls-example-io"}, targetSecret)
+
+		assert.NoError(t, err)
+		assert.Equal(t, namespace != "broken", string(targetSecret.Data[corev1.TLSCertKey]) == string(certificatePEM), namespace)
+	}
+
+	assert.Equal(t, 2, fakeClient.conflicts)
+
+	// Reconcile again as the requeue does, and check that only the failed copy is written again
+	fakeClient.updates = nil
+
+	_, err = reconciler.Reconcile(context.TODO(), request)
+
+	assert.Error(t, err)
+	assert.Equal(t, map[string]int{"broken": 1}, fakeClient.updates)
+}
@@ -0,0 +1,107 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"tls-secret-injector/pkg/certificate"
+	"tls-secret-injector/pkg/replica"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// isOutdated returns whether any of the copies was not written with its data yet
+func isOutdated(copies []targetCopy) bool {
+	for _, target := range copies {
+		if !replica.IsRecorded(target.metadata, target.data) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// copyNames returns the names of the copies
+func copyNames(copies []targetCopy) []types.NamespacedName {
+	names := make([]types.NamespacedName, 0, len(copies))
+	for _, target := range copies {
+		names = append(names, target.name)
+	}
+
+	return names
+}
+
+// preflight returns an error describing why the data of the source Secret must not be copied, checking that the
+// certificate matches its key, is valid now and covers all hosts of the Ingresses using the copies
+func (r *reconciler) preflight(ctx context.Context, sourceSecret *corev1.Secret, copies []targetCopy) (preflightErr error, err error) {
+	if sourceSecret.Type != corev1.SecretTypeTLS {
+		return
+	}
+
+	preflightErr = certificate.Verify(sourceSecret.Data[corev1.TLSCertKey], sourceSecret.Data[corev1.TLSPrivateKeyKey], time.Now())
+	if preflightErr != nil {
+		return
+	}
+
+	certificates, err := certificate.ParseCertificates(sourceSecret.Data[corev1.TLSCertKey])
+	if err != nil {
+		return
+	}
+
+	leaf := certificates[0]
+
+	for _, target := range copies {
+		var ingresses []networkingv1.Ingress
+
+		ingresses, err = r.lookup.IngressesUsing(ctx, target.name)
+		if err != nil {
+			return
+		}
+
+		for _, ingress := range ingresses {
+			for _, ingressTLS := range ingress.Spec.TLS {
+				if ingressTLS.SecretName != target.name.Name {
+					continue
+				}
+
+				for _, host := range ingressTLS.Hosts {
+					if leaf.VerifyHostname(host) != nil {
+						preflightErr = fmt.Errorf("certificate [%s] does not cover host %s of Ingress [%s/%s]", leaf.Subject.CommonName, host, ingress.Namespace, ingress.Name)
+						return
+					}
+				}
+			}
+		}
+	}
+
+	return
+}
+
+// isRejected returns whether the current data of the source Secret has been rejected
+func (r *reconciler) isRejected(sourceSecret *corev1.Secret) bool {
+	r.rejectedLock.Lock()
+	defer r.rejectedLock.Unlock()
+
+	sourceSecretName := types.NamespacedName{
+		Namespace: sourceSecret.Namespace,
+		Name:      sourceSecret.Name,
+	}
+
+	return r.rejected[sourceSecretName] == replica.DataHash(sourceSecret.Data)
+}
+
+// reject stops copying the current data of the source Secret until it changes again, reporting why on the Secret
+func (r *reconciler) reject(sourceSecret *corev1.Secret, reason string, message string) {
+	r.rejectedLock.Lock()
+	r.rejected[types.NamespacedName{Namespace: sourceSecret.Namespace, Name: sourceSecret.Name}] = replica.DataHash(sourceSecret.Data)
+	r.rejectedLock.Unlock()
+
+	log.Errorf("Rejected the data of Secret [%s/%s]: %s", sourceSecret.Namespace, sourceSecret.Name, message)
+
+	r.recorder.Event(sourceSecret, corev1.EventTypeWarning, reason, message)
+}
@@ -0,0 +1,132 @@
+package secret
+
+import (
+	"context"
+	"testing"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/index"
+	"tls-secret-injector/pkg/index/indexfake"
+	"tls-secret-injector/pkg/replica"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcilePreflight(t *testing.T) {
+	certificatePEM, privateKeyPEM := newTestCertificate(t)
+	_, otherPrivateKeyPEM := newTestCertificate(t)
+
+	tests := map[string]struct {
+		privateKey []byte
+		host       string
+		updated    bool
+	}{
+		"update copies with valid certificate": {
+			privateKey: privateKeyPEM,
+			host:       "example.io",
+			updated:    true,
+		},
+		"quarantine certificate not matching its key": {
+			privateKey: otherPrivateKeyPEM,
+			host:       "example.io",
+		},
+		"quarantine certificate not covering ingress host": {
+			privateKey: privateKeyPEM,
+			host:       "other.io",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			sourceSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "source",
+					Name:      "tls-example-io",
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					corev1.TLSCertKey:       certificatePEM,
+					corev1.TLSPrivateKeyKey: test.privateKey,
+				},
+			}
+
+			targetSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "target",
+					Name:      "tls-example-io",
+					Labels:    replica.Labels("tls-example-io"),
+				},
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					corev1.TLSCertKey:       []byte("outdated certificate"),
+					corev1.TLSPrivateKeyKey: []byte("outdated private key"),
+				},
+			}
+
+			ingress := &networkingv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "target",
+					Name:      "example-io",
+				},
+				Spec: networkingv1.IngressSpec{
+					TLS: []networkingv1.IngressTLS{
+						{Hosts: []string{test.host}, SecretName: "tls-example-io"},
+					},
+				},
+			}
+
+			// Create a client and the reconciler
+			fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(sourceSecret, targetSecret, ingress).Build(), index.Register)
+			recorder := record.NewFakeRecorder(10)
+			reconciler := newReconciler(fakeClient, fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), newWriter(fakeClient), replica.Types{corev1.SecretTypeTLS}, FanOut{}, nil, nil, recorder)
+
+			// Reconcile and check for errors
+			request := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: sourceSecret.Namespace,
+					Name:      sourceSecret.Name,
+				},
+			}
+
+			_, err := reconciler.Reconcile(context.TODO(), request)
+			assert.NoError(t, err)
+
+			// Verify whether the copy was updated, or kept and the source quarantined
+			updatedSecret := &corev1.Secret{}
+			err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: "target", Name: "tls-example-io"}, updatedSecret)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.updated, string(updatedSecret.Data[corev1.TLSCertKey]) == string(certificatePEM))
+
+			if test.updated {
+				assert.Empty(t, recorder.Events)
+				return
+			}
+
+			assert.Contains(t, <-recorder.Events, "Warning Quarantined")
+
+			// Reconcile again and verify that the quarantined data is not checked again
+			_, err = reconciler.Reconcile(context.TODO(), request)
+			assert.NoError(t, err)
+			assert.Empty(t, recorder.Events)
+
+			// Verify that the quarantine holds after a restart, and for the copies made by other controllers
+			reconciler = newReconciler(fakeClient, fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), newWriter(fakeClient), replica.Types{corev1.SecretTypeTLS}, FanOut{}, nil, nil, recorder)
+
+			_, err = reconciler.Reconcile(context.TODO(), request)
+			assert.NoError(t, err)
+			assert.Empty(t, recorder.Events)
+
+			written, err := newWriter(fakeClient).Create(context.TODO(), sourceSecret, types.NamespacedName{Namespace: "other", Name: "tls-example-io"}, nil)
+			assert.False(t, written)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// TargetNamespacesAnnotation lists comma separated glob patterns of the namespaces a source Secret is copied into
+	TargetNamespacesAnnotation = "tls-secret-injector/target-namespaces"
+	// TargetNamespaceSelectorAnnotation holds a label selector of the namespaces a source Secret is copied into
+	TargetNamespaceSelectorAnnotation = "tls-secret-injector/target-namespace-selector"
+)
+
+// isPushed returns whether the source Secret declares namespaces it is copied into
+func isPushed(sourceSecret *corev1.Secret) bool {
+	return sourceSecret.Annotations[TargetNamespacesAnnotation] != "" || sourceSecret.Annotations[TargetNamespaceSelectorAnnotation] != ""
+}
+
+// isTargetNamespace returns whether the source Secret declares to be copied into the namespace
+func isTargetNamespace(sourceSecret *corev1.Secret, namespace *corev1.Namespace) (bool, error) {
+	for _, pattern := range strings.Split(sourceSecret.Annotations[TargetNamespacesAnnotation], ",") {
+		if matched, _ := path.Match(strings.TrimSpace(pattern), namespace.Name); matched {
+			return true, nil
+		}
+	}
+
+	if sourceSecret.Annotations[TargetNamespaceSelectorAnnotation] == "" {
+		return false, nil
+	}
+
+	selector, err := labels.Parse(sourceSecret.Annotations[TargetNamespaceSelectorAnnotation])
+	if err != nil {
+		return false, fmt.Errorf("invalid target namespace selector on Secret [%s/%s]: %v", sourceSecret.Namespace, sourceSecret.Name, err)
+	}
+
+	return selector.Matches(labels.Set(namespace.Labels)), nil
+}
+
+// pushIndex holds the pushed source Secrets by their key, keeping only the annotations declaring their target
+// namespaces, so that namespaces are matched against them without reading the source
+type pushIndex struct {
+	sources map[types.NamespacedName]*corev1.Secret
+	lock    sync.RWMutex
+}
+
+func newPushIndex() *pushIndex {
+	return &pushIndex{
+		sources: map[types.NamespacedName]*corev1.Secret{},
+	}
+}
+
+// update records the target namespaces of the source Secret, or forgets it when it no longer declares any
+func (p *pushIndex) update(name types.NamespacedName, sourceSecret *corev1.Secret) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if sourceSecret == nil || !isPushed(sourceSecret) {
+		delete(p.sources, name)
+		return
+	}
+
+	p.sources[name] = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: sourceSecret.Namespace,
+			Name:      sourceSecret.Name,
+			Annotations: map[string]string{
+				TargetNamespacesAnnotation:        sourceSecret.Annotations[TargetNamespacesAnnotation],
+				TargetNamespaceSelectorAnnotation: sourceSecret.Annotations[TargetNamespaceSelectorAnnotation],
+			},
+		},
+	}
+}
+
+// sourcesFor returns the keys of the source Secrets declaring to be copied into the namespace
+func (p *pushIndex) sourcesFor(namespace *corev1.Namespace) []types.NamespacedName {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	var names []types.NamespacedName
+
+	for name, sourceSecret := range p.sources {
+		targeted, err := isTargetNamespace(sourceSecret, namespace)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		if !targeted {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// pushSecret creates the missing copies of the source Secret in the namespaces it declares through annotations, and
+// marks the existing ones as pushed
+func (r *reconciler) pushSecret(ctx context.Context, sourceSecret *corev1.Secret) error {
+	namespaceList := &corev1.NamespaceList{}
+
+	err := r.client.List(ctx, namespaceList)
+	if err != nil {
+		return fmt.Errorf("could not list namespaces: %v", err)
+	}
+
+	for i := range namespaceList.Items {
+		namespace := &namespaceList.Items[i]
+
+		if namespace.Status.Phase == corev1.NamespaceTerminating || r.source.IsSourceNamespace(ctx, namespace.Name) {
+			continue
+		}
+
+		targeted, err := isTargetNamespace(sourceSecret, namespace)
+		if err != nil {
+			return err
+		}
+		if !targeted {
+			continue
+		}
+
+		targetSecretName := types.NamespacedName{
+			Namespace: namespace.Name,
+			Name:      sourceSecret.Name,
+		}
+
+		// Existing Secrets are left to the conflict policy, while the existing copies are kept up to date along with all
+		// other copies of the source Secret, in waves when enabled
+		created, err := r.writer.Push(ctx, sourceSecret, targetSecretName)
+		if err != nil {
+			return fmt.Errorf("failed to create the target Secret [%s]: %v", targetSecretName, err)
+		}
+
+		if created {
+			log.Infof("Successfully created Secret [%s]", targetSecretName)
+		}
+	}
+
+	return nil
+}
@@ -3,43 +3,106 @@ package secret
 import (
 	"context"
 	"fmt"
+	"sync"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/history"
+	"tls-secret-injector/pkg/index"
+	"tls-secret-injector/pkg/replica"
 
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 type reconciler struct {
-	client          client.Client
-	sourceNamespace string
+	client   client.Client
+	reader   client.Reader
+	lookup   *index.Lookup
+	source   backend.SecretSource
+	writer   *replica.Writer
+	types    replica.Types
+	waves    *Waves
+	history  *history.History
+	recorder record.EventRecorder
+
+	// concurrency and limiter bound how many copies are updated at the same time, and how fast
+	concurrency int
+	limiter     *rate.Limiter
+
+	// pushed holds the source Secrets declaring the namespaces they are copied into
+	pushed *pushIndex
+
+	// rejected holds the data hash of the source Secrets whose data is not copied until it changes again
+	rejected     map[types.NamespacedName]string
+	rejectedLock sync.Mutex
 }
 
-func newReconciler(client client.Client, sourceNamespace string) *reconciler {
+func newReconciler(client client.Client, reader client.Reader, secretSource backend.SecretSource, writer *replica.Writer, secretTypes replica.Types, fanOut FanOut, waves *Waves, secretHistory *history.History, recorder record.EventRecorder) *reconciler {
 	return &reconciler{
-		client:          client,
-		sourceNamespace: sourceNamespace,
+		client:   client,
+		reader:   reader,
+		lookup:   index.NewLookup(client),
+		source:   secretSource,
+		writer:   writer,
+		types:    secretTypes,
+		waves:    waves,
+		history:  secretHistory,
+		recorder: recorder,
+		pushed:   newPushIndex(),
+		rejected: map[types.NamespacedName]string{},
+
+		concurrency: fanOut.concurrency(),
+		limiter:     fanOut.limiter(),
 	}
 }
 
 func (r *reconciler) Reconcile(ctx context.Context, request reconcile.Request) (result reconcile.Result, err error) {
 	log.Debugf("Received request to reconcile Secret [%s]", request.NamespacedName)
 
-	if request.Namespace != r.sourceNamespace {
-		log.Debugf("Skipping reconciliation of Secret [%s] as it is not from the source namespace", request.NamespacedName)
+	// Fetch the intermediates Secrets once for all copies
+	ctx = replica.WithIntermediatesCache(ctx)
+
+	// Secrets outside of the source are copied when Ingresses reference them through annotations
+	fromSource := r.source.IsSourceNamespace(ctx, request.Namespace)
+
+	// Find all Secrets that were created from this Secret, by name alone for the source as any source namespace may hold it
+	sourceSecretName := request.NamespacedName
+	if fromSource {
+		sourceSecretName.Namespace = ""
+	}
+
+	secretMetadataList, err := r.lookup.SecretCopiesOf(ctx, sourceSecretName)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	// Secrets outside of the source only matter when Ingresses copied them, which saves fetching all others
+	if !fromSource && len(secretMetadataList) == 0 {
+		log.Debugf("Skipping reconciliation of Secret [%s] as it has no copies", request.NamespacedName)
 		return
 	}
 
-	// Fetch the source Secret from cache
+	// Fetch the source Secret, either from the source or directly when Ingresses reference it through annotations
 	sourceSecret := &corev1.Secret{}
-
-	err = r.client.Get(ctx, request.NamespacedName, sourceSecret)
+	if fromSource {
+		sourceSecret, err = r.source.Get(ctx, request.Name)
+	} else {
+		err = r.client.Get(ctx, request.NamespacedName, sourceSecret)
+	}
 	if errors.IsNotFound(err) {
+		if fromSource {
+			r.pushed.update(request.NamespacedName, nil)
+		}
+
 		log.Debugf("Skipping reconciliation of Secret [%s] as it no longer exists: %v", request.NamespacedName, err)
 		return
 	}
@@ -49,63 +112,196 @@ func (r *reconciler) Reconcile(ctx context.Context, request reconcile.Request) (
 		return
 	}
 
-	// Skip if this Secret is not a TLS
-	if sourceSecret.Type != corev1.SecretTypeTLS {
-		log.Debugf("Skipping reconciliation of Secret [%s] as it is not a TLS Secret", request.NamespacedName)
+	// Skip if another source namespace takes precedence for this Secret
+	if fromSource && sourceSecret.Namespace != request.Namespace {
+		r.pushed.update(request.NamespacedName, nil)
+		log.Debugf("Skipping reconciliation of Secret [%s] as it is shadowed by Secret [%s/%s]", request.NamespacedName, sourceSecret.Namespace, sourceSecret.Name)
+		return
+	}
+
+	if fromSource {
+		r.pushed.update(request.NamespacedName, sourceSecret)
+	}
+
+	// Skip if Secrets of this type are not replicated, or if this one lacks the data its type requires
+	if !r.types.Allows(sourceSecret.Type) {
+		log.Debugf("Skipping reconciliation of Secret [%s] as its type %s is not replicated", request.NamespacedName, sourceSecret.Type)
+		return
+	}
+
+	if validationErr := replica.Validate(sourceSecret); validationErr != nil {
+		log.Warnf("Skipping reconciliation of Secret [%s] as it is invalid: %v", request.NamespacedName, validationErr)
+		r.recorder.Eventf(sourceSecret, corev1.EventTypeWarning, "InvalidSource", "Kept the copies as they are as %v", validationErr)
 		return
 	}
 
-	// Fetch all Secrets that were created from this Secret
-	secretLabels := client.MatchingLabels{
-		"app.kubernetes.io/name":          "tls-secret-injector",
-		"tls-secret-injector/source-name": request.Name,
+	// Skip if the data of this Secret has been rejected before, or quarantined before a restart
+	if r.isRejected(sourceSecret) {
+		log.Warnf("Skipping reconciliation of Secret [%s] as its data has been rejected", request.NamespacedName)
+		return
 	}
 
-	secretMetadataList := &metav1.PartialObjectMetadataList{}
-	secretMetadataList.SetGroupVersionKind(schema.GroupVersionKind{
-		Group:   "",
-		Version: "v1",
-		Kind:    "SecretList",
-	})
+	var quarantined bool
 
-	err = r.client.List(ctx, secretMetadataList, secretLabels)
+	quarantined, err = r.writer.IsQuarantined(ctx, sourceSecret)
 	if err != nil {
-		err = fmt.Errorf("could not list Secrets: %v", err)
 		log.Error(err)
 		return
 	}
+	if quarantined {
+		log.Warnf("Skipping reconciliation of Secret [%s] as its data is quarantined", request.NamespacedName)
+		return
+	}
 
 	// Iterate through the list of Secrets metadata
-	for _, targetSecretMetadata := range secretMetadataList.Items {
+	var copies []targetCopy
+
+	for i := range secretMetadataList {
+		targetSecretMetadata := &secretMetadataList[i]
 		targetSecretName := types.NamespacedName{
 			Namespace: targetSecretMetadata.ObjectMeta.Namespace,
 			Name:      targetSecretMetadata.ObjectMeta.Name,
 		}
 
+		// Skip the Secrets with the same name that were copied from elsewhere
+		if !r.isCopyOf(ctx, targetSecretMetadata, sourceSecret, fromSource) {
+			continue
+		}
+
+		// Skip the copies in namespaces whose policy no longer allows the Secret referenced through annotations
+		if !fromSource {
+			if sourceErr := r.writer.CheckSource(ctx, sourceSecret, targetSecretName.Namespace); sourceErr != nil {
+				log.Warnf("Skipping update of Secret [%s]: %v", targetSecretName, sourceErr)
+				r.recorder.Eventf(sourceSecret, corev1.EventTypeWarning, "SourceNotAllowed", "Skipped the update of Secret [%s] as %v", targetSecretName, sourceErr)
+				continue
+			}
+		}
+
+		// Skip the copies used by Ingresses in namespaces whose policy only copies the public certificates, as
+		// Ingresses need the private key
+		var usedByIngress bool
+
+		usedByIngress, err = r.isPublicIngressCopy(ctx, targetSecretName)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if usedByIngress {
+			log.Warnf("Skipping update of Secret [%s] as it is used by an Ingress, but its policy only copies public certificates", targetSecretName)
+			r.recorder.Eventf(sourceSecret, corev1.EventTypeWarning, "PublicIngressCopy", "Skipped the update of Secret [%s] as it is used by an Ingress, but its policy only copies public certificates", targetSecretName)
+			continue
+		}
+
 		log.Debugf("Found target Secret [%s] to be copied from source Secret [%s]", targetSecretName, request.NamespacedName)
 
-		// Fetch the target Secret
-		targetSecret := &corev1.Secret{}
+		var data map[string][]byte
 
-		err = r.client.Get(ctx, targetSecretName, targetSecret)
+		data, err = r.writer.Data(ctx, sourceSecret, targetSecretName.Namespace)
 		if err != nil {
-			err = fmt.Errorf("could not fetch the target Secret [%s]: %v", request.NamespacedName, err)
+			err = fmt.Errorf("could not resolve the data of the target Secret [%s]: %v", targetSecretName, err)
 			log.Error(err)
 			return
 		}
 
-		// Copy Secret data from source to target
-		targetSecret.Data = sourceSecret.Data
+		copies = append(copies, targetCopy{
+			name:     targetSecretName,
+			metadata: targetSecretMetadata,
+			data:     data,
+		})
+	}
 
-		err = r.client.Update(ctx, targetSecret)
+	// Quarantine new data failing the pre-flight checks, keeping the copies as they are
+	if isOutdated(copies) || fromSource && isPushed(sourceSecret) {
+		var preflightErr error
+
+		preflightErr, err = r.preflight(ctx, sourceSecret, copies)
 		if err != nil {
-			err = fmt.Errorf("failed to update target Secret [%s]: %v", targetSecretName, err)
 			log.Error(err)
 			return
 		}
+		if preflightErr != nil {
+			r.reject(sourceSecret, "Quarantined", fmt.Sprintf("Quarantined the new data, keeping the copies as they are, as %v", preflightErr))
 
-		log.Infof("Successfully updated Secret [%s]", targetSecretName)
+			err = r.writer.Quarantine(ctx, sourceSecret, copyNames(copies))
+			if err != nil {
+				log.Error(err)
+			}
+			return
+		}
+	}
+
+	// Keep the data that passed the checks in the history, so the copies can be rolled back to it
+	if fromSource && r.history != nil {
+		err = r.history.Record(ctx, sourceSecret)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+	}
+
+	// Create the copies in the namespaces the source Secret declares through annotations
+	if fromSource && isPushed(sourceSecret) {
+		err = r.pushSecret(ctx, sourceSecret)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+	}
+
+	// Update the copies all at once, or in waves leaving the ConfigMaps until all copies are rotated
+	if r.waves == nil {
+		err = r.updateSecrets(ctx, sourceSecret, copies)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+	} else {
+		var completed bool
+
+		result, completed, err = r.rollOut(ctx, sourceSecret, copies)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if !completed {
+			return
+		}
+	}
+
+	// Update the ConfigMaps holding the public certificates of this Secret
+	err = r.updateConfigMaps(ctx, sourceSecret, fromSource)
+	if err != nil {
+		log.Error(err)
+		return
 	}
 
 	return
 }
+
+// isPublicIngressCopy returns whether the copy is used by an Ingress while the policy of its namespace only copies the
+// public certificates
+func (r *reconciler) isPublicIngressCopy(ctx context.Context, targetSecretName types.NamespacedName) (bool, error) {
+	public, err := r.writer.IsPublic(ctx, targetSecretName.Namespace)
+	if err != nil || !public {
+		return false, err
+	}
+
+	ingresses, err := r.lookup.IngressesUsing(ctx, targetSecretName)
+	if err != nil {
+		return false, err
+	}
+
+	return len(ingresses) > 0, nil
+}
+
+// isCopyOf returns whether the target Secret was copied from the source Secret
+func (r *reconciler) isCopyOf(ctx context.Context, target metav1.Object, sourceSecret *corev1.Secret, fromSource bool) bool {
+	sourceNamespace, ok := target.GetAnnotations()[replica.SourceNamespaceAnnotation]
+
+	// Copies made before their provenance was recorded can only come from the source
+	if fromSource {
+		return !ok || r.source.IsSourceNamespace(ctx, sourceNamespace)
+	}
+
+	return ok && sourceNamespace == sourceSecret.Namespace
+}
@@ -4,28 +4,41 @@ import (
 	"context"
 	"testing"
 
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/index"
+	"tls-secret-injector/pkg/index/indexfake"
+	"tls-secret-injector/pkg/policy"
+	"tls-secret-injector/pkg/replica"
+
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 func TestReconcile(t *testing.T) {
+	certificatePEM, privateKeyPEM := newTestCertificate(t)
+
 	sourceSecret := &corev1.Secret{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "v1",
 			Kind:       "Secret",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Namespace: "source",
-			Name:      "tls-example-io",
+			Namespace:       "source",
+			Name:            "tls-example-io",
+			UID:             "source-uid",
+			ResourceVersion: "7",
 		},
 		Type: corev1.SecretTypeTLS,
 		Data: map[string][]byte{
-			corev1.TLSCertKey:       []byte("certificate"),
-			corev1.TLSPrivateKeyKey: []byte("private key"),
+			corev1.TLSCertKey:       certificatePEM,
+			corev1.TLSPrivateKeyKey: privateKeyPEM,
 		},
 	}
 
@@ -65,8 +78,8 @@ func TestReconcile(t *testing.T) {
 	}
 
 	// Create a client and the reconciler
-	fakeClient := fake.NewClientBuilder().WithObjects(sourceSecret, targetSecret).Build()
-	reconciler := newReconciler(fakeClient, sourceSecret.ObjectMeta.Namespace)
+	fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(sourceSecret, targetSecret).Build(), index.Register)
+	reconciler := newReconciler(fakeClient, fakeClient, backend.NewKubernetes(fakeClient, nil, []string{sourceSecret.ObjectMeta.Namespace}, nil), newWriter(fakeClient), replica.Types{corev1.SecretTypeTLS}, FanOut{}, nil, nil, record.NewFakeRecorder(10))
 
 	// Reconcile and check for errors
 	_, err := reconciler.Reconcile(context.TODO(), request)
@@ -78,6 +91,458 @@ func TestReconcile(t *testing.T) {
 
 	assert.NoError(t, err)
 	assert.Equal(t, "2", updatedSecret.ResourceVersion)
-	assert.Equal(t, "certificate", string(updatedSecret.Data[corev1.TLSCertKey]))
-	assert.Equal(t, "private key", string(updatedSecret.Data[corev1.TLSPrivateKeyKey]))
+	assert.Equal(t, certificatePEM, updatedSecret.Data[corev1.TLSCertKey])
+	assert.Equal(t, privateKeyPEM, updatedSecret.Data[corev1.TLSPrivateKeyKey])
+	assert.Equal(t, "source", updatedSecret.Annotations[replica.SourceNamespaceAnnotation])
+	assert.Equal(t, "source-uid", updatedSecret.Annotations[replica.SourceUIDAnnotation])
+	assert.Equal(t, "7", updatedSecret.Annotations[replica.SourceResourceVersionAnnotation])
+	assert.Equal(t, replica.DataHash(sourceSecret.Data), updatedSecret.Annotations[replica.DataHashAnnotation])
+	assert.NotEmpty(t, updatedSecret.Annotations[replica.LastSyncAnnotation])
+
+	// Reconcile again and verify that the unchanged Secret is not updated
+	_, err = reconciler.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+
+	err = fakeClient.Get(context.TODO(), targetSecretName, updatedSecret)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "2", updatedSecret.ResourceVersion)
+
+	// Change the data of the copy and verify that it is repaired, even though its metadata is unchanged
+	updatedSecret.Data[corev1.TLSCertKey] = []byte("changed certificate")
+	assert.NoError(t, fakeClient.Update(context.TODO(), updatedSecret))
+
+	_, err = reconciler.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+
+	err = fakeClient.Get(context.TODO(), targetSecretName, updatedSecret)
+
+	assert.NoError(t, err)
+	assert.Equal(t, certificatePEM, updatedSecret.Data[corev1.TLSCertKey])
+}
+
+func TestReconcileReferencedSecret(t *testing.T) {
+	certificatePEM, privateKeyPEM := newTestCertificate(t)
+
+	referencedSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "team",
+			Name:      "tls-team-io",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certificatePEM,
+			corev1.TLSPrivateKeyKey: privateKeyPEM,
+		},
+	}
+
+	newTargetSecret := func(namespace, sourceNamespace string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      "tls-example-io",
+				Labels:    replica.Labels(referencedSecret.Name),
+				Annotations: map[string]string{
+					replica.SourceNamespaceAnnotation: sourceNamespace,
+				},
+			},
+			Type: corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       []byte("outdated certificate"),
+				corev1.TLSPrivateKeyKey: []byte("outdated private key"),
+			},
+		}
+	}
+
+	// Create a client and the reconciler
+	fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(
+		referencedSecret,
+		newTargetSecret("target", "team"),
+		newTargetSecret("other-target", "other-team"),
+		newTargetSecret("revoked", "team"),
+	).Build(), index.Register)
+
+	// Only the policy of the target namespace still allows copying from the referenced Secret
+	policies := policy.NewResolver(fakeClient, []policy.Policy{{Name: "teams", Namespaces: []string{"target"}, AllowedSources: []string{"team/*"}}}, policy.Policy{Conflict: policy.ConflictSkip})
+	secretSource := backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil)
+	writer := replica.NewWriter(fakeClient, secretSource, policies, record.NewFakeRecorder(10))
+	reconciler := newReconciler(fakeClient, fakeClient, secretSource, writer, replica.Types{corev1.SecretTypeTLS}, FanOut{}, nil, nil, record.NewFakeRecorder(10))
+
+	// Reconcile and check for errors
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: referencedSecret.Namespace,
+			Name:      referencedSecret.Name,
+		},
+	}
+
+	_, err := reconciler.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+
+	// Verify that only the copy of the referenced Secret was updated
+	updatedSecret := &corev1.Secret{}
+	err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: "target", Name: "tls-example-io"}, updatedSecret)
+
+	assert.NoError(t, err)
+	assert.Equal(t, certificatePEM, updatedSecret.Data[corev1.TLSCertKey])
+
+	otherSecret := &corev1.Secret{}
+	err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: "other-target", Name: "tls-example-io"}, otherSecret)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "outdated certificate", string(otherSecret.Data[corev1.TLSCertKey]))
+
+	revokedSecret := &corev1.Secret{}
+	err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: "revoked", Name: "tls-example-io"}, revokedSecret)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "outdated certificate", string(revokedSecret.Data[corev1.TLSCertKey]))
+}
+
+func newWriter(fakeClient client.Client) *replica.Writer {
+	policies := policy.NewResolver(fakeClient, nil, policy.Policy{Conflict: policy.ConflictSkip})
+
+	return replica.NewWriter(fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), policies, record.NewFakeRecorder(10))
+}
+
+func TestReconcilePushedSecret(t *testing.T) {
+	certificatePEM, privateKeyPEM := newTestCertificate(t)
+
+	sourceSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "tls-example-io",
+			Annotations: map[string]string{
+				TargetNamespacesAnnotation:        "grpc-*, payments",
+				TargetNamespaceSelectorAnnotation: "database=postgres",
+			},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certificatePEM,
+			corev1.TLSPrivateKeyKey: privateKeyPEM,
+		},
+	}
+
+	newNamespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: labels,
+			},
+		}
+	}
+
+	// Create a client and the reconciler
+	fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(
+		sourceSecret,
+		newNamespace("source", nil),
+		newNamespace("grpc-orders", nil),
+		newNamespace("payments", nil),
+		newNamespace("database", map[string]string{"database": "postgres"}),
+		newNamespace("other", nil),
+	).Build(), index.Register)
+	reconciler := newReconciler(fakeClient, fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), newWriter(fakeClient), replica.Types{corev1.SecretTypeTLS}, FanOut{}, nil, nil, record.NewFakeRecorder(10))
+
+	// Reconcile and check for errors
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: sourceSecret.Namespace,
+			Name:      sourceSecret.Name,
+		},
+	}
+
+	_, err := reconciler.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+
+	// Verify that the copies were only created in the target namespaces
+	for namespace, copied := range map[string]bool{"grpc-orders": true, "payments": true, "database": true, "other": false} {
+		targetSecret := &corev1.Secret{}
+		err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: sourceSecret.Name}, targetSecret)
+
+		if copied {
+			assert.NoError(t, err, namespace)
+			assert.Equal(t, certificatePEM, targetSecret.Data[corev1.TLSCertKey], namespace)
+			assert.True(t, replica.IsPushed(targetSecret), namespace)
+		} else {
+			assert.True(t, errors.IsNotFound(err), namespace)
+		}
+	}
+
+	// Verify that namespaces labelled later are mapped to the source Secret without reading it again
+	assert.Equal(t, []reconcile.Request{request}, mapNamespaceToSourceSecrets(reconciler.pushed, newNamespace("relabelled", map[string]string{"database": "postgres"})))
+	assert.Empty(t, mapNamespaceToSourceSecrets(reconciler.pushed, newNamespace("other", nil)))
+}
+
+func TestReconcilePushedSecretConflict(t *testing.T) {
+	certificatePEM, privateKeyPEM := newTestCertificate(t)
+
+	sourceSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "source",
+			Name:        "tls-example-io",
+			Annotations: map[string]string{TargetNamespacesAnnotation: "payments"},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certificatePEM,
+			corev1.TLSPrivateKeyKey: privateKeyPEM,
+		},
+	}
+
+	existingSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "payments",
+			Name:      "tls-example-io",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       []byte("hand-copied certificate"),
+			corev1.TLSPrivateKeyKey: []byte("hand-copied private key"),
+		},
+	}
+
+	// Create a client and the reconciler adopting the existing Secrets
+	fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(sourceSecret, existingSecret, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments"}}).Build(), index.Register)
+	policies := policy.NewResolver(fakeClient, nil, policy.Policy{Conflict: policy.ConflictAdopt})
+	secretSource := backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil)
+	writer := replica.NewWriter(fakeClient, secretSource, policies, record.NewFakeRecorder(10))
+	reconciler := newReconciler(fakeClient, fakeClient, secretSource, writer, replica.Types{corev1.SecretTypeTLS}, FanOut{}, nil, nil, record.NewFakeRecorder(10))
+
+	_, err := reconciler.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "source", Name: "tls-example-io"}})
+	assert.NoError(t, err)
+
+	// Verify that the conflict policy applied to the existing Secret
+	targetSecret := &corev1.Secret{}
+	assert.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: "payments", Name: "tls-example-io"}, targetSecret))
+	assert.True(t, replica.IsManaged(targetSecret))
+	assert.True(t, replica.IsPushed(targetSecret))
+	assert.Equal(t, certificatePEM, targetSecret.Data[corev1.TLSCertKey])
+}
+
+func TestMapResyncRequestToSourceSecret(t *testing.T) {
+	sourceSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "tls-example-io",
+		},
+	}
+
+	tests := map[string]struct {
+		annotations      map[string]string
+		expectedRequests []reconcile.Request
+	}{
+		"recorded source namespace": {
+			annotations:      map[string]string{replica.SourceNamespaceAnnotation: "other"},
+			expectedRequests: []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "other", Name: "tls-example-io"}}},
+		},
+		"copy made before its provenance was recorded": {
+			expectedRequests: []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "source", Name: "tls-example-io"}}},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(sourceSecret).Build(), index.Register)
+			secretSource := backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil)
+
+			copySecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:   "copied",
+					Name:        "tls-example-io",
+					Labels:      replica.Labels("tls-example-io"),
+					Annotations: test.annotations,
+				},
+			}
+
+			assert.Equal(t, test.expectedRequests, mapResyncRequestToSourceSecret(secretSource, copySecret))
+		})
+	}
+}
+
+func TestReconcileSecretTypes(t *testing.T) {
+	sourceSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "registry-example-io",
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(`{"auths":{"registry.example.io":{"auth":"dXNlcjpwYXNz"}}}`),
+		},
+	}
+
+	tests := map[string]struct {
+		secretTypes replica.Types
+		updated     bool
+	}{
+		"update copy of replicated type": {
+			secretTypes: replica.Types{corev1.SecretTypeTLS, corev1.SecretTypeDockerConfigJson},
+			updated:     true,
+		},
+		"skip copy of other type": {
+			secretTypes: replica.Types{corev1.SecretTypeTLS},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			targetSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "target",
+					Name:      sourceSecret.Name,
+					Labels:    replica.Labels(sourceSecret.Name),
+				},
+				Type: corev1.SecretTypeDockerConfigJson,
+				Data: map[string][]byte{
+					corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`),
+				},
+			}
+
+			// Create a client and the reconciler
+			fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(sourceSecret, targetSecret).Build(), index.Register)
+			reconciler := newReconciler(fakeClient, fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), newWriter(fakeClient), test.secretTypes, FanOut{}, nil, nil, record.NewFakeRecorder(10))
+
+			// Reconcile and check for errors
+			request := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: sourceSecret.Namespace,
+					Name:      sourceSecret.Name,
+				},
+			}
+
+			_, err := reconciler.Reconcile(context.TODO(), request)
+			assert.NoError(t, err)
+
+			// Verify whether the copy was updated
+			updatedSecret := &corev1.Secret{}
+			err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: "target", Name: sourceSecret.Name}, updatedSecret)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.updated, string(updatedSecret.Data[corev1.DockerConfigJsonKey]) == string(sourceSecret.Data[corev1.DockerConfigJsonKey]))
+		})
+	}
+}
+
+func TestReconcileKeyMapping(t *testing.T) {
+	certificatePEM, privateKeyPEM := newTestCertificate(t)
+
+	sourceSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "tls-example-io",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certificatePEM,
+			corev1.TLSPrivateKeyKey: privateKeyPEM,
+			"ca.crt":                []byte("ca certificate"),
+		},
+	}
+
+	targetSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "legacy",
+			Name:      "tls-example-io",
+			Labels:    replica.Labels(sourceSecret.Name),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"cert.pem": []byte("outdated certificate"),
+			"key.pem":  []byte("outdated private key"),
+		},
+	}
+
+	// Create a client and the reconciler with a policy renaming the keys in the legacy namespace
+	fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(sourceSecret, targetSecret).Build(), index.Register)
+	policies := policy.NewResolver(fakeClient, []policy.Policy{
+		{
+			Name:       "legacy",
+			Namespaces: []string{"legacy"},
+			Keys: &policy.KeyMapping{
+				Exclude: []string{"ca.crt"},
+				Rename:  map[string]string{corev1.TLSCertKey: "cert.pem", corev1.TLSPrivateKeyKey: "key.pem"},
+			},
+		},
+	}, policy.Policy{Conflict: policy.ConflictSkip})
+	secretSource := backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil)
+	writer := replica.NewWriter(fakeClient, secretSource, policies, record.NewFakeRecorder(10))
+
+	reconciler := newReconciler(fakeClient, fakeClient, secretSource, writer, replica.Types{corev1.SecretTypeTLS}, FanOut{}, nil, nil, record.NewFakeRecorder(10))
+
+	// Reconcile and check for errors
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: sourceSecret.Namespace,
+			Name:      sourceSecret.Name,
+		},
+	}
+
+	_, err := reconciler.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+
+	// Verify that the copy was updated under the renamed keys only
+	updatedSecret := &corev1.Secret{}
+	err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: "legacy", Name: "tls-example-io"}, updatedSecret)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"cert.pem": certificatePEM,
+		"key.pem":  privateKeyPEM,
+	}, updatedSecret.Data)
+}
+
+func TestReconcileConfigMap(t *testing.T) {
+	certificatePEM, privateKeyPEM := newTestCertificate(t)
+
+	sourceSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "tls-example-io",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certificatePEM,
+			corev1.TLSPrivateKeyKey: privateKeyPEM,
+		},
+	}
+
+	targetConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "clients",
+			Name:      "tls-example-io",
+			Labels:    replica.Labels(sourceSecret.Name),
+		},
+		Data: map[string]string{
+			corev1.TLSCertKey: "outdated certificate",
+		},
+	}
+
+	// Create a client and the reconciler with a policy writing ConfigMaps into the clients namespace
+	fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(sourceSecret, targetConfigMap).Build(), index.Register)
+	policies := policy.NewResolver(fakeClient, []policy.Policy{
+		{Name: "clients", Namespaces: []string{"clients"}, Public: policy.PublicConfigMap},
+	}, policy.Policy{Conflict: policy.ConflictSkip})
+	secretSource := backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil)
+	writer := replica.NewWriter(fakeClient, secretSource, policies, record.NewFakeRecorder(10))
+	reconciler := newReconciler(fakeClient, fakeClient, secretSource, writer, replica.Types{corev1.SecretTypeTLS}, FanOut{}, nil, nil, record.NewFakeRecorder(10))
+
+	// Reconcile and check for errors
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Namespace: sourceSecret.Namespace,
+			Name:      sourceSecret.Name,
+		},
+	}
+
+	_, err := reconciler.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+
+	// Verify that the ConfigMap was updated with the public certificate only
+	updatedConfigMap := &corev1.ConfigMap{}
+	err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: "clients", Name: "tls-example-io"}, updatedConfigMap)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{corev1.TLSCertKey: string(certificatePEM)}, updatedConfigMap.Data)
+	assert.Equal(t, replica.DataHash(map[string][]byte{corev1.TLSCertKey: certificatePEM}), updatedConfigMap.Annotations[replica.DataHashAnnotation])
 }
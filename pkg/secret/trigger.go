@@ -0,0 +1,37 @@
+package secret
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// Trigger enqueues source Secrets into the Secret controller, so their copies are checked and updated again
+type Trigger struct {
+	events chan event.GenericEvent
+}
+
+// NewTrigger returns a pointer to Trigger
+func NewTrigger() *Trigger {
+	return &Trigger{
+		events: make(chan event.GenericEvent),
+	}
+}
+
+// Resync enqueues the source Secret with the given name, waiting until the controller takes it or the context is done
+func (t *Trigger) Resync(ctx context.Context, name types.NamespacedName) {
+	changedSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: name.Namespace,
+			Name:      name.Name,
+		},
+	}
+
+	select {
+	case t.events <- event.GenericEvent{Object: changedSecret}:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,269 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"tls-secret-injector/pkg/replica"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Waves defines how the copies of a rotated source Secret are updated, canary namespaces first and then the rest
+type Waves struct {
+	// CanarySelector selects the namespaces whose copies are updated before all others
+	CanarySelector labels.Selector
+	// BatchSize is the number of copies updated at once, or 0 to update all copies of a wave at once
+	BatchSize int
+	// Pause is the time waited after each batch before the next one is updated
+	Pause time.Duration
+}
+
+// batch returns the copies to update in the next batch
+func (w *Waves) batch(copies []targetCopy) []targetCopy {
+	if w.BatchSize <= 0 || w.BatchSize >= len(copies) {
+		return copies
+	}
+
+	return copies[:w.BatchSize]
+}
+
+// targetCopy holds the metadata of a copy and the data it should hold
+type targetCopy struct {
+	name     types.NamespacedName
+	metadata client.Object
+	data     map[string][]byte
+}
+
+// rollOut updates the next batch of outdated copies, and returns whether all copies are up to date. The copies already
+// written with the data are repaired at once when they were changed since, as they do not take part in the rotation.
+// The state of the rollout is kept on the copies, so it holds whatever triggers the reconciliation and across restarts.
+func (r *reconciler) rollOut(ctx context.Context, sourceSecret *corev1.Secret, copies []targetCopy) (result reconcile.Result, completed bool, err error) {
+	// Split the copies into the canary and the other waves
+	var current, canaries, outdatedCanaries, outdated []targetCopy
+
+	// The last batch was written when the most recent copy holding its data was synced
+	var halted bool
+	var lastBatch time.Time
+
+	canaryNamespaces := map[string]bool{}
+	for _, target := range copies {
+		canary, found := canaryNamespaces[target.name.Namespace]
+		if !found {
+			canary, err = r.isCanaryNamespace(ctx, target.name.Namespace)
+			if err != nil {
+				return
+			}
+
+			canaryNamespaces[target.name.Namespace] = canary
+		}
+
+		upToDate := replica.IsRecorded(target.metadata, target.data)
+		if upToDate {
+			current = append(current, target)
+
+			if target.metadata.GetAnnotations()[replica.RolloutHaltedAnnotation] == replica.DataHash(target.data) {
+				halted = true
+			}
+
+			var lastSync time.Time
+
+			lastSync, err = parseLastSync(target)
+			if err != nil {
+				return
+			}
+			if lastSync.After(lastBatch) {
+				lastBatch = lastSync
+			}
+		}
+
+		switch {
+		case canary && upToDate:
+			canaries = append(canaries, target)
+		case canary:
+			outdatedCanaries = append(outdatedCanaries, target)
+		case !upToDate:
+			outdated = append(outdated, target)
+		}
+	}
+
+	err = r.updateSecrets(ctx, sourceSecret, current)
+	if err != nil {
+		return
+	}
+
+	if len(outdatedCanaries) == 0 && len(outdated) == 0 {
+		completed = true
+		return
+	}
+
+	if halted {
+		log.Warnf("Skipping the rollout of Secret [%s/%s] as it was halted for its data", sourceSecret.Namespace, sourceSecret.Name)
+		return
+	}
+
+	// Wait for the pause after the last batch, also when other events trigger the reconciliation
+	wait := time.Until(lastBatch.Add(r.waves.Pause))
+	if wait > 0 {
+		result = reconcile.Result{RequeueAfter: wait}
+		return
+	}
+
+	// Update the canaries first, and give them time to report errors before the other copies follow
+	if len(outdatedCanaries) > 0 {
+		err = r.updateSecrets(ctx, sourceSecret, r.waves.batch(outdatedCanaries))
+		if err != nil {
+			return
+		}
+
+		result = reconcile.Result{Requeue: true, RequeueAfter: r.waves.Pause}
+		return
+	}
+
+	failedCanary, ingressErr, err := r.checkCanaryIngresses(ctx, canaries)
+	if err != nil {
+		return
+	}
+	if ingressErr != nil {
+		err = r.halt(ctx, sourceSecret, canaries, failedCanary, ingressErr)
+		return
+	}
+
+	batch := r.waves.batch(outdated)
+
+	err = r.updateSecrets(ctx, sourceSecret, batch)
+	if err != nil {
+		return
+	}
+
+	if len(batch) < len(outdated) {
+		result = reconcile.Result{Requeue: true, RequeueAfter: r.waves.Pause}
+		return
+	}
+
+	completed = true
+	return
+}
+
+// isCanaryNamespace returns whether the copies in the namespace are updated in the canary wave
+func (r *reconciler) isCanaryNamespace(ctx context.Context, namespaceName string) (bool, error) {
+	if r.waves.CanarySelector == nil {
+		return false, nil
+	}
+
+	namespace := &corev1.Namespace{}
+
+	err := r.client.Get(ctx, types.NamespacedName{Name: namespaceName}, namespace)
+	if err != nil {
+		return false, fmt.Errorf("could not fetch namespace [%s]: %v", namespaceName, err)
+	}
+
+	return r.waves.CanarySelector.Matches(labels.Set(namespace.Labels)), nil
+}
+
+// halt stops the rollout of the data of the source Secret by marking the canary copies, and reports it on the source
+// Secret and on the canary copy whose Ingress reported errors, which also exists for sources outside of Kubernetes
+func (r *reconciler) halt(ctx context.Context, sourceSecret *corev1.Secret, canaries []targetCopy, failedCanary targetCopy, ingressErr error) error {
+	message := fmt.Sprintf("Halted the rollout to the copies as a canary Ingress reports errors: %v", ingressErr)
+
+	r.reject(sourceSecret, "RolloutHalted", message)
+
+	for _, canary := range canaries {
+		canarySecret := &corev1.Secret{}
+
+		err := r.client.Get(ctx, canary.name, canarySecret)
+		if err != nil {
+			return fmt.Errorf("could not fetch the canary Secret [%s]: %v", canary.name, err)
+		}
+
+		if canarySecret.Annotations == nil {
+			canarySecret.Annotations = map[string]string{}
+		}
+		canarySecret.Annotations[replica.RolloutHaltedAnnotation] = replica.DataHash(canary.data)
+
+		err = r.client.Update(ctx, canarySecret)
+		if err != nil {
+			return fmt.Errorf("could not mark the rollout as halted on the canary Secret [%s]: %v", canary.name, err)
+		}
+
+		if canary.name == failedCanary.name {
+			r.recorder.Eventf(canarySecret, corev1.EventTypeWarning, "RolloutHalted", "%s of Secret [%s/%s]", message, sourceSecret.Namespace, sourceSecret.Name)
+		}
+	}
+
+	return nil
+}
+
+// parseLastSync returns the time at which the data of the copy was last written
+func parseLastSync(target targetCopy) (time.Time, error) {
+	lastSync, err := time.Parse(time.RFC3339, target.metadata.GetAnnotations()[replica.LastSyncAnnotation])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not parse the last sync time of the Secret [%s]: %v", target.name, err)
+	}
+
+	return lastSync, nil
+}
+
+// checkCanaryIngresses returns an error describing the first Warning Event reported for an Ingress using one of the
+// canary copies since that copy was updated, along with that copy
+func (r *reconciler) checkCanaryIngresses(ctx context.Context, canaries []targetCopy) (failedCanary targetCopy, ingressErr error, err error) {
+	for _, canary := range canaries {
+		var lastSync time.Time
+
+		lastSync, err = parseLastSync(canary)
+		if err != nil {
+			return
+		}
+
+		// Find the Ingresses using the canary copy
+		var ingresses []networkingv1.Ingress
+
+		ingresses, err = r.lookup.IngressesUsing(ctx, canary.name)
+		if err != nil {
+			return
+		}
+
+		// Look for the errors their controller reported since the canary copy was updated, only listing the Events
+		// of these Ingresses
+		for _, ingress := range ingresses {
+			eventList := &corev1.EventList{}
+
+			err = r.reader.List(ctx, eventList, client.InNamespace(canary.name.Namespace), client.MatchingFieldsSelector{
+				Selector: fields.SelectorFromSet(fields.Set{
+					"involvedObject.kind": "Ingress",
+					"involvedObject.name": ingress.Name,
+					"type":                corev1.EventTypeWarning,
+				}),
+			})
+			if err != nil {
+				err = fmt.Errorf("could not list the Events of Ingress [%s/%s]: %v", ingress.Namespace, ingress.Name, err)
+				return
+			}
+
+			for _, event := range eventList.Items {
+				timestamp := event.LastTimestamp.Time
+				if timestamp.IsZero() {
+					timestamp = event.EventTime.Time
+				}
+
+				if timestamp.Before(lastSync) {
+					continue
+				}
+
+				failedCanary = canary
+				ingressErr = fmt.Errorf("Ingress [%s/%s] reported %s: %s", event.Namespace, event.InvolvedObject.Name, event.Reason, event.Message)
+				return
+			}
+		}
+	}
+
+	return
+}
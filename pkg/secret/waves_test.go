@@ -0,0 +1,304 @@
+package secret
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/index"
+	"tls-secret-injector/pkg/index/indexfake"
+	"tls-secret-injector/pkg/replica"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileWaves(t *testing.T) {
+	certificatePEM, privateKeyPEM := newTestCertificate(t)
+
+	sourceSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "tls-example-io",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certificatePEM,
+			corev1.TLSPrivateKeyKey: privateKeyPEM,
+		},
+	}
+
+	newNamespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: labels,
+			},
+		}
+	}
+
+	newTargetSecret := func(namespace string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      "tls-example-io",
+				Labels:    replica.Labels("tls-example-io"),
+			},
+			Type: corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       []byte("outdated certificate"),
+				corev1.TLSPrivateKeyKey: []byte("outdated private key"),
+			},
+		}
+	}
+
+	canaryIngress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "canary",
+			Name:      "example-io",
+		},
+		Spec: networkingv1.IngressSpec{
+			TLS: []networkingv1.IngressTLS{
+				{Hosts: []string{"example.io"}, SecretName: "tls-example-io"},
+			},
+		},
+	}
+
+	canaryWarning := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "canary",
+			Name:      "example-io.1",
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:      "Ingress",
+			Namespace: "canary",
+			Name:      "example-io",
+		},
+		Type:          corev1.EventTypeWarning,
+		Reason:        "TLSHandshakeFailed",
+		LastTimestamp: metav1.NewTime(time.Now().Add(time.Minute)),
+	}
+
+	tests := map[string]struct {
+		// warning is reported for the canary Ingress after the first wave
+		warning *corev1.Event
+		// waves lists the namespaces whose copies are updated by each reconciliation
+		waves [][]string
+		// requeues is the number of reconciliations coming back for the next wave
+		requeues int
+		event    string
+	}{
+		"update canaries first and the rest in batches": {
+			waves:    [][]string{{"canary"}, {"first"}, {"second"}},
+			requeues: 2,
+		},
+		"halt on errors of canary ingresses": {
+			warning:  canaryWarning,
+			waves:    [][]string{{"canary"}, {}, {}},
+			requeues: 1,
+			event:    "Warning RolloutHalted",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Create a client and the reconciler updating the canary namespace first, then one namespace at a time
+			fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(
+				sourceSecret,
+				canaryIngress,
+				newNamespace("canary", map[string]string{"stage": "canary"}),
+				newNamespace("first", nil),
+				newNamespace("second", nil),
+				newTargetSecret("canary"),
+				newTargetSecret("first"),
+				newTargetSecret("second"),
+			).Build(), index.Register)
+			recorder := record.NewFakeRecorder(10)
+			waves := &Waves{
+				CanarySelector: labels.SelectorFromSet(labels.Set{"stage": "canary"}),
+				BatchSize:      1,
+				Pause:          time.Minute,
+			}
+			reconciler := newReconciler(fakeClient, fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), newWriter(fakeClient), replica.Types{corev1.SecretTypeTLS}, FanOut{}, waves, nil, recorder)
+
+			request := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: sourceSecret.Namespace,
+					Name:      sourceSecret.Name,
+				},
+			}
+
+			updated := map[string]bool{}
+
+			for i, wave := range test.waves {
+				if i == 1 && test.warning != nil {
+					assert.NoError(t, fakeClient.Create(context.TODO(), test.warning))
+				}
+				if i > 0 {
+					elapsePause(t, fakeClient, waves.Pause)
+				}
+
+				// Reconcile and check that only the copies of this wave were updated
+				result, err := reconciler.Reconcile(context.TODO(), request)
+				assert.NoError(t, err)
+
+				for _, namespace := range wave {
+					updated[namespace] = true
+				}
+
+				for _, namespace := range []string{"canary", "first", "second"} {
+					targetSecret := &corev1.Secret{}
+					err = fakeClient.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: "tls-example-io"}, targetSecret)
+
+					assert.NoError(t, err)
+					assert.Equal(t, updated[namespace], string(targetSecret.Data[corev1.TLSCertKey]) == string(certificatePEM), namespace)
+				}
+
+				// Check that the reconciler comes back for the next wave
+				assert.Equal(t, i < test.requeues, result.RequeueAfter == waves.Pause)
+			}
+
+			if test.event == "" {
+				assert.Empty(t, recorder.Events)
+			} else {
+				assert.Contains(t, <-recorder.Events, test.event)
+				assert.Contains(t, <-recorder.Events, test.event)
+			}
+
+			// Check that a restarted reconciler keeps the rollout where it stopped
+			elapsePause(t, fakeClient, waves.Pause)
+
+			reconciler = newReconciler(fakeClient, fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), newWriter(fakeClient), replica.Types{corev1.SecretTypeTLS}, FanOut{}, waves, nil, recorder)
+
+			_, err := reconciler.Reconcile(context.TODO(), request)
+			assert.NoError(t, err)
+
+			targetSecret := &corev1.Secret{}
+			err = fakeClient.Get(context.TODO(), client.ObjectKey{Namespace: "second", Name: "tls-example-io"}, targetSecret)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.warning == nil, string(targetSecret.Data[corev1.TLSCertKey]) == string(certificatePEM))
+		})
+	}
+}
+
+func TestReconcileWavesPause(t *testing.T) {
+	certificatePEM, privateKeyPEM := newTestCertificate(t)
+
+	sourceSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "tls-example-io",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certificatePEM,
+			corev1.TLSPrivateKeyKey: privateKeyPEM,
+		},
+	}
+
+	var objects []client.Object
+	for _, namespace := range []string{"first", "second"} {
+		objects = append(objects, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      "tls-example-io",
+				Labels:    replica.Labels("tls-example-io"),
+			},
+			Type: corev1.SecretTypeTLS,
+		})
+	}
+
+	// Create a client and the reconciler updating one namespace at a time
+	fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(append(objects, sourceSecret)...).Build(), index.Register)
+	waves := &Waves{BatchSize: 1, Pause: time.Minute}
+	reconciler := newReconciler(fakeClient, fakeClient, backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil), newWriter(fakeClient), replica.Types{corev1.SecretTypeTLS}, FanOut{}, waves, nil, record.NewFakeRecorder(10))
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "source", Name: "tls-example-io"}}
+
+	countUpdated := func() (updated int) {
+		for _, namespace := range []string{"first", "second"} {
+			targetSecret := &corev1.Secret{}
+			assert.NoError(t, fakeClient.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: "tls-example-io"}, targetSecret))
+
+			if string(targetSecret.Data[corev1.TLSCertKey]) == string(certificatePEM) {
+				updated++
+			}
+		}
+
+		return
+	}
+
+	_, err := reconciler.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, countUpdated())
+
+	// Verify that a reconciliation triggered by another event during the pause does not update the next batch
+	result, err := reconciler.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, countUpdated())
+	assert.True(t, result.RequeueAfter > 0 && result.RequeueAfter <= waves.Pause)
+
+	elapsePause(t, fakeClient, waves.Pause)
+
+	_, err = reconciler.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, countUpdated())
+}
+
+// elapsePause moves the last sync time of all copies back by the pause, as if it elapsed since they were written
+func elapsePause(t *testing.T, fakeClient client.Client, pause time.Duration) {
+	secretList := &corev1.SecretList{}
+	assert.NoError(t, fakeClient.List(context.TODO(), secretList))
+
+	for i := range secretList.Items {
+		secret := &secretList.Items[i]
+
+		lastSync, err := time.Parse(time.RFC3339, secret.Annotations[replica.LastSyncAnnotation])
+		if err != nil {
+			continue
+		}
+
+		secret.Annotations[replica.LastSyncAnnotation] = lastSync.Add(-pause).Format(time.RFC3339)
+		assert.NoError(t, fakeClient.Update(context.TODO(), secret))
+	}
+}
+
+// newTestCertificate returns a self-signed certificate and its private key in PEM form
+func newTestCertificate(t *testing.T) ([]byte, []byte) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.io"},
+		DNSNames:     []string{"example.io"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	certificateDER, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	assert.NoError(t, err)
+
+	privateKeyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	assert.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificateDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyDER})
+}
@@ -0,0 +1,135 @@
+package secretcache
+
+import (
+	"context"
+	"fmt"
+
+	"tls-secret-injector/pkg/replica"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Mode decides how the manager caches Secrets
+type Mode string
+
+const (
+	// ModeFull caches whole Secrets in all namespaces
+	ModeFull Mode = "full"
+	// ModeMetadata caches whole Secrets only in the source namespaces, and only the metadata of the Secrets elsewhere
+	ModeMetadata Mode = "metadata"
+)
+
+// ParseMode returns the mode with the given name
+func ParseMode(name string) (Mode, error) {
+	switch Mode(name) {
+	case ModeFull, ModeMetadata:
+		return Mode(name), nil
+
+	default:
+		return "", fmt.Errorf("unknown Secret cache mode [%s], expected full or metadata", name)
+	}
+}
+
+// Apply sets up the manager options for the mode
+func (m Mode) Apply(options *manager.Options) {
+	if m != ModeMetadata {
+		return
+	}
+
+	options.NewClient = newMetadataClient
+}
+
+// metadataClient reads whole Secrets and ConfigMaps from the API server, so no informer caches them, and reads
+// everything else, including their metadata, from the cache. The copies are found and checked through their metadata,
+// so whole Secrets are only read to write a copy: when an outdated copy is updated, its keystores are regenerated, an
+// unmanaged Secret is adopted, a copy is released by its Ingress or namespace, or the audit checks the data of a copy.
+// Secrets referenced through annotations and the history are read whole as well. Whole ConfigMaps are only read to
+// update or adopt a ConfigMap holding public certificates.
+type metadataClient struct {
+	client.Client
+
+	apiReader client.Reader
+}
+
+func newMetadataClient(cache cache.Cache, config *rest.Config, options client.Options, uncachedObjects ...client.Object) (client.Client, error) {
+	cachedClient, err := cluster.DefaultNewClient(cache, config, options, uncachedObjects...)
+	if err != nil {
+		return nil, err
+	}
+
+	apiReader, err := client.New(config, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metadataClient{
+		Client:    cachedClient,
+		apiReader: apiReader,
+	}, nil
+}
+
+func (c *metadataClient) Get(ctx context.Context, key client.ObjectKey, object client.Object) error {
+	switch object.(type) {
+	case *corev1.Secret, *corev1.ConfigMap:
+		return c.apiReader.Get(ctx, key, object)
+	}
+
+	return c.Client.Get(ctx, key, object)
+}
+
+func (c *metadataClient) List(ctx context.Context, list client.ObjectList, options ...client.ListOption) error {
+	switch list.(type) {
+	case *corev1.SecretList, *corev1.ConfigMapList:
+		return c.apiReader.List(ctx, list, options...)
+	}
+
+	return c.Client.List(ctx, list, options...)
+}
+
+// NewSourceCluster returns a cluster caching the whole Secrets of the source namespaces only. When they are selected by
+// label, which no cache can be restricted to, the Secrets of all namespaces are cached apart from the copies and the
+// ServiceAccount tokens, which are never sources.
+func NewSourceCluster(config *rest.Config, scheme *runtime.Scheme, namespaces []string, selector labels.Selector) (cluster.Cluster, error) {
+	if selector == nil {
+		return cluster.New(config, func(options *cluster.Options) {
+			options.Scheme = scheme
+			options.NewCache = cache.MultiNamespacedCacheBuilder(namespaces)
+		})
+	}
+
+	secretSelector, err := sourceSecretSelector()
+	if err != nil {
+		return nil, err
+	}
+
+	return cluster.New(config, func(options *cluster.Options) {
+		options.Scheme = scheme
+		options.NewCache = cache.BuilderWithOptions(cache.Options{
+			SelectorsByObject: cache.SelectorsByObject{
+				&corev1.Secret{}: secretSelector,
+			},
+		})
+	})
+}
+
+// sourceSecretSelector returns the selector leaving out the Secrets that cannot be source Secrets
+func sourceSecretSelector() (cache.ObjectSelector, error) {
+	notManaged, err := labels.NewRequirement(replica.NameLabel, selection.NotEquals, []string{replica.ManagedLabels()[replica.NameLabel]})
+	if err != nil {
+		return cache.ObjectSelector{}, fmt.Errorf("could not select the source Secrets: %v", err)
+	}
+
+	return cache.ObjectSelector{
+		Label: labels.NewSelector().Add(*notManaged),
+		Field: fields.OneTermNotEqualSelector("type", string(corev1.SecretTypeServiceAccountToken)),
+	}, nil
+}
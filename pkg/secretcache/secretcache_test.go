@@ -0,0 +1,229 @@
+package secretcache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"tls-secret-injector/pkg/replica"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParseMode(t *testing.T) {
+	tests := map[string]struct {
+		name         string
+		expectedMode Mode
+		expectError  bool
+	}{
+		"full": {
+			name:         "full",
+			expectedMode: ModeFull,
+		},
+		"metadata": {
+			name:         "metadata",
+			expectedMode: ModeMetadata,
+		},
+		"unknown": {
+			name:        "none",
+			expectError: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mode, err := ParseMode(test.name)
+
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedMode, mode)
+		})
+	}
+}
+
+func TestMetadataClient(t *testing.T) {
+	secret := newSecret("target-namespace", "tls-example-io")
+
+	// Create a cached client missing the Secret, and an API reader holding it
+	c := &metadataClient{
+		Client:    fake.NewClientBuilder().Build(),
+		apiReader: fake.NewClientBuilder().WithObjects(secret).Build(),
+	}
+
+	secretName := types.NamespacedName{
+		Namespace: secret.Namespace,
+		Name:      secret.Name,
+	}
+
+	// Whole Secrets are read from the API server
+	err := c.Get(context.TODO(), secretName, &corev1.Secret{})
+	assert.NoError(t, err)
+
+	secretList := &corev1.SecretList{}
+	err = c.List(context.TODO(), secretList)
+	assert.NoError(t, err)
+	assert.Len(t, secretList.Items, 1)
+
+	// The metadata of Secrets is read from the cache
+	err = c.Get(context.TODO(), secretName, replica.NewSecretMetadata())
+	assert.Error(t, err)
+}
+
+func TestSourceSecretSelector(t *testing.T) {
+	sourceSecret := newSecret("source", "tls-example-io")
+	sourceSecret.Labels = nil
+
+	tokenSecret := newSecret("source", "default-token")
+	tokenSecret.Labels = nil
+	tokenSecret.Type = corev1.SecretTypeServiceAccountToken
+
+	tests := map[string]struct {
+		secret   *corev1.Secret
+		selected bool
+	}{
+		"select source Secret": {
+			secret:   sourceSecret,
+			selected: true,
+		},
+		"leave out copy": {
+			secret: newSecret("target", "tls-example-io"),
+		},
+		"leave out ServiceAccount token": {
+			secret: tokenSecret,
+		},
+	}
+
+	secretSelector, err := sourceSecretSelector()
+	assert.NoError(t, err)
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			selected := secretSelector.Label.Matches(labels.Set(test.secret.Labels)) &&
+				secretSelector.Field.Matches(fields.Set{"type": string(test.secret.Type)})
+
+			assert.Equal(t, test.selected, selected)
+		})
+	}
+}
+
+// BenchmarkCache reports the memory held by the informer caching whole Secrets against the informer caching their
+// metadata only, filled through a ListWatch as from the API server
+func BenchmarkCache(b *testing.B) {
+	for _, count := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("full/%d", count), func(b *testing.B) {
+			benchmarkCache(b, count, &corev1.Secret{}, func() client.ObjectList {
+				secretList := &corev1.SecretList{}
+				for j := 0; j < count; j++ {
+					secretList.Items = append(secretList.Items, *newSecret(fmt.Sprintf("namespace-%d", j), "tls-example-io"))
+				}
+
+				return secretList
+			})
+		})
+
+		b.Run(fmt.Sprintf("metadata/%d", count), func(b *testing.B) {
+			benchmarkCache(b, count, replica.NewSecretMetadata(), func() client.ObjectList {
+				metadataList := &metav1.PartialObjectMetadataList{}
+				for j := 0; j < count; j++ {
+					secretMetadata := replica.NewSecretMetadata()
+					secretMetadata.ObjectMeta = newSecret(fmt.Sprintf("namespace-%d", j), "tls-example-io").ObjectMeta
+
+					metadataList.Items = append(metadataList.Items, *secretMetadata)
+				}
+
+				return metadataList
+			})
+		})
+	}
+}
+
+func benchmarkCache(b *testing.B, count int, object client.Object, newList func() client.ObjectList) {
+	var heapBytes uint64
+
+	for i := 0; i < b.N; i++ {
+		before := heapAlloc()
+
+		// Run the same kind of informer as the cache of the manager, indexed by namespace like it
+		listWatch := &toolscache.ListWatch{
+			ListFunc: func(metav1.ListOptions) (k8sruntime.Object, error) {
+				return newList(), nil
+			},
+			WatchFunc: func(metav1.ListOptions) (watch.Interface, error) {
+				return watch.NewFake(), nil
+			},
+		}
+
+		informer := toolscache.NewSharedIndexInformer(listWatch, object, 0, toolscache.Indexers{
+			toolscache.NamespaceIndex: toolscache.MetaNamespaceIndexFunc,
+		})
+
+		stop := make(chan struct{})
+		done := make(chan struct{})
+
+		go func() {
+			informer.Run(stop)
+			close(done)
+		}()
+
+		if !toolscache.WaitForCacheSync(stop, informer.HasSynced) {
+			b.Fatal("the informer did not sync")
+		}
+		if len(informer.GetStore().ListKeys()) != count {
+			b.Fatalf("the informer holds %d Secrets instead of %d", len(informer.GetStore().ListKeys()), count)
+		}
+
+		after := heapAlloc()
+		if after > before {
+			heapBytes += after - before
+		}
+
+		// Let the informer stop, so its objects are freed before the next run is measured
+		close(stop)
+		<-done
+	}
+
+	b.ReportMetric(float64(heapBytes)/float64(b.N), "heap-bytes/op")
+	b.ReportMetric(float64(heapBytes)/float64(b.N)/float64(count), "heap-bytes/secret")
+}
+
+func heapAlloc() uint64 {
+	runtime.GC()
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	return stats.HeapAlloc
+}
+
+// newSecret returns a copy holding a certificate chain and key of a realistic size
+func newSecret(namespace, name string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   namespace,
+			Name:        name,
+			Labels:      replica.Labels(name),
+			Annotations: map[string]string{replica.DataHashAnnotation: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       bytes.Repeat([]byte("A"), 5*1024),
+			corev1.TLSPrivateKeyKey: bytes.Repeat([]byte("B"), 1024),
+		},
+	}
+}
@@ -0,0 +1,44 @@
+package api
+
+import "time"
+
+// Source is a source Secret the copies are made from
+type Source struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	DataHash  string `json:"dataHash"`
+	Version   string `json:"version,omitempty"`
+	Copies    int    `json:"copies"`
+}
+
+// Copy is a Secret copied from a source Secret, and whether it holds the data of its source Secret
+type Copy struct {
+	Namespace       string    `json:"namespace"`
+	Name            string    `json:"name"`
+	SourceNamespace string    `json:"sourceNamespace,omitempty"`
+	SourceName      string    `json:"sourceName"`
+	Synced          bool      `json:"synced"`
+	LastSync        time.Time `json:"lastSync"`
+	Version         string    `json:"version,omitempty"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// WaitingIngress is an Ingress using a Secret that has neither a copy nor a source Secret yet
+type WaitingIngress struct {
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	SecretName string `json:"secretName"`
+	Source     string `json:"source"`
+}
+
+// Error is an error reported by the injector
+type Error struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// ResyncResult lists the source Secrets whose copies are checked and updated again
+type ResyncResult struct {
+	Sources []string `json:"sources"`
+}
@@ -0,0 +1,75 @@
+package status
+
+import (
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+// authorized only passes the requests on whose bearer token Kubernetes authenticates, and whose user RBAC allows the
+// HTTP verb on the path as a non-resource URL
+func (s *Server) authorized(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		token := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
+		if token == "" || token == request.Header.Get("Authorization") {
+			http.Error(writer, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		tokenReview := &authenticationv1.TokenReview{
+			Spec: authenticationv1.TokenReviewSpec{
+				Token: token,
+			},
+		}
+
+		err := s.client.Create(request.Context(), tokenReview)
+		if err != nil {
+			log.Errorf("could not review the token of a status API request: %v", err)
+			http.Error(writer, "could not review the token", http.StatusInternalServerError)
+			return
+		}
+
+		if !tokenReview.Status.Authenticated {
+			http.Error(writer, "invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		user := tokenReview.Status.User
+
+		extra := map[string]authorizationv1.ExtraValue{}
+		for key, value := range user.Extra {
+			extra[key] = authorizationv1.ExtraValue(value)
+		}
+
+		accessReview := &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				User:   user.Username,
+				Groups: user.Groups,
+				UID:    user.UID,
+				Extra:  extra,
+				NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+					Path: request.URL.Path,
+					Verb: strings.ToLower(request.Method),
+				},
+			},
+		}
+
+		err = s.client.Create(request.Context(), accessReview)
+		if err != nil {
+			log.Errorf("could not review the access of user [%s] to the status API: %v", user.Username, err)
+			http.Error(writer, "could not review the access", http.StatusInternalServerError)
+			return
+		}
+
+		if !accessReview.Status.Allowed {
+			http.Error(writer, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(writer, request)
+	})
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"tls-secret-injector/pkg/status/api"
+)
+
+// Client calls the status API of the injector
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// New returns a pointer to Client calling the injector at the base URL, such as https://tls-secret-injector.tls-secret-injector.svc,
+// with a bearer token allowed to access the status API, where a nil httpClient uses the default one
+func New(baseURL, token string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		httpClient: httpClient,
+	}
+}
+
+// Sources returns the source Secrets
+func (c *Client) Sources(ctx context.Context) ([]api.Source, error) {
+	var sources []api.Source
+
+	err := c.do(ctx, http.MethodGet, "sources", nil, &sources)
+
+	return sources, err
+}
+
+// Copies returns the copies of all source Secrets, or of the given source Secret as name or namespace/name
+func (c *Client) Copies(ctx context.Context, source string) ([]api.Copy, error) {
+	query := url.Values{}
+	if source != "" {
+		query.Set("source", source)
+	}
+
+	var copies []api.Copy
+
+	err := c.do(ctx, http.MethodGet, "copies", query, &copies)
+
+	return copies, err
+}
+
+// Waiting returns the Ingresses using Secrets that have neither a copy nor a source Secret
+func (c *Client) Waiting(ctx context.Context) ([]api.WaitingIngress, error) {
+	var waiting []api.WaitingIngress
+
+	err := c.do(ctx, http.MethodGet, "waiting", nil, &waiting)
+
+	return waiting, err
+}
+
+// Errors returns the most recent errors of the injector, most recent first
+func (c *Client) Errors(ctx context.Context) ([]api.Error, error) {
+	var errors []api.Error
+
+	err := c.do(ctx, http.MethodGet, "errors", nil, &errors)
+
+	return errors, err
+}
+
+// ResyncSource updates the copies of the given source Secret, as name or namespace/name
+func (c *Client) ResyncSource(ctx context.Context, source string) (*api.ResyncResult, error) {
+	result := &api.ResyncResult{}
+
+	err := c.do(ctx, http.MethodPost, "resync", url.Values{"source": {source}}, result)
+
+	return result, err
+}
+
+// ResyncNamespace updates the copies in the given namespace, along with all other copies of their source Secrets
+func (c *Client) ResyncNamespace(ctx context.Context, namespace string) (*api.ResyncResult, error) {
+	result := &api.ResyncResult{}
+
+	err := c.do(ctx, http.MethodPost, "resync", url.Values{"namespace": {namespace}}, result)
+
+	return result, err
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, response interface{}) error {
+	requestURL := c.baseURL + "/status/" + path
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, requestURL, nil)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Authorization", "Bearer "+c.token)
+
+	httpResponse, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(httpResponse.Body, 1024))
+		return fmt.Errorf("status API returned %s: %s", httpResponse.Status, strings.TrimSpace(string(body)))
+	}
+
+	return json.NewDecoder(httpResponse.Body).Decode(response)
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"tls-secret-injector/pkg/status/api"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient(t *testing.T) {
+	var requests []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Header.Get("Authorization") != "Bearer token" {
+			http.Error(writer, "invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		requests = append(requests, request.Method+" "+request.URL.String())
+
+		switch request.URL.Path {
+		case "/status/copies":
+			_ = json.NewEncoder(writer).Encode([]api.Copy{{Namespace: "target", Name: "tls-example-io", Synced: true}})
+		case "/status/resync":
+			_ = json.NewEncoder(writer).Encode(api.ResyncResult{Sources: []string{"source/tls-example-io"}})
+		default:
+			http.NotFound(writer, request)
+		}
+	}))
+	defer server.Close()
+
+	c := New(server.URL+"/", "token", nil)
+
+	copies, err := c.Copies(context.TODO(), "source/tls-example-io")
+	assert.NoError(t, err)
+	assert.Equal(t, []api.Copy{{Namespace: "target", Name: "tls-example-io", Synced: true}}, copies)
+
+	result, err := c.ResyncNamespace(context.TODO(), "target")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"source/tls-example-io"}, result.Sources)
+
+	assert.Equal(t, []string{
+		"GET /status/copies?source=source%2Ftls-example-io",
+		"POST /status/resync?namespace=target",
+	}, requests)
+
+	// Check that errors of the API are returned
+	_, err = c.Waiting(context.TODO())
+	assert.EqualError(t, err, "status API returned 404 Not Found: 404 page not found")
+
+	_, err = New(server.URL, "invalid", nil).Sources(context.TODO())
+	assert.EqualError(t, err, "status API returned 401 Unauthorized: invalid bearer token")
+}
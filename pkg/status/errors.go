@@ -0,0 +1,59 @@
+package status
+
+import (
+	"sync"
+
+	"tls-secret-injector/pkg/status/api"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrorLog keeps the most recent errors logged, as a hook of the logger
+type ErrorLog struct {
+	size int
+
+	mutex  sync.Mutex
+	errors []api.Error
+}
+
+// NewErrorLog returns a pointer to ErrorLog keeping the given number of errors
+func NewErrorLog(size int) *ErrorLog {
+	return &ErrorLog{
+		size: size,
+	}
+}
+
+// Levels returns the levels of the entries kept
+func (e *ErrorLog) Levels() []log.Level {
+	return []log.Level{log.ErrorLevel}
+}
+
+// Fire keeps the entry, dropping the oldest one once full
+func (e *ErrorLog) Fire(entry *log.Entry) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.errors = append(e.errors, api.Error{
+		Time:    entry.Time,
+		Message: entry.Message,
+	})
+
+	if len(e.errors) > e.size {
+		e.errors = e.errors[len(e.errors)-e.size:]
+	}
+
+	return nil
+}
+
+// Errors returns the errors kept, most recent first
+func (e *ErrorLog) Errors() []api.Error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	errors := make([]api.Error, 0, len(e.errors))
+	for i := len(e.errors) - 1; i >= 0; i-- {
+		errors = append(errors, e.errors[i])
+	}
+
+	return errors
+}
@@ -0,0 +1,32 @@
+package status
+
+import (
+	"testing"
+
+	"tls-secret-injector/pkg/status/api"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorLog(t *testing.T) {
+	errorLog := NewErrorLog(2)
+
+	logger := log.New()
+	logger.AddHook(errorLog)
+
+	// Only errors are kept, and only the most recent ones
+	logger.Error("first")
+	logger.Warn("warning")
+	logger.Error("second")
+	logger.Error("third")
+
+	var messages []string
+	for _, entry := range errorLog.Errors() {
+		assert.False(t, entry.Time.IsZero())
+		messages = append(messages, entry.Message)
+	}
+
+	assert.Equal(t, []string{"third", "second"}, messages)
+	assert.Equal(t, []api.Error{}, NewErrorLog(2).Errors())
+}
@@ -0,0 +1,542 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/index"
+	"tls-secret-injector/pkg/ingress"
+	"tls-secret-injector/pkg/replica"
+	"tls-secret-injector/pkg/status/api"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// PathPrefix prefixes the paths of the status API
+const PathPrefix = "/status/"
+
+// sourcesMaxAge is how long the listed source Secrets are served before they are listed again
+const sourcesMaxAge = 30 * time.Second
+
+// Server serves the state of the injector as JSON, and triggers resyncs of source Secrets
+type Server struct {
+	client   client.Client
+	lookup   *index.Lookup
+	source   backend.SecretSource
+	writer   *replica.Writer
+	errorLog *ErrorLog
+
+	// resync enqueues a source Secret into the Secret controller, which only runs once elected, while the other
+	// replicas mark a copy of the source Secret that the leader watches for
+	resync  func(ctx context.Context, name types.NamespacedName)
+	elected <-chan struct{}
+
+	// sources holds the source Secrets last listed, without their copies, so requests do not list them from the
+	// source each time
+	sources       []api.Source
+	sourcesListed time.Time
+	sourcesLock   sync.Mutex
+}
+
+// NewServer returns a pointer to Server
+func NewServer(client client.Client, secretSource backend.SecretSource, writer *replica.Writer, errorLog *ErrorLog, resync func(ctx context.Context, name types.NamespacedName), elected <-chan struct{}) *Server {
+	return &Server{
+		client:   client,
+		lookup:   index.NewLookup(client),
+		source:   secretSource,
+		writer:   writer,
+		errorLog: errorLog,
+		resync:   resync,
+		elected:  elected,
+	}
+}
+
+// Register serves the status API on the webhook server, next to the webhooks and with the same certificate
+func (s *Server) Register(webhookServer *webhook.Server) {
+	handlers := map[string]http.HandlerFunc{
+		"sources": s.getOnly(s.serveSources),
+		"copies":  s.getOnly(s.serveCopies),
+		"waiting": s.getOnly(s.serveWaiting),
+		"errors":  s.getOnly(s.serveErrors),
+		"resync":  s.serveResync,
+	}
+
+	for name, handler := range handlers {
+		webhookServer.Register(PathPrefix+name, s.authorized(handler))
+	}
+}
+
+// serveSources lists the source Secrets and how many copies they have
+func (s *Server) serveSources(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	cachedSources, err := s.listSources(ctx)
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+
+	sources := make([]api.Source, 0, len(cachedSources))
+
+	for _, source := range cachedSources {
+		copies, err := s.lookup.SecretCopiesOf(ctx, types.NamespacedName{Name: source.Name})
+		if err != nil {
+			writeError(writer, err)
+			return
+		}
+
+		// Leave out the copies of Secrets with the same name referenced through annotations outside of the source
+		for i := range copies {
+			if s.isSourceCopy(ctx, &copies[i]) {
+				source.Copies++
+			}
+		}
+
+		sources = append(sources, source)
+	}
+
+	writeJSON(writer, sources)
+}
+
+// serveCopies lists the copies, of all source Secrets or only of the source query parameter, and whether they hold the
+// data of their source Secret
+func (s *Server) serveCopies(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	var copies []metav1.PartialObjectMetadata
+	var err error
+
+	if source := request.URL.Query().Get("source"); source != "" {
+		copies, err = s.lookup.SecretCopiesOf(ctx, parseSourceName(source))
+	} else {
+		copies, err = s.listCopies(ctx)
+	}
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+
+	statuses := make([]api.Copy, 0, len(copies))
+	sourceSecrets := map[types.NamespacedName]*corev1.Secret{}
+
+	for i := range copies {
+		targetSecretMetadata := &copies[i]
+
+		copyStatus := api.Copy{
+			Namespace:       targetSecretMetadata.Namespace,
+			Name:            targetSecretMetadata.Name,
+			SourceNamespace: targetSecretMetadata.Annotations[replica.SourceNamespaceAnnotation],
+			SourceName:      targetSecretMetadata.Labels[replica.SourceNameLabel],
+			Version:         targetSecretMetadata.Annotations[replica.VersionAnnotation],
+		}
+
+		lastSync, err := time.Parse(time.RFC3339, targetSecretMetadata.Annotations[replica.LastSyncAnnotation])
+		if err == nil {
+			copyStatus.LastSync = lastSync
+		}
+
+		copyStatus.Synced, err = s.isSynced(ctx, targetSecretMetadata, sourceSecrets)
+		if err != nil {
+			copyStatus.Error = err.Error()
+		}
+
+		statuses = append(statuses, copyStatus)
+	}
+
+	writeJSON(writer, statuses)
+}
+
+// serveWaiting lists the Ingresses using Secrets that have neither a copy nor a source Secret
+func (s *Server) serveWaiting(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	ingressList := &networkingv1.IngressList{}
+
+	err := s.client.List(ctx, ingressList)
+	if err != nil {
+		writeError(writer, fmt.Errorf("could not list Ingresses: %v", err))
+		return
+	}
+
+	waiting := []api.WaitingIngress{}
+
+	for i := range ingressList.Items {
+		ingressObject := &ingressList.Items[i]
+		if s.source.IsSourceNamespace(ctx, ingressObject.Namespace) {
+			continue
+		}
+
+		for _, ingressTLS := range ingressObject.Spec.TLS {
+			if ingressTLS.SecretName == "" {
+				continue
+			}
+
+			source, missing, err := s.isSourceMissing(ctx, ingressObject, ingressTLS.SecretName)
+			if err != nil {
+				writeError(writer, err)
+				return
+			}
+			if !missing {
+				continue
+			}
+
+			waiting = append(waiting, api.WaitingIngress{
+				Namespace:  ingressObject.Namespace,
+				Name:       ingressObject.Name,
+				SecretName: ingressTLS.SecretName,
+				Source:     source,
+			})
+		}
+	}
+
+	writeJSON(writer, waiting)
+}
+
+// serveErrors lists the most recent errors, most recent first
+func (s *Server) serveErrors(writer http.ResponseWriter, _ *http.Request) {
+	writeJSON(writer, s.errorLog.Errors())
+}
+
+// serveResync enqueues the source Secret given by the source query parameter, or the source Secrets of all copies in
+// the namespace query parameter
+func (s *Server) serveResync(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := request.Context()
+	query := request.URL.Query()
+
+	var sourceNames []types.NamespacedName
+	var err error
+
+	switch {
+	case query.Get("source") != "":
+		var sourceName types.NamespacedName
+
+		sourceName, err = s.resolveSourceName(ctx, parseSourceName(query.Get("source")))
+		sourceNames = []types.NamespacedName{sourceName}
+
+	case query.Get("namespace") != "":
+		sourceNames, err = s.listSourceNames(ctx, query.Get("namespace"))
+
+	default:
+		http.Error(writer, "either the source or the namespace query parameter is required", http.StatusBadRequest)
+		return
+	}
+	if errors.IsNotFound(err) {
+		http.Error(writer, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+
+	result := api.ResyncResult{
+		Sources: []string{},
+	}
+
+	for _, sourceName := range sourceNames {
+		var requested bool
+
+		requested, err = s.requestResync(ctx, sourceName)
+		if err != nil {
+			writeError(writer, err)
+			return
+		}
+		if !requested {
+			continue
+		}
+
+		result.Sources = append(result.Sources, sourceName.String())
+	}
+
+	writeJSON(writer, result)
+}
+
+// requestResync enqueues the source Secret when this replica is the leader running the Secret controller, and
+// otherwise marks one of its copies for the leader to resync it, returning false when the source Secret has no copies
+func (s *Server) requestResync(ctx context.Context, sourceName types.NamespacedName) (bool, error) {
+	select {
+	case <-s.elected:
+		log.Infof("Resyncing source Secret [%s] as requested through the status API", sourceName)
+
+		s.resync(ctx, sourceName)
+		return true, nil
+	default:
+	}
+
+	copies, err := s.lookup.SecretCopiesOf(ctx, types.NamespacedName{Name: sourceName.Name})
+	if err != nil {
+		return false, err
+	}
+
+	for i := range copies {
+		targetSecretMetadata := &copies[i]
+
+		// Copies made before their provenance was recorded come from the source
+		if sourceNamespace, ok := targetSecretMetadata.Annotations[replica.SourceNamespaceAnnotation]; ok && sourceNamespace != sourceName.Namespace || !ok && !s.source.IsSourceNamespace(ctx, sourceName.Namespace) {
+			continue
+		}
+
+		targetSecretName := types.NamespacedName{
+			Namespace: targetSecretMetadata.Namespace,
+			Name:      targetSecretMetadata.Name,
+		}
+
+		targetSecret := &corev1.Secret{}
+
+		err = s.client.Get(ctx, targetSecretName, targetSecret)
+		if err != nil {
+			return false, fmt.Errorf("could not fetch the target Secret [%s]: %v", targetSecretName, err)
+		}
+
+		if targetSecret.Annotations == nil {
+			targetSecret.Annotations = map[string]string{}
+		}
+		targetSecret.Annotations[replica.ResyncRequestedAnnotation] = time.Now().Format(time.RFC3339Nano)
+
+		err = s.client.Update(ctx, targetSecret)
+		if err != nil {
+			return false, fmt.Errorf("could not request the resync on the target Secret [%s]: %v", targetSecretName, err)
+		}
+
+		log.Infof("Requested the resync of source Secret [%s] from the leader on its copy [%s]", sourceName, targetSecretName)
+
+		return true, nil
+	}
+
+	log.Warnf("Skipping the resync of source Secret [%s] as it has no copies to request it on from the leader", sourceName)
+
+	return false, nil
+}
+
+// listSources returns the source Secrets without their copies, listing them again once they are older than
+// sourcesMaxAge
+func (s *Server) listSources(ctx context.Context) ([]api.Source, error) {
+	s.sourcesLock.Lock()
+	defer s.sourcesLock.Unlock()
+
+	if s.sources != nil && time.Since(s.sourcesListed) < sourcesMaxAge {
+		return s.sources, nil
+	}
+
+	sourceSecrets, err := s.source.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not list the source Secrets: %v", err)
+	}
+
+	sources := make([]api.Source, 0, len(sourceSecrets))
+
+	for _, sourceSecret := range sourceSecrets {
+		sources = append(sources, api.Source{
+			Namespace: sourceSecret.Namespace,
+			Name:      sourceSecret.Name,
+			Type:      string(sourceSecret.Type),
+			DataHash:  replica.DataHash(sourceSecret.Data),
+			Version:   sourceSecret.Annotations[replica.VersionAnnotation],
+		})
+	}
+
+	s.sources = sources
+	s.sourcesListed = time.Now()
+
+	return sources, nil
+}
+
+// listCopies returns the metadata of all copies of source Secrets
+func (s *Server) listCopies(ctx context.Context) ([]metav1.PartialObjectMetadata, error) {
+	secretMetadataList := &metav1.PartialObjectMetadataList{}
+	secretMetadataList.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("SecretList"))
+
+	err := s.client.List(ctx, secretMetadataList, client.HasLabels{replica.SourceNameLabel}, client.MatchingLabels(replica.ManagedLabels()))
+	if err != nil {
+		return nil, fmt.Errorf("could not list the copies: %v", err)
+	}
+
+	return secretMetadataList.Items, nil
+}
+
+// listSourceNames returns the source Secrets of the copies in the namespace
+func (s *Server) listSourceNames(ctx context.Context, namespace string) ([]types.NamespacedName, error) {
+	secretMetadataList := &metav1.PartialObjectMetadataList{}
+	secretMetadataList.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("SecretList"))
+
+	err := s.client.List(ctx, secretMetadataList, client.InNamespace(namespace), client.HasLabels{replica.SourceNameLabel}, client.MatchingLabels(replica.ManagedLabels()))
+	if err != nil {
+		return nil, fmt.Errorf("could not list the copies in namespace [%s]: %v", namespace, err)
+	}
+
+	var sourceNames []types.NamespacedName
+	found := map[types.NamespacedName]bool{}
+
+	for i := range secretMetadataList.Items {
+		sourceName, err := s.resolveSourceName(ctx, copySourceName(&secretMetadataList.Items[i]))
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if !found[sourceName] {
+			found[sourceName] = true
+			sourceNames = append(sourceNames, sourceName)
+		}
+	}
+
+	return sourceNames, nil
+}
+
+// resolveSourceName returns the key under which the Secret controller takes the source Secret, looking up the
+// namespace of the source Secret when unknown
+func (s *Server) resolveSourceName(ctx context.Context, sourceName types.NamespacedName) (types.NamespacedName, error) {
+	if sourceName.Namespace != "" {
+		return sourceName, nil
+	}
+
+	sourceSecret, err := s.source.Get(ctx, sourceName.Name)
+	if err != nil {
+		return types.NamespacedName{}, err
+	}
+
+	return types.NamespacedName{
+		Namespace: sourceSecret.Namespace,
+		Name:      sourceSecret.Name,
+	}, nil
+}
+
+// isSynced returns whether the copy holds the data of its source Secret, fetching each source Secret once
+func (s *Server) isSynced(ctx context.Context, targetSecretMetadata *metav1.PartialObjectMetadata, sourceSecrets map[types.NamespacedName]*corev1.Secret) (bool, error) {
+	sourceName := copySourceName(targetSecretMetadata)
+
+	sourceSecret, ok := sourceSecrets[sourceName]
+	if !ok {
+		var err error
+
+		if s.isSourceCopy(ctx, targetSecretMetadata) {
+			sourceSecret, err = s.source.Get(ctx, sourceName.Name)
+		} else {
+			sourceSecret = &corev1.Secret{}
+			err = s.client.Get(ctx, sourceName, sourceSecret)
+		}
+		if err != nil {
+			return false, fmt.Errorf("could not fetch the source Secret [%s]: %v", sourceName, err)
+		}
+
+		sourceSecrets[sourceName] = sourceSecret
+	}
+
+	data, err := s.writer.Data(ctx, sourceSecret, targetSecretMetadata.Namespace)
+	if err != nil {
+		return false, fmt.Errorf("could not resolve the data of the copy [%s/%s]: %v", targetSecretMetadata.Namespace, targetSecretMetadata.Name, err)
+	}
+
+	return replica.IsRecorded(targetSecretMetadata, data), nil
+}
+
+// isSourceMissing returns the source Secret of the secretName of the Ingress, and whether neither a copy nor the source
+// Secret exists
+func (s *Server) isSourceMissing(ctx context.Context, ingressObject *networkingv1.Ingress, secretName string) (string, bool, error) {
+	targetSecretName := types.NamespacedName{
+		Namespace: ingressObject.Namespace,
+		Name:      secretName,
+	}
+
+	err := s.client.Get(ctx, targetSecretName, replica.NewSecretMetadata())
+	if err == nil {
+		return "", false, nil
+	}
+	if !errors.IsNotFound(err) {
+		return "", false, fmt.Errorf("could not fetch the target Secret [%s]: %v", targetSecretName, err)
+	}
+
+	sourceSecretName, ok, err := ingress.SourceReference(ingressObject, secretName)
+	if err != nil {
+		return "", false, err
+	}
+
+	source := secretName
+	if ok {
+		source = sourceSecretName.String()
+		err = s.client.Get(ctx, sourceSecretName, replica.NewSecretMetadata())
+	} else {
+		_, err = s.source.Get(ctx, secretName)
+	}
+	if errors.IsNotFound(err) {
+		return source, true, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("could not fetch the source Secret [%s]: %v", source, err)
+	}
+
+	return "", false, nil
+}
+
+// isSourceCopy returns whether the copy was made from the source, rather than from a Secret referenced through an
+// annotation
+func (s *Server) isSourceCopy(ctx context.Context, target metav1.Object) bool {
+	sourceNamespace, ok := target.GetAnnotations()[replica.SourceNamespaceAnnotation]
+
+	return !ok || s.source.IsSourceNamespace(ctx, sourceNamespace)
+}
+
+func (s *Server) getOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodGet {
+			http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		handler(writer, request)
+	}
+}
+
+// copySourceName returns the namespace/name of the source Secret the copy was made from, where the namespace is empty
+// when it was not recorded
+func copySourceName(target metav1.Object) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: target.GetAnnotations()[replica.SourceNamespaceAnnotation],
+		Name:      target.GetLabels()[replica.SourceNameLabel],
+	}
+}
+
+// parseSourceName parses a source Secret given as name or namespace/name
+func parseSourceName(source string) types.NamespacedName {
+	parts := strings.SplitN(source, "/", 2)
+	if len(parts) == 2 {
+		return types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+	}
+
+	return types.NamespacedName{Name: source}
+}
+
+func writeJSON(writer http.ResponseWriter, value interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(writer).Encode(value)
+	if err != nil {
+		log.Errorf("could not write the status API response: %v", err)
+	}
+}
+
+func writeError(writer http.ResponseWriter, err error) {
+	log.Error(err)
+	http.Error(writer, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,300 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/index"
+	"tls-secret-injector/pkg/index/indexfake"
+	"tls-secret-injector/pkg/policy"
+	"tls-secret-injector/pkg/replica"
+	"tls-secret-injector/pkg/status/api"
+
+	"github.com/stretchr/testify/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// reviewingClient answers the token and access reviews, for the token "valid" of the user "admin" allowed on all paths
+// except those of the "forbidden" method
+type reviewingClient struct {
+	client.Client
+}
+
+func (c *reviewingClient) Create(ctx context.Context, object client.Object, opts ...client.CreateOption) error {
+	switch review := object.(type) {
+	case *authenticationv1.TokenReview:
+		if review.Spec.Token == "valid" {
+			review.Status.Authenticated = true
+			review.Status.User = authenticationv1.UserInfo{Username: "admin"}
+		}
+		return nil
+
+	case *authorizationv1.SubjectAccessReview:
+		review.Status.Allowed = review.Spec.User == "admin" && review.Spec.NonResourceAttributes.Verb != "delete"
+		return nil
+	}
+
+	return c.Client.Create(ctx, object, opts...)
+}
+
+func TestAuthorized(t *testing.T) {
+	tests := map[string]struct {
+		method         string
+		authorization  string
+		expectedStatus int
+	}{
+		"missing token": {
+			method:         http.MethodGet,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"not a bearer token": {
+			method:         http.MethodGet,
+			authorization:  "Basic valid",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"invalid token": {
+			method:         http.MethodGet,
+			authorization:  "Bearer invalid",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"forbidden verb": {
+			method:         http.MethodDelete,
+			authorization:  "Bearer valid",
+			expectedStatus: http.StatusForbidden,
+		},
+		"allowed": {
+			method:         http.MethodGet,
+			authorization:  "Bearer valid",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := &Server{client: &reviewingClient{fake.NewClientBuilder().Build()}}
+			handler := server.authorized(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+				writer.WriteHeader(http.StatusOK)
+			}))
+
+			request := httptest.NewRequest(test.method, "/status/sources", nil)
+			if test.authorization != "" {
+				request.Header.Set("Authorization", test.authorization)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+		})
+	}
+}
+
+func TestServeSourcesAndCopies(t *testing.T) {
+	server, _ := newServer(t, newSecret("source"), newCopy("synced"), newCopy("stale"))
+
+	// Mark one copy as holding the data of its source Secret
+	syncedCopy := &corev1.Secret{}
+	err := server.client.Get(context.TODO(), types.NamespacedName{Namespace: "synced", Name: "tls-example-io"}, syncedCopy)
+	assert.NoError(t, err)
+
+	data, err := server.writer.Data(context.TODO(), newSecret("source"), "synced")
+	assert.NoError(t, err)
+
+	syncedCopy.Annotations = map[string]string{replica.DataHashAnnotation: replica.DataHash(data)}
+	assert.NoError(t, server.client.Update(context.TODO(), syncedCopy))
+
+	var sources []api.Source
+	serve(t, server.serveSources, http.MethodGet, "/status/sources", &sources)
+
+	if assert.Len(t, sources, 1) {
+		assert.Equal(t, "source", sources[0].Namespace)
+		assert.Equal(t, "tls-example-io", sources[0].Name)
+		assert.Equal(t, 2, sources[0].Copies)
+	}
+
+	var copies []api.Copy
+	serve(t, server.serveCopies, http.MethodGet, "/status/copies?source=tls-example-io", &copies)
+
+	synced := map[string]bool{}
+	for _, copyStatus := range copies {
+		assert.Empty(t, copyStatus.Error)
+		synced[copyStatus.Namespace] = copyStatus.Synced
+	}
+
+	assert.Equal(t, map[string]bool{"synced": true, "stale": false}, synced)
+
+	// Check that the source Secrets are listed again only once the listed ones are too old
+	otherSecret := newSecret("source")
+	otherSecret.Name = "tls-other-io"
+	assert.NoError(t, server.client.Create(context.TODO(), otherSecret))
+
+	serve(t, server.serveSources, http.MethodGet, "/status/sources", &sources)
+	assert.Len(t, sources, 1)
+
+	server.sourcesListed = server.sourcesListed.Add(-sourcesMaxAge)
+
+	serve(t, server.serveSources, http.MethodGet, "/status/sources", &sources)
+	assert.Len(t, sources, 2)
+}
+
+func TestServeWaiting(t *testing.T) {
+	server, _ := newServer(t, newSecret("source"), newCopy("copied"), newIngress("copied", "tls-example-io"), newIngress("copied", "tls-missing-io"), newIngress("source", "tls-missing-io"))
+
+	var waiting []api.WaitingIngress
+	serve(t, server.serveWaiting, http.MethodGet, "/status/waiting", &waiting)
+
+	// Only the Ingress outside the source using a Secret without a source Secret is waiting
+	assert.Equal(t, []api.WaitingIngress{
+		{Namespace: "copied", Name: "tls-missing-io", SecretName: "tls-missing-io", Source: "tls-missing-io"},
+	}, waiting)
+}
+
+func TestServeResync(t *testing.T) {
+	tests := map[string]struct {
+		target          string
+		elected         bool
+		expectedStatus  int
+		expectedResyncs []types.NamespacedName
+		// expectedRequest is whether the copy is marked for the leader to resync its source Secret
+		expectedRequest bool
+	}{
+		"source by name": {
+			target:          "/status/resync?source=tls-example-io",
+			elected:         true,
+			expectedStatus:  http.StatusOK,
+			expectedResyncs: []types.NamespacedName{{Namespace: "source", Name: "tls-example-io"}},
+		},
+		"namespace": {
+			target:          "/status/resync?namespace=copied",
+			elected:         true,
+			expectedStatus:  http.StatusOK,
+			expectedResyncs: []types.NamespacedName{{Namespace: "source", Name: "tls-example-io"}},
+		},
+		"unknown source": {
+			target:         "/status/resync?source=tls-unknown-io",
+			elected:        true,
+			expectedStatus: http.StatusNotFound,
+		},
+		"missing parameter": {
+			target:         "/status/resync",
+			elected:        true,
+			expectedStatus: http.StatusBadRequest,
+		},
+		"follower": {
+			target:          "/status/resync?source=tls-example-io",
+			expectedStatus:  http.StatusOK,
+			expectedRequest: true,
+		},
+		"follower by namespace": {
+			target:          "/status/resync?namespace=copied",
+			expectedStatus:  http.StatusOK,
+			expectedRequest: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server, resyncs := newServer(t, newSecret("source"), newCopy("copied"))
+
+			elected := make(chan struct{})
+			if test.elected {
+				close(elected)
+			}
+			server.elected = elected
+
+			recorder := httptest.NewRecorder()
+			server.serveResync(recorder, httptest.NewRequest(http.MethodPost, test.target, nil))
+
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+			assert.Equal(t, test.expectedResyncs, *resyncs)
+
+			// Check that the followers leave the resync to the leader through the copy
+			copySecret := &corev1.Secret{}
+			assert.NoError(t, server.client.Get(context.TODO(), types.NamespacedName{Namespace: "copied", Name: "tls-example-io"}, copySecret))
+			assert.Equal(t, test.expectedRequest, copySecret.Annotations[replica.ResyncRequestedAnnotation] != "")
+
+			if test.expectedStatus == http.StatusOK {
+				result := &api.ResyncResult{}
+				assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), result))
+				assert.Equal(t, []string{"source/tls-example-io"}, result.Sources)
+			}
+		})
+	}
+}
+
+func newServer(t *testing.T, objects ...client.Object) (*Server, *[]types.NamespacedName) {
+	t.Helper()
+
+	// Create a client and the server, recording the resyncs
+	fakeClient := indexfake.NewClient(fake.NewClientBuilder().WithObjects(objects...).Build(), index.Register)
+	policies := policy.NewResolver(fakeClient, nil, policy.Policy{Conflict: policy.ConflictSkip})
+	secretSource := backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil)
+	writer := replica.NewWriter(fakeClient, secretSource, policies, record.NewFakeRecorder(10))
+
+	var resyncs []types.NamespacedName
+	resync := func(_ context.Context, name types.NamespacedName) {
+		resyncs = append(resyncs, name)
+	}
+
+	return NewServer(fakeClient, secretSource, writer, NewErrorLog(10), resync, make(chan struct{})), &resyncs
+}
+
+func serve(t *testing.T, handler http.HandlerFunc, method, target string, response interface{}) {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(method, target, nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), response))
+}
+
+func newSecret(namespace string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      "tls-example-io",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       []byte("certificate"),
+			corev1.TLSPrivateKeyKey: []byte("private key"),
+		},
+	}
+}
+
+func newCopy(namespace string) *corev1.Secret {
+	secret := newSecret(namespace)
+	secret.Labels = replica.Labels(secret.Name)
+
+	return secret
+}
+
+func newIngress(namespace, secretName string) *networkingv1.Ingress {
+	return &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      secretName,
+		},
+		Spec: networkingv1.IngressSpec{
+			TLS: []networkingv1.IngressTLS{
+				{
+					SecretName: secretName,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,145 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+)
+
+// NewClient returns the function building the clients of the manager, wrapping the clients built by newClient, or the
+// default one when nil, so each of their calls is a span
+func NewClient(newClient cluster.NewClientFunc) cluster.NewClientFunc {
+	if newClient == nil {
+		newClient = cluster.DefaultNewClient
+	}
+
+	return func(cache cache.Cache, config *rest.Config, options client.Options, uncachedObjects ...client.Object) (client.Client, error) {
+		c, err := newClient(cache, config, options, uncachedObjects...)
+		if err != nil {
+			return nil, err
+		}
+
+		return WrapClient(c), nil
+	}
+}
+
+// WrapClient returns a client recording each call of the client as a span
+func WrapClient(c client.Client) client.Client {
+	return &tracedClient{
+		Client: c,
+	}
+}
+
+// tracedClient records the calls to the API server, and the reads from the cache, as spans named after the verb and
+// the kind of the object
+type tracedClient struct {
+	client.Client
+}
+
+func (c *tracedClient) Get(ctx context.Context, key client.ObjectKey, object client.Object) (err error) {
+	ctx, span := c.start(ctx, "Get", object, key.Namespace, key.Name)
+	defer func() { End(span, err) }()
+
+	return c.Client.Get(ctx, key, object)
+}
+
+func (c *tracedClient) List(ctx context.Context, list client.ObjectList, options ...client.ListOption) (err error) {
+	listOptions := &client.ListOptions{}
+	listOptions.ApplyOptions(options)
+
+	ctx, span := c.start(ctx, "List", list, listOptions.Namespace, "")
+	defer func() { End(span, err) }()
+
+	return c.Client.List(ctx, list, options...)
+}
+
+func (c *tracedClient) Create(ctx context.Context, object client.Object, options ...client.CreateOption) (err error) {
+	ctx, span := c.start(ctx, "Create", object, object.GetNamespace(), object.GetName())
+	defer func() { End(span, err) }()
+
+	return c.Client.Create(ctx, object, options...)
+}
+
+func (c *tracedClient) Update(ctx context.Context, object client.Object, options ...client.UpdateOption) (err error) {
+	ctx, span := c.start(ctx, "Update", object, object.GetNamespace(), object.GetName())
+	defer func() { End(span, err) }()
+
+	return c.Client.Update(ctx, object, options...)
+}
+
+func (c *tracedClient) Patch(ctx context.Context, object client.Object, patch client.Patch, options ...client.PatchOption) (err error) {
+	ctx, span := c.start(ctx, "Patch", object, object.GetNamespace(), object.GetName())
+	defer func() { End(span, err) }()
+
+	return c.Client.Patch(ctx, object, patch, options...)
+}
+
+func (c *tracedClient) Delete(ctx context.Context, object client.Object, options ...client.DeleteOption) (err error) {
+	ctx, span := c.start(ctx, "Delete", object, object.GetNamespace(), object.GetName())
+	defer func() { End(span, err) }()
+
+	return c.Client.Delete(ctx, object, options...)
+}
+
+func (c *tracedClient) DeleteAllOf(ctx context.Context, object client.Object, options ...client.DeleteAllOfOption) (err error) {
+	ctx, span := c.start(ctx, "DeleteAllOf", object, object.GetNamespace(), "")
+	defer func() { End(span, err) }()
+
+	return c.Client.DeleteAllOf(ctx, object, options...)
+}
+
+func (c *tracedClient) Status() client.StatusWriter {
+	return &tracedStatusWriter{
+		StatusWriter: c.Client.Status(),
+		client:       c,
+	}
+}
+
+// tracedStatusWriter records the updates of the status subresource as spans
+type tracedStatusWriter struct {
+	client.StatusWriter
+
+	client *tracedClient
+}
+
+func (w *tracedStatusWriter) Update(ctx context.Context, object client.Object, options ...client.UpdateOption) (err error) {
+	ctx, span := w.client.start(ctx, "UpdateStatus", object, object.GetNamespace(), object.GetName())
+	defer func() { End(span, err) }()
+
+	return w.StatusWriter.Update(ctx, object, options...)
+}
+
+func (w *tracedStatusWriter) Patch(ctx context.Context, object client.Object, patch client.Patch, options ...client.PatchOption) (err error) {
+	ctx, span := w.client.start(ctx, "PatchStatus", object, object.GetNamespace(), object.GetName())
+	defer func() { End(span, err) }()
+
+	return w.StatusWriter.Patch(ctx, object, patch, options...)
+}
+
+// start starts the span of a call, named like "Get Secret"
+func (c *tracedClient) start(ctx context.Context, verb string, object runtime.Object, namespace, name string) (context.Context, trace.Span) {
+	kind := "Object"
+	if gvk, err := apiutil.GVKForObject(object, c.Scheme()); err == nil {
+		kind = strings.TrimSuffix(gvk.Kind, "List")
+	}
+
+	attributes := []attribute.KeyValue{
+		attribute.String("k8s.kind", kind),
+	}
+	if namespace != "" {
+		attributes = append(attributes, attribute.String("k8s.namespace", namespace))
+	}
+	if name != "" {
+		attributes = append(attributes, attribute.String("k8s.name", name))
+	}
+
+	return Start(ctx, verb+" "+kind, attributes...)
+}
@@ -0,0 +1,37 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// reconciler records each reconcile of the wrapped reconciler as a span, the parent of the spans of its calls
+type reconciler struct {
+	reconcile.Reconciler
+
+	controller string
+}
+
+// Reconciler returns a reconciler recording each reconcile of the named controller as a span
+func Reconciler(controller string, r reconcile.Reconciler) reconcile.Reconciler {
+	return &reconciler{
+		Reconciler: r,
+		controller: controller,
+	}
+}
+
+func (r *reconciler) Reconcile(ctx context.Context, request reconcile.Request) (result reconcile.Result, err error) {
+	ctx, span := Start(ctx, "Reconcile "+r.controller,
+		attribute.String("controller", r.controller),
+		attribute.String("k8s.namespace", request.Namespace),
+		attribute.String("k8s.name", request.Name),
+	)
+	defer func() {
+		span.SetAttributes(attribute.Bool("requeue", result.Requeue || result.RequeueAfter > 0))
+		End(span, err)
+	}()
+
+	return r.Reconciler.Reconcile(ctx, request)
+}
@@ -0,0 +1,42 @@
+package tracing
+
+import (
+	"context"
+
+	"tls-secret-injector/pkg/backend"
+
+	"go.opentelemetry.io/otel/attribute"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// secretSource records the reads of source Secrets from the wrapped backend as spans
+type secretSource struct {
+	backend.SecretSource
+
+	backendName string
+}
+
+// SecretSource returns a source recording each read of the named backend as a span
+func SecretSource(backendName string, source backend.SecretSource) backend.SecretSource {
+	return &secretSource{
+		SecretSource: source,
+		backendName:  backendName,
+	}
+}
+
+func (s *secretSource) Get(ctx context.Context, name string) (sourceSecret *corev1.Secret, err error) {
+	ctx, span := Start(ctx, "Get source Secret", attribute.String("backend", s.backendName), attribute.String("k8s.name", name))
+	defer func() { End(span, err) }()
+
+	return s.SecretSource.Get(ctx, name)
+}
+
+func (s *secretSource) List(ctx context.Context) (sourceSecrets []corev1.Secret, err error) {
+	ctx, span := Start(ctx, "List source Secrets", attribute.String("backend", s.backendName))
+	defer func() {
+		span.SetAttributes(attribute.Int("count", len(sourceSecrets)))
+		End(span, err)
+	}()
+
+	return s.SecretSource.List(ctx)
+}
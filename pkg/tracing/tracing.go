@@ -0,0 +1,110 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName names the tracer of all spans of the injector
+const tracerName = "tls-secret-injector"
+
+// Exporter decides where spans are exported to
+type Exporter string
+
+const (
+	// ExporterNone does not record spans
+	ExporterNone Exporter = "none"
+	// ExporterOTLP exports spans to an OTLP collector over HTTP
+	ExporterOTLP Exporter = "otlp"
+	// ExporterStdout writes spans to the standard output, for local debugging
+	ExporterStdout Exporter = "stdout"
+)
+
+// ParseExporter returns the exporter with the given name
+func ParseExporter(name string) (Exporter, error) {
+	switch Exporter(name) {
+	case ExporterNone, ExporterOTLP, ExporterStdout:
+		return Exporter(name), nil
+
+	default:
+		return "", fmt.Errorf("unknown tracing exporter [%s], expected none, otlp or stdout", name)
+	}
+}
+
+// Options configure the export of spans
+type Options struct {
+	Exporter Exporter
+	// Endpoint is the host:port of the OTLP collector, defaulting to the OTEL_EXPORTER_OTLP_ENDPOINT environment
+	// variable and then to localhost:4318
+	Endpoint string
+	// Insecure exports to the OTLP collector over plain HTTP
+	Insecure bool
+	// SampleRatio is the share of the traces started by the injector that are recorded
+	SampleRatio float64
+}
+
+// Setup installs the global tracer provider exporting the spans, and returns the function flushing the spans left on
+// shutdown
+func Setup(ctx context.Context, options Options) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch options.Exporter {
+	case ExporterOTLP:
+		var clientOptions []otlptracehttp.Option
+		if options.Endpoint != "" {
+			clientOptions = append(clientOptions, otlptracehttp.WithEndpoint(options.Endpoint))
+		}
+		if options.Insecure {
+			clientOptions = append(clientOptions, otlptracehttp.WithInsecure())
+		}
+
+		exporter, err = otlptracehttp.New(ctx, clientOptions...)
+
+	case ExporterStdout:
+		exporter, err = stdouttrace.New()
+
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not set up the %s span exporter: %v", options.Exporter, err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(options.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(tracerName))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as child of the span in the context, which does nothing unless tracing is set up
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End records the error, if any, on the span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestParseExporter(t *testing.T) {
+	for _, name := range []string{"none", "otlp", "stdout"} {
+		exporter, err := ParseExporter(name)
+		assert.NoError(t, err)
+		assert.Equal(t, Exporter(name), exporter)
+	}
+
+	_, err := ParseExporter("jaeger")
+	assert.EqualError(t, err, "unknown tracing exporter [jaeger], expected none, otlp or stdout")
+}
+
+func TestClient(t *testing.T) {
+	recorder := recordSpans(t)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "target",
+			Name:      "tls-example-io",
+		},
+	}
+	c := WrapClient(fake.NewClientBuilder().WithObjects(secret).Build())
+
+	// Check that each call is a span named after the verb and the kind
+	assert.NoError(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "target", Name: "tls-example-io"}, &corev1.Secret{}))
+	assert.NoError(t, c.List(context.TODO(), &corev1.SecretList{}, client.InNamespace("target")))
+	assert.Error(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "target", Name: "missing"}, &corev1.Secret{}))
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 3) {
+		assert.Equal(t, "Get Secret", spans[0].Name())
+		assert.Contains(t, spans[0].Attributes(), attribute.String("k8s.name", "tls-example-io"))
+		assert.Equal(t, codes.Unset, spans[0].Status().Code)
+
+		assert.Equal(t, "List Secret", spans[1].Name())
+		assert.Contains(t, spans[1].Attributes(), attribute.String("k8s.namespace", "target"))
+
+		assert.Equal(t, "Get Secret", spans[2].Name())
+		assert.Equal(t, codes.Error, spans[2].Status().Code)
+	}
+}
+
+func TestReconciler(t *testing.T) {
+	recorder := recordSpans(t)
+
+	// The calls of the reconciler are children of the span of the reconcile
+	c := WrapClient(fake.NewClientBuilder().Build())
+	r := Reconciler("secret", reconcile.Func(func(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+		_ = c.Get(ctx, request.NamespacedName, &corev1.Secret{})
+		return reconcile.Result{}, errors.New("could not reconcile")
+	}))
+
+	_, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "source", Name: "tls-example-io"}})
+	assert.EqualError(t, err, "could not reconcile")
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, "Get Secret", spans[0].Name())
+		assert.Equal(t, "Reconcile secret", spans[1].Name())
+		assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+		assert.Equal(t, codes.Error, spans[1].Status().Code)
+	}
+}
+
+// recordSpans installs a tracer provider recording the spans for the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	return recorder
+}
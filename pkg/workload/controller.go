@@ -0,0 +1,104 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/policy"
+	"tls-secret-injector/pkg/replica"
+	"tls-secret-injector/pkg/tracing"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// NewServiceAccountController copies the Docker config Secrets referenced as imagePullSecrets by ServiceAccounts
+func NewServiceAccountController(mgr manager.Manager, secretSource backend.SecretSource, policies *policy.Resolver, resync func(ctx context.Context, name types.NamespacedName)) error {
+	return newController(mgr, "serviceaccount", serviceAccountTrigger, secretSource, policies, resync, func(event event.UpdateEvent) bool {
+		// Only the imagePullSecrets of a ServiceAccount reference Secrets to copy
+		oldServiceAccount, _ := event.ObjectOld.(*corev1.ServiceAccount)
+		newServiceAccount, _ := event.ObjectNew.(*corev1.ServiceAccount)
+
+		return oldServiceAccount == nil || newServiceAccount == nil ||
+			!reflect.DeepEqual(oldServiceAccount.ImagePullSecrets, newServiceAccount.ImagePullSecrets)
+	})
+}
+
+// NewPodController copies the CA bundle Secrets mounted as volumes by Pods
+func NewPodController(mgr manager.Manager, secretSource backend.SecretSource, policies *policy.Resolver, resync func(ctx context.Context, name types.NamespacedName)) error {
+	return newController(mgr, "pod", podTrigger, secretSource, policies, resync, func(event event.UpdateEvent) bool {
+		// The volumes of a Pod cannot change after its creation
+		return false
+	})
+}
+
+func newController(mgr manager.Manager, name string, trigger trigger, secretSource backend.SecretSource, policies *policy.Resolver, resync func(ctx context.Context, name types.NamespacedName), update func(event event.UpdateEvent) bool) error {
+	// Setup the reconciler
+	recorder := mgr.GetEventRecorderFor("tls-secret-injector")
+	writer := replica.NewWriter(mgr.GetClient(), secretSource, policies, recorder)
+
+	// Only watch the metadata of uncached objects, reading them from the API server when they are reconciled
+	var reader client.Reader = mgr.GetClient()
+	watchedObject := trigger.newObject()
+
+	if trigger.uncached {
+		reader = mgr.GetAPIReader()
+
+		metadata := &metav1.PartialObjectMetadata{}
+		metadata.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind(trigger.kind))
+		watchedObject = metadata
+	}
+
+	triggerController, err := controller.New(name, mgr, controller.Options{
+		Reconciler: tracing.Reconciler(name, newReconciler(mgr.GetClient(), reader, secretSource, writer, trigger, recorder, resync)),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to set up %s controller: %v", trigger.kind, err)
+	}
+
+	// Watch the trigger resource and enqueue its object key
+	err = triggerController.Watch(
+		&source.Kind{
+			Type: watchedObject,
+		},
+		&handler.EnqueueRequestForObject{},
+		predicate.Funcs{
+			UpdateFunc: update,
+			DeleteFunc: func(event event.DeleteEvent) bool {
+				log.Debugf(
+					"Skipping reconciliation of %s [%s/%s] as it has been deleted",
+					trigger.kind,
+					event.Object.GetNamespace(),
+					event.Object.GetName(),
+				)
+				return false
+			},
+			GenericFunc: func(event event.GenericEvent) bool {
+				log.Debugf(
+					"Skipping reconciliation of %s [%s/%s] for the generic event type",
+					trigger.kind,
+					event.Object.GetNamespace(),
+					event.Object.GetName(),
+				)
+				return false
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("unable to watch %s: %v", trigger.kind, err)
+	}
+
+	return nil
+}
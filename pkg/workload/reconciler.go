@@ -0,0 +1,143 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/replica"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// trigger describes a resource referencing Secrets of a single type by name
+type trigger struct {
+	kind       string
+	secretType corev1.SecretType
+	newObject  func() client.Object
+	references func(object client.Object) []string
+	// uncached watches only the metadata of the objects and reads them from the API server, as there are too many of
+	// them to cache in full
+	uncached bool
+}
+
+type reconciler struct {
+	client   client.Client
+	reader   client.Reader
+	source   backend.SecretSource
+	writer   *replica.Writer
+	trigger  trigger
+	recorder record.EventRecorder
+
+	// resync enqueues a source Secret into the Secret controller, which updates its outdated copies
+	resync func(ctx context.Context, name types.NamespacedName)
+}
+
+func newReconciler(client client.Client, reader client.Reader, secretSource backend.SecretSource, writer *replica.Writer, trigger trigger, recorder record.EventRecorder, resync func(ctx context.Context, name types.NamespacedName)) *reconciler {
+	return &reconciler{
+		client:   client,
+		reader:   reader,
+		source:   secretSource,
+		writer:   writer,
+		trigger:  trigger,
+		recorder: recorder,
+		resync:   resync,
+	}
+}
+
+func (r *reconciler) Reconcile(ctx context.Context, request reconcile.Request) (result reconcile.Result, err error) {
+	log.Debugf("Received request to reconcile %s [%s]", r.trigger.kind, request.NamespacedName)
+
+	// Skip if this object is from the same namespace as the source
+	if r.source.IsSourceNamespace(ctx, request.Namespace) {
+		log.Debugf("Skipping reconciliation of %s [%s] from the same namespace as the source", r.trigger.kind, request.NamespacedName)
+		return
+	}
+
+	// Fetch the object, from the cache unless the trigger is uncached
+	object := r.trigger.newObject()
+
+	err = r.reader.Get(ctx, request.NamespacedName, object)
+	if errors.IsNotFound(err) {
+		log.Debugf("Skipping reconciliation of %s [%s] as it no longer exists: %v", r.trigger.kind, request.NamespacedName, err)
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("could not fetch the %s [%s]: %v", r.trigger.kind, request.NamespacedName, err)
+		log.Error(err)
+		return
+	}
+
+	for _, secretName := range r.trigger.references(object) {
+		err = r.copySecret(ctx, object, secretName)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+	}
+
+	return
+}
+
+// copySecret copies the referenced source Secret into the namespace of the object, leaving an existing Secret to the
+// conflict policy and resyncing the source Secret when its existing copy is outdated, unless the source has no valid
+// Secret of the expected type
+func (r *reconciler) copySecret(ctx context.Context, object client.Object, secretName string) error {
+	targetSecretName := types.NamespacedName{
+		Namespace: object.GetNamespace(),
+		Name:      secretName,
+	}
+
+	sourceSecret, err := r.source.Get(ctx, secretName)
+	if errors.IsNotFound(err) {
+		log.Debugf("Skipping creation of the target Secret [%s] as the source has no such Secret", targetSecretName)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not fetch the source Secret [%s]: %v", secretName, err)
+	}
+
+	if sourceSecret.Type != r.trigger.secretType {
+		log.Debugf("Skipping creation of the target Secret [%s] as the source Secret has type %s", targetSecretName, sourceSecret.Type)
+		return nil
+	}
+
+	// Retrying cannot make an invalid source Secret valid, its next change triggers the copy instead
+	err = replica.Validate(sourceSecret)
+	if err != nil {
+		log.Warnf("Skipping creation of the target Secret [%s] as the source Secret is invalid: %v", targetSecretName, err)
+		r.recorder.Eventf(object, corev1.EventTypeWarning, "InvalidSource", "Skipped copying Secret [%s] as %v", secretName, err)
+		return nil
+	}
+
+	created, err := r.writer.Create(ctx, sourceSecret, targetSecretName, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create the target Secret [%s]: %v", targetSecretName, err)
+	}
+
+	if created {
+		log.Infof("Successfully created Secret [%s] for %s", targetSecretName, r.trigger.kind)
+		return nil
+	}
+
+	// Let the Secret controller update the outdated copy, which checks the data and rolls it out first
+	outdated, err := r.writer.IsOutdated(ctx, sourceSecret, targetSecretName)
+	if err != nil {
+		return err
+	}
+	if outdated {
+		sourceSecretName := types.NamespacedName{Namespace: sourceSecret.Namespace, Name: sourceSecret.Name}
+
+		log.Infof("Resyncing source Secret [%s] as its copy [%s] for %s is outdated", sourceSecretName, targetSecretName, r.trigger.kind)
+		r.resync(ctx, sourceSecretName)
+	}
+
+	return nil
+}
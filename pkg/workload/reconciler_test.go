@@ -0,0 +1,271 @@
+package workload
+
+import (
+	"context"
+	"encoding/pem"
+	"testing"
+
+	"tls-secret-injector/pkg/backend"
+	"tls-secret-injector/pkg/policy"
+	"tls-secret-injector/pkg/replica"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcile(t *testing.T) {
+	pullSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "registry-example-io",
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(`{"auths":{"registry.example.io":{"auth":"dXNlcjpwYXNz"}}}`),
+		},
+	}
+
+	caBundleSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "ca-example-io",
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			replica.CABundleKey: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("certificate")}),
+		},
+	}
+
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "target",
+			Name:      "default",
+		},
+		ImagePullSecrets: []corev1.LocalObjectReference{
+			{Name: pullSecret.Name},
+			{Name: caBundleSecret.Name},
+		},
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "target",
+			Name:      "api",
+		},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{
+				{
+					Name: "ca",
+					VolumeSource: corev1.VolumeSource{
+						Projected: &corev1.ProjectedVolumeSource{
+							Sources: []corev1.VolumeProjection{
+								{Secret: &corev1.SecretProjection{LocalObjectReference: corev1.LocalObjectReference{Name: caBundleSecret.Name}}},
+							},
+						},
+					},
+				},
+				{
+					Name: "registry",
+					VolumeSource: corev1.VolumeSource{
+						Secret: &corev1.SecretVolumeSource{SecretName: pullSecret.Name},
+					},
+				},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		trigger trigger
+		object  client.Object
+		created string
+		skipped string
+	}{
+		"copy imagePullSecrets of ServiceAccount": {
+			trigger: serviceAccountTrigger,
+			object:  serviceAccount,
+			created: pullSecret.Name,
+			skipped: caBundleSecret.Name,
+		},
+		"copy CA bundles mounted by Pod": {
+			trigger: podTrigger,
+			object:  pod,
+			created: caBundleSecret.Name,
+			skipped: pullSecret.Name,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Create a client and the reconciler
+			fakeClient := fake.NewClientBuilder().WithObjects(pullSecret, caBundleSecret, test.object).Build()
+			policies := policy.NewResolver(fakeClient, nil, policy.Policy{Conflict: policy.ConflictSkip})
+			secretSource := backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil)
+			writer := replica.NewWriter(fakeClient, secretSource, policies, record.NewFakeRecorder(10))
+
+			reconciler := newReconciler(fakeClient, fakeClient, secretSource, writer, test.trigger, record.NewFakeRecorder(10), nil)
+
+			// Reconcile and check for errors
+			request := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: test.object.GetNamespace(),
+					Name:      test.object.GetName(),
+				},
+			}
+
+			_, err := reconciler.Reconcile(context.TODO(), request)
+			assert.NoError(t, err)
+
+			// Verify that only the Secret of the type the resource references was copied
+			createdSecret := &corev1.Secret{}
+			err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: "target", Name: test.created}, createdSecret)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.trigger.secretType, createdSecret.Type)
+			assert.True(t, replica.IsManaged(createdSecret))
+
+			err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: "target", Name: test.skipped}, &corev1.Secret{})
+			assert.True(t, errors.IsNotFound(err))
+		})
+	}
+}
+
+func TestReconcileInvalidSource(t *testing.T) {
+	pullSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "registry-example-io",
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(`{}`),
+		},
+	}
+
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "target",
+			Name:      "default",
+		},
+		ImagePullSecrets: []corev1.LocalObjectReference{
+			{Name: pullSecret.Name},
+		},
+	}
+
+	// Create a client and the reconciler
+	fakeClient := fake.NewClientBuilder().WithObjects(pullSecret, serviceAccount).Build()
+	policies := policy.NewResolver(fakeClient, nil, policy.Policy{Conflict: policy.ConflictSkip})
+	secretSource := backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil)
+	writer := replica.NewWriter(fakeClient, secretSource, policies, record.NewFakeRecorder(10))
+	recorder := record.NewFakeRecorder(10)
+
+	reconciler := newReconciler(fakeClient, fakeClient, secretSource, writer, serviceAccountTrigger, recorder, nil)
+
+	// Reconcile and check that the invalid Secret is reported instead of retried
+	result, err := reconciler.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "target", Name: "default"}})
+
+	assert.NoError(t, err)
+	assert.False(t, result.Requeue)
+	assert.Contains(t, <-recorder.Events, "Warning InvalidSource")
+
+	err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: "target", Name: pullSecret.Name}, &corev1.Secret{})
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestReconcileExistingSecret(t *testing.T) {
+	pullSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "source",
+			Name:      "registry-example-io",
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(`{"auths":{"registry.example.io":{"auth":"dXNlcjpwYXNz"}}}`),
+		},
+	}
+
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "target",
+			Name:      "default",
+		},
+		ImagePullSecrets: []corev1.LocalObjectReference{
+			{Name: pullSecret.Name},
+		},
+	}
+
+	newTargetSecret := func(labels map[string]string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "target",
+				Name:      pullSecret.Name,
+				Labels:    labels,
+			},
+			Type: corev1.SecretTypeDockerConfigJson,
+			Data: map[string][]byte{
+				corev1.DockerConfigJsonKey: []byte(`{}`),
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		conflict policy.ConflictPolicy
+		existing *corev1.Secret
+		adopted  bool
+		resyncs  []types.NamespacedName
+	}{
+		"resync outdated copy": {
+			conflict: policy.ConflictSkip,
+			existing: newTargetSecret(replica.Labels(pullSecret.Name)),
+			resyncs:  []types.NamespacedName{{Namespace: pullSecret.Namespace, Name: pullSecret.Name}},
+		},
+		"adopt existing secret": {
+			conflict: policy.ConflictAdopt,
+			existing: newTargetSecret(nil),
+			adopted:  true,
+		},
+		"skip existing secret": {
+			conflict: policy.ConflictSkip,
+			existing: newTargetSecret(nil),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Create a client and the reconciler
+			fakeClient := fake.NewClientBuilder().WithObjects(pullSecret, serviceAccount, test.existing).Build()
+			policies := policy.NewResolver(fakeClient, nil, policy.Policy{Conflict: test.conflict})
+			secretSource := backend.NewKubernetes(fakeClient, nil, []string{"source"}, nil)
+			writer := replica.NewWriter(fakeClient, secretSource, policies, record.NewFakeRecorder(10))
+
+			var resyncs []types.NamespacedName
+			resync := func(ctx context.Context, name types.NamespacedName) {
+				resyncs = append(resyncs, name)
+			}
+
+			reconciler := newReconciler(fakeClient, fakeClient, secretSource, writer, serviceAccountTrigger, record.NewFakeRecorder(10), resync)
+
+			// Reconcile and check that the existing Secret is left to the conflict policy
+			_, err := reconciler.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "target", Name: "default"}})
+			assert.NoError(t, err)
+			assert.Equal(t, test.resyncs, resyncs)
+
+			targetSecret := &corev1.Secret{}
+			err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: "target", Name: pullSecret.Name}, targetSecret)
+
+			assert.NoError(t, err)
+			if test.adopted {
+				assert.True(t, replica.IsManaged(targetSecret))
+				assert.Equal(t, pullSecret.Data, targetSecret.Data)
+			} else {
+				assert.Equal(t, `{}`, string(targetSecret.Data[corev1.DockerConfigJsonKey]))
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package workload
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// serviceAccountTrigger copies the Docker config Secrets ServiceAccounts use as imagePullSecrets
+var serviceAccountTrigger = trigger{
+	kind:       "ServiceAccount",
+	secretType: corev1.SecretTypeDockerConfigJson,
+	newObject: func() client.Object {
+		return &corev1.ServiceAccount{}
+	},
+	references: func(object client.Object) []string {
+		serviceAccount, ok := object.(*corev1.ServiceAccount)
+		if !ok {
+			return nil
+		}
+
+		var secretNames []string
+		for _, reference := range serviceAccount.ImagePullSecrets {
+			secretNames = append(secretNames, reference.Name)
+		}
+
+		return secretNames
+	},
+}
+
+// podTrigger copies the Opaque CA bundle Secrets Pods mount as volumes
+var podTrigger = trigger{
+	kind:       "Pod",
+	secretType: corev1.SecretTypeOpaque,
+	uncached:   true,
+	newObject: func() client.Object {
+		return &corev1.Pod{}
+	},
+	references: func(object client.Object) []string {
+		pod, ok := object.(*corev1.Pod)
+		if !ok {
+			return nil
+		}
+
+		var secretNames []string
+		for _, volume := range pod.Spec.Volumes {
+			if volume.Secret != nil {
+				secretNames = append(secretNames, volume.Secret.SecretName)
+			}
+
+			if volume.Projected != nil {
+				for _, projection := range volume.Projected.Sources {
+					if projection.Secret != nil {
+						secretNames = append(secretNames, projection.Secret.Name)
+					}
+				}
+			}
+		}
+
+		return secretNames
+	},
+}